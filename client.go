@@ -1,21 +1,55 @@
 package docker
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/system"
 	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/transport/dockercontext"
 )
 
 // Client is the main docker client
 // Create one with `NewClient`
 type Client struct {
-	tr transport.Doer
+	tr  transport.Doer
+	sys *system.Service
 }
 
 // NewClientConfig is the list of options for configuring a new docker client
 type NewClientConfig struct {
 	// Transport is the communication method for reaching a docker engine instance.
 	// You can implement your own transport, or use the ones provided in the transport package.
-	// If this is unset, the default transport will be used (unix socket connected to /var/run/docker.sock).
+	// If this is unset, the transport is created from the environment (DOCKER_HOST, DOCKER_TLS_VERIFY, DOCKER_CERT_PATH) using `transport.FromEnvContext`
+	// when the first request is made.
+	// Without those variables this is the default for the platform (unix socket connected to /var/run/docker.sock).
 	Transport transport.Doer
+
+	// APIVersion is the API version used for requests which do not have one set in their context with
+	// version.WithAPIVersion.
+	// When NegotiateAPIVersion is set, this is the maximum version that may be negotiated.
+	APIVersion string
+	// NegotiateAPIVersion negotiates the API version with the daemon before the first request which does not have a
+	// version set in its context. The negotiated version is cached for the life of the client, and is available from
+	// the Negotiated method of the system service.
+	NegotiateAPIVersion bool
+	// ResponseLimit is the max size to read from responses when the context does not have a limit set with
+	// httputil.WithResponseLimit.
+	// Zero means httputil.DefaultResponseLimit is used.
+	// It only applies to responses which are read as a whole: streamed responses, such as events, logs, and exports,
+	// are not limited by it, and neither are streamed list responses, see httputil.WithItemLimit.
+	ResponseLimit int64
+	// UserAgent is sent with every request.
+	UserAgent string
+	// Headers are added to every request which does not already have them set.
+	Headers map[string]string
+	// DockerConfigHeaders adds the "HttpHeaders" from the docker CLI config file ($DOCKER_CONFIG/config.json or
+	// ~/.docker/config.json) to every request, like the docker CLI does.
+	// Headers and UserAgent take precedence over these.
+	DockerConfigHeaders bool
 }
 
 type NewClientOption func(*NewClientConfig)
@@ -23,9 +57,13 @@ type NewClientOption func(*NewClientConfig)
 // NewClient creates a new docker client
 // You can pass in options using functional arguments.
 //
-// If no transport is provided as an option, the default transport will be used.
+// If no transport is provided as an option, the transport is created from the environment when the first request is
+// made, see `transport.FromEnvContext`. Finding the daemon is bounded by the context of that request. If that fails,
+// the request returns the error and the next request tries again, so the client works once the daemon is started.
 //
-// You probably want to set an API version for the client to use here.
+// You probably want to set an API version for the client to use here, or have it negotiated with the daemon.
+// The defaults configured on the client are applied to all requests made through services created by the client,
+// unless they are set in the request context.
 // See `NewClientConfig` for available options
 func NewClient(opts ...NewClientOption) *Client {
 	var cfg NewClientConfig
@@ -34,9 +72,75 @@ func NewClient(opts ...NewClientOption) *Client {
 	}
 	tr := cfg.Transport
 	if tr == nil {
-		tr, _ = transport.DefaultTransport()
+		tr = &envDoer{}
+	}
+
+	var configHeaders map[string]string
+	if cfg.DockerConfigHeaders {
+		var err error
+		configHeaders, err = dockercontext.NewStore("").HTTPHeaders()
+		if err != nil {
+			tr = &errDoer{err}
+		}
+	}
+
+	if cfg.APIVersion != "" || cfg.NegotiateAPIVersion || cfg.ResponseLimit != 0 || cfg.UserAgent != "" || len(cfg.Headers) > 0 || len(configHeaders) > 0 {
+		d := newDefaultsDoer(tr, cfg, configHeaders)
+		return &Client{tr: d, sys: d.sys}
+	}
+	return &Client{tr: tr, sys: system.NewService(tr)}
+}
+
+// errDoer is a transport.Doer which always returns the error it was created with.
+// This is used when the client could not create a transport.
+type errDoer struct {
+	err error
+}
+
+func (d *errDoer) Do(context.Context, string, string, ...transport.RequestOpt) (*http.Response, error) {
+	return nil, d.err
+}
+
+func (d *errDoer) DoRaw(context.Context, string, string, ...transport.RequestOpt) (net.Conn, error) {
+	return nil, d.err
+}
+
+// envDoer is a transport.Doer which creates the transport from the environment on the first request, since finding
+// the daemon can take a while.
+// Until a transport is created, every request tries again.
+type envDoer struct {
+	mu sync.Mutex
+	tr *transport.Transport
+}
+
+func (d *envDoer) transport(ctx context.Context) (*transport.Transport, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.tr == nil {
+		tr, err := transport.FromEnvContext(ctx)
+		if err != nil {
+			return nil, errdefs.Wrap(err, "error creating transport from the environment")
+		}
+		d.tr = tr
+	}
+	return d.tr, nil
+}
+
+func (d *envDoer) Do(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (*http.Response, error) {
+	tr, err := d.transport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tr.Do(ctx, method, uri, opts...)
+}
+
+func (d *envDoer) DoRaw(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (net.Conn, error) {
+	tr, err := d.transport(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return &Client{tr: tr}
+	return tr.DoRaw(ctx, method, uri, opts...)
 }
 
 // WithTransport is a NewClientOption that sets the transport to be used for the client.
@@ -46,7 +150,55 @@ func WithTransport(tr transport.Doer) NewClientOption {
 	}
 }
 
+// WithAPIVersion is a NewClientOption that sets the default API version used by the client.
+func WithAPIVersion(v string) NewClientOption {
+	return func(cfg *NewClientConfig) {
+		cfg.APIVersion = v
+	}
+}
+
+// WithAPIVersionNegotiation is a NewClientOption that makes the client negotiate the API version with the daemon.
+// See NewClientConfig.NegotiateAPIVersion.
+func WithAPIVersionNegotiation(cfg *NewClientConfig) {
+	cfg.NegotiateAPIVersion = true
+}
+
+// WithResponseLimit is a NewClientOption that sets the default max size to read from responses.
+func WithResponseLimit(limit int64) NewClientOption {
+	return func(cfg *NewClientConfig) {
+		cfg.ResponseLimit = limit
+	}
+}
+
+// WithUserAgent is a NewClientOption that sets the User-Agent sent with every request.
+func WithUserAgent(ua string) NewClientOption {
+	return func(cfg *NewClientConfig) {
+		cfg.UserAgent = ua
+	}
+}
+
+// WithHeaders is a NewClientOption that adds headers to every request.
+// Headers set by previous calls are kept unless they are set again.
+func WithHeaders(headers map[string]string) NewClientOption {
+	return func(cfg *NewClientConfig) {
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			cfg.Headers[k] = v
+		}
+	}
+}
+
+// WithDockerConfigHeaders is a NewClientOption that adds the "HttpHeaders" from the docker CLI config file to every
+// request.
+// See NewClientConfig.DockerConfigHeaders.
+func WithDockerConfigHeaders(cfg *NewClientConfig) {
+	cfg.DockerConfigHeaders = true
+}
+
 // Transport returns the transport used by the client.
+// This applies the defaults configured on the client.
 func (c *Client) Transport() transport.Doer {
 	return c.tr
 }
@@ -0,0 +1,358 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container"
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/system"
+	"github.com/cpuguy83/go-docker/testutils/fakeengine"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// requestLog records the requests sent to a Doer.
+type requestLog struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (l *requestLog) middleware() transport.Middleware {
+	return transport.WithHooks(transport.Hooks{
+		BeforeRequest: func(req *http.Request) error {
+			l.mu.Lock()
+			l.requests = append(l.requests, req.Clone(context.Background()))
+			l.mu.Unlock()
+			return nil
+		},
+	})
+}
+
+func (l *requestLog) last() *http.Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requests[len(l.requests)-1]
+}
+
+func (l *requestLog) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var paths []string
+	for _, r := range l.requests {
+		paths = append(paths, r.URL.Path)
+	}
+	return paths
+}
+
+func newTestClient(t *testing.T, e *fakeengine.Engine, opts ...NewClientOption) (*Client, *requestLog) {
+	t.Helper()
+	log := &requestLog{}
+	tr := transport.Chain(e.Doer(), log.middleware())
+	return NewClient(append([]NewClientOption{WithTransport(tr)}, opts...)...), log
+}
+
+func TestClientDefaults(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+
+	ctx := context.Background()
+
+	c, log := newTestClient(t, e)
+	_, err := c.ContainerService().List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(log.last().URL.Path, "/containers/json"))
+
+	c, log = newTestClient(t, e,
+		WithAPIVersion("1.40"),
+		WithUserAgent("test-agent"),
+		WithHeaders(map[string]string{"x-foo": "bar"}),
+	)
+	_, err = c.ContainerService().List(ctx)
+	assert.NilError(t, err)
+	req := log.last()
+	assert.Check(t, cmp.Equal(req.URL.Path, "/v1.40/containers/json"))
+	assert.Check(t, cmp.Equal(req.Header.Get("User-Agent"), "test-agent"))
+	assert.Check(t, cmp.Equal(req.Header.Get("X-Foo"), "bar"))
+
+	// The context takes precedence
+	_, err = c.ContainerService().List(version.WithAPIVersion(ctx, "1.39"))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(log.last().URL.Path, "/v1.39/containers/json"))
+
+	_, err = c.SystemService().Ping(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(log.last().URL.Path, "/v1.40/_ping"))
+}
+
+func TestClientFromEnv(t *testing.T) {
+	for _, sock := range []string{"/var/run/docker.sock", "/run/podman/podman.sock"} {
+		if _, err := os.Stat(sock); err == nil {
+			t.Skip("a daemon socket exists at " + sock)
+		}
+	}
+
+	dir := t.TempDir()
+	runtimeDir := filepath.Join(dir, "run")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv(transport.EnvDockerHost, "")
+	t.Setenv(transport.EnvDockerTLSVerify, "")
+	t.Setenv(transport.EnvDockerCertPath, "")
+
+	ctx := context.Background()
+
+	// The daemon is found when the first request is made, not when the client is created.
+	c := NewClient()
+	_, err := c.SystemService().Ping(ctx)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	e := fakeengine.New()
+	defer e.Close()
+	assert.NilError(t, os.MkdirAll(runtimeDir, 0o700))
+	l, err := net.Listen("unix", filepath.Join(runtimeDir, "docker.sock"))
+	assert.NilError(t, err)
+	go e.Serve(l)
+
+	_, err = c.SystemService().Ping(ctx)
+	assert.NilError(t, err)
+}
+
+func TestClientNegotiateAPIVersion(t *testing.T) {
+	e := fakeengine.New(fakeengine.WithAPIVersion("1.24", "1.30"))
+	defer e.Close()
+
+	ctx := context.Background()
+
+	c, log := newTestClient(t, e, WithAPIVersionNegotiation)
+	for i := 0; i < 2; i++ {
+		_, err := c.ContainerService().List(ctx)
+		assert.NilError(t, err)
+	}
+	_, err := c.ImageService().List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(log.paths(), []string{"/version", "/v1.30/containers/json", "/v1.30/containers/json", "/v1.30/images/json"}))
+
+	n, ok := c.SystemService().Negotiated()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(n, system.Negotiation{APIVersion: "1.30", ServerAPIVersion: "1.30", ServerMinAPIVersion: "1.24"}))
+
+	// The client version is the maximum version.
+	c, log = newTestClient(t, e, WithAPIVersionNegotiation, WithAPIVersion("1.25"))
+	_, err = c.ContainerService().List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(log.last().URL.Path, "/v1.25/containers/json"))
+
+	// There is no version supported by both.
+	c, _ = newTestClient(t, e, WithAPIVersionNegotiation, WithAPIVersion("1.20"))
+	_, err = c.ContainerService().List(ctx)
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "no API version is supported by both the client (1.12 to 1.20) and the server (1.24 to 1.30)"))
+	_, ok = c.SystemService().Negotiated()
+	assert.Check(t, !ok)
+
+	// The ceiling is newer than 1.41.
+	e = fakeengine.New(fakeengine.WithAPIVersion("1.24", "1.45"))
+	defer e.Close()
+	c, log = newTestClient(t, e, WithAPIVersionNegotiation)
+	_, err = c.ContainerService().List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(log.last().URL.Path, "/v1.45/containers/json"))
+}
+
+func TestClientFeatureGating(t *testing.T) {
+	e := fakeengine.New(fakeengine.WithAPIVersion("1.24", "1.30"))
+	defer e.Close()
+	e.AddImage(fakeengine.Image{RepoTags: []string{"busybox:latest"}, Config: fakeengine.ImageConfig{Cmd: []string{"sh"}}})
+
+	ctx := context.Background()
+
+	// The negotiated version is checked, not only the one in the context.
+	c, log := newTestClient(t, e, WithAPIVersionNegotiation)
+	_, err := c.ContainerService().Create(ctx, "busybox:latest", container.WithCreatePlatform("linux/amd64"))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "container create platform requires API version 1.41"))
+	assert.Check(t, cmp.DeepEqual(log.paths(), []string{"/version"}))
+
+	ctr, err := c.ContainerService().Create(ctx, "busybox:latest")
+	assert.NilError(t, err)
+
+	_, err = ctr.Wait(version.WithAPIVersion(ctx, "1.29"), container.WithWaitCondition(container.WaitConditionNextExit))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "container wait requires API version 1.30"))
+}
+
+func TestClientResponseLimit(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+	e.AddImage(fakeengine.Image{RepoTags: []string{"busybox:latest"}, Config: fakeengine.ImageConfig{Cmd: []string{"sh"}}})
+
+	ctx := context.Background()
+
+	// Enough labels that inspecting the container is larger than the default response limit.
+	labels := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		labels[fmt.Sprintf("label%d", i)] = strings.Repeat("x", 100)
+	}
+	c, _ := newTestClient(t, e)
+	ctr, err := c.ContainerService().Create(ctx, "busybox:latest", container.WithCreateConfigOpt(func(cfg *containerapi.Config) {
+		cfg.Labels = labels
+	}))
+	assert.NilError(t, err)
+
+	_, err = ctr.Inspect(ctx)
+	assert.Check(t, err != nil)
+
+	c, _ = newTestClient(t, e, WithResponseLimit(1<<20))
+	inspect, err := c.ContainerService().NewContainer(ctx, ctr.ID()).Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(inspect.Config.Labels, 200))
+
+	// The context takes precedence
+	_, err = c.ContainerService().NewContainer(ctx, ctr.ID()).Inspect(httputil.WithResponseLimit(ctx, 100))
+	assert.Check(t, err != nil)
+}
+
+func TestClientResponseLimitStream(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+	e.AddImage(fakeengine.Image{RepoTags: []string{"busybox:latest"}, Config: fakeengine.ImageConfig{Cmd: []string{"sh"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The client default only applies to responses which are read as a whole, not to streams.
+	c, _ := newTestClient(t, e, WithResponseLimit(200))
+	events, err := c.SystemService().Events(ctx)
+	assert.NilError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err := c.ContainerService().Create(ctx, "busybox:latest")
+		assert.NilError(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		ev, err := events()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(ev.Action, "create"))
+	}
+
+	out := strings.Repeat("x", 1000)
+	ctr, err := c.ContainerService().Create(ctx, "busybox:latest", container.WithCreateCmd("echo", out))
+	assert.NilError(t, err)
+	assert.NilError(t, ctr.Start(ctx))
+	_, err = ctr.Wait(ctx)
+	assert.NilError(t, err)
+
+	r, w := io.Pipe()
+	assert.NilError(t, ctr.Logs(ctx, func(cfg *container.LogReadConfig) {
+		cfg.Stdout = w
+	}))
+	data, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), out+"\n"))
+}
+
+func TestClientContainerExport(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+	e.AddImage(fakeengine.Image{RepoTags: []string{"busybox:latest"}, Config: fakeengine.ImageConfig{Cmd: []string{"sh"}}})
+
+	ctx := context.Background()
+
+	// Enough files that the archive is larger than the default response limit.
+	files := make([]string, 64)
+	for i := range files {
+		files[i] = fmt.Sprintf("/file%d", i)
+	}
+	c, _ := newTestClient(t, e)
+	ctr, err := c.ContainerService().Create(ctx, "busybox:latest", container.WithCreateCmd(append([]string{"touch"}, files...)...))
+	assert.NilError(t, err)
+	assert.NilError(t, ctr.Start(ctx))
+	_, err = ctr.Wait(ctx)
+	assert.NilError(t, err)
+
+	rdr, err := ctr.Export(ctx)
+	assert.NilError(t, err)
+	defer rdr.Close()
+	n, err := io.Copy(io.Discard, rdr)
+	assert.NilError(t, err)
+	assert.Check(t, n > httputil.DefaultResponseLimit, n)
+
+	// The context takes precedence
+	rdr, err = ctr.Export(httputil.WithResponseLimit(ctx, 1024))
+	assert.NilError(t, err)
+	defer rdr.Close()
+	n, err = io.Copy(io.Discard, rdr)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(n, int64(1024)))
+}
+
+func TestClientListStream(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+
+	// Enough images that listing them is larger than the default response limit.
+	for i := 0; i < 200; i++ {
+		e.AddImage(fakeengine.Image{RepoTags: []string{fmt.Sprintf("image%d:%s", i, strings.Repeat("x", 100))}})
+	}
+
+	ctx := context.Background()
+
+	// Lists are streamed, so only the size of each item is limited.
+	c, _ := newTestClient(t, e)
+	images, err := c.ImageService().List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(images, 200))
+
+	_, err = c.ImageService().List(httputil.WithItemLimit(ctx, 100))
+	assert.Check(t, cmp.ErrorContains(err, "size limit of 100 bytes"))
+
+	// A response limit set in the context still applies.
+	_, err = c.ImageService().List(httputil.WithResponseLimit(ctx, 100))
+	assert.Check(t, err != nil)
+
+	var n int
+	for img, err := range c.ImageService().ListIter(ctx) {
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Len(img.RepoTags, 1))
+		n++
+		if n == 10 {
+			break
+		}
+	}
+	assert.Check(t, cmp.Equal(n, 10))
+}
+
+func TestClientDockerConfigHeaders(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"HttpHeaders": {"X-Meta": "config", "X-Other": "config"}}`), 0o644))
+
+	c, log := newTestClient(t, e, WithDockerConfigHeaders, WithHeaders(map[string]string{"X-Other": "client"}))
+	_, err := c.ContainerService().List(context.Background())
+	assert.NilError(t, err)
+	req := log.last()
+	assert.Check(t, cmp.Equal(req.Header.Get("X-Meta"), "config"))
+	assert.Check(t, cmp.Equal(req.Header.Get("X-Other"), "client"))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{`), 0o644))
+	c, _ = newTestClient(t, e, WithDockerConfigHeaders)
+	_, err = c.ContainerService().List(context.Background())
+	assert.Check(t, cmp.ErrorContains(err, "error parsing docker config"))
+}
@@ -24,6 +24,14 @@ type AttachConfig struct {
 	Stderr     bool
 	DetachKeys string
 	Logs       bool
+	// WebSocket attaches over a WebSocket (/containers/{id}/attach/ws) instead of hijacking the HTTP connection.
+	// This is useful when there is a proxy in between which only passes WebSockets.
+	//
+	// The daemon does not separate stdout and stderr over a WebSocket, so only one of them may be requested.
+	// Closing stdin sends a close frame, which ends the whole WebSocket on the daemon side rather than just stdin:
+	// output is only read until the daemon replies to it. Attach to stdin separately, e.g. with `StdinPipe`, to keep
+	// reading output after closing stdin.
+	WebSocket bool
 }
 
 // AttachIO is used to for providing access to stdio streams of a container
@@ -55,7 +63,14 @@ func WithAttachStream(o *AttachConfig) {
 	o.Stream = true
 }
 
-// WithAttachDetachKeys sets the key sequence for detaching from an attach request
+// WithAttachWebSocket sets the attach request to use a WebSocket instead of hijacking the HTTP connection.
+// See AttachConfig.WebSocket for details.
+func WithAttachWebSocket(o *AttachConfig) {
+	o.WebSocket = true
+}
+
+// WithAttachDetachKeys sets the key sequence for detaching from an attach request, e.g. "ctrl-p,ctrl-q".
+// Reading the sequence from stdin ends the attach request, the container keeps running.
 func WithAttachDetachKeys(keys string) func(*AttachConfig) {
 	return func(o *AttachConfig) {
 		o.DetachKeys = keys
@@ -94,18 +109,11 @@ func handleAttach(ctx context.Context, tr transport.Doer, name string, cfg Attac
 		}
 	}()
 
-	withAttachRequest := func(req *http.Request) error {
-		q := req.URL.Query()
-		q.Add("stdin", strconv.FormatBool(cfg.Stdin))
-		q.Add("stdout", strconv.FormatBool(cfg.Stdout))
-		q.Add("stderr", strconv.FormatBool(cfg.Stderr))
-		q.Add("logs", strconv.FormatBool(cfg.Logs))
-		q.Add("stream", strconv.FormatBool(cfg.Stream))
-		req.URL.RawQuery = q.Encode()
-		return nil
+	if cfg.WebSocket {
+		return handleAttachWebSocket(ctx, tr, name, cfg)
 	}
 
-	rwc, err := tr.DoRaw(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/attach"), withAttachRequest, transport.WithUpgrade("tcp"))
+	rwc, err := tr.DoRaw(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/attach"), withAttachRequest(cfg), transport.WithUpgrade("tcp"))
 	if err != nil {
 		return nil, err
 	}
@@ -182,10 +190,77 @@ func handleAttach(ctx context.Context, tr transport.Doer, name string, cfg Attac
 	return &attachIO{stdin: stdin, stdout: stdout, stderr: stderr}, nil
 }
 
+func withAttachRequest(cfg AttachConfig) transport.RequestOpt {
+	return func(req *http.Request) error {
+		q := req.URL.Query()
+		q.Add("stdin", strconv.FormatBool(cfg.Stdin))
+		q.Add("stdout", strconv.FormatBool(cfg.Stdout))
+		q.Add("stderr", strconv.FormatBool(cfg.Stderr))
+		q.Add("logs", strconv.FormatBool(cfg.Logs))
+		q.Add("stream", strconv.FormatBool(cfg.Stream))
+		if cfg.DetachKeys != "" {
+			q.Add("detachKeys", cfg.DetachKeys)
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
+
+// handleAttachWebSocket attaches using /containers/{id}/attach/ws.
+// Output is not multiplexed over a WebSocket, even if the container does not have a TTY.
+// See AttachConfig.WebSocket for how closing stdin differs from a hijacked connection.
+func handleAttachWebSocket(ctx context.Context, tr transport.Doer, name string, cfg AttachConfig) (*attachIO, error) {
+	if cfg.Stdout && cfg.Stderr {
+		return nil, errdefs.Invalid("stdout and stderr cannot be separated when attaching over a websocket, attach to them separately")
+	}
+
+	ws, err := transport.DoWebSocket(ctx, tr, version.Join(ctx, "/containers/"+name+"/attach/ws"), withAttachRequest(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &attachIO{conn: ws}
+	if cfg.Stdout || cfg.Stderr {
+		r, w := io.Pipe()
+		if cfg.Stdout {
+			a.stdout = r
+		} else {
+			a.stderr = r
+		}
+		go func() {
+			_, err := io.Copy(w, ws)
+			w.CloseWithError(err)
+			ws.Close()
+		}()
+	} else {
+		// Keep reading so that pings and the close from the daemon are handled.
+		go func() {
+			io.Copy(io.Discard, ws)
+			ws.Close()
+		}()
+	}
+	if cfg.Stdin {
+		a.stdin = webSocketStdin{ws}
+	}
+	return a, nil
+}
+
+// webSocketStdin is the stdin of an attach over a WebSocket.
+// Close only sends a close frame, the connection is closed once the output, if any, is drained.
+type webSocketStdin struct {
+	*transport.WebSocketConn
+}
+
+func (s webSocketStdin) Close() error {
+	return s.CloseWrite()
+}
+
 type attachIO struct {
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
 	stderr io.ReadCloser
+	// conn is closed along with the streams, if set.
+	conn io.Closer
 }
 
 func (a *attachIO) Stdin() io.WriteCloser {
@@ -210,15 +285,29 @@ func (a *attachIO) Close() error {
 	if a.stderr != nil {
 		a.stderr.Close()
 	}
+	if a.conn != nil {
+		a.conn.Close()
+	}
 	return nil
 }
 
-// TODO: Do these pipe calls need to be able to set options like DetachKeys?
+// pipeAttachConfig returns the config used by the pipe functions, which only attach to a single stream.
+func pipeAttachConfig(stream func(*AttachConfig), opts []AttachOption) AttachConfig {
+	cfg := AttachConfig{Stream: true}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	cfg.Stdin, cfg.Stdout, cfg.Stderr = false, false, false
+	stream(&cfg)
+	return cfg
+}
 
 // StdinPipe opens a pipe to the container's stdin stream.
 // If the container is not configured with `OpenStdin`, this will not work.
-func (c *Container) StdinPipe(ctx context.Context) (io.WriteCloser, error) {
-	attach, err := handleAttach(ctx, c.tr, c.id, AttachConfig{Stdin: true, Stream: true})
+//
+// Options, such as `WithAttachWebSocket`, may be passed to configure the attach request.
+func (c *Container) StdinPipe(ctx context.Context, opts ...AttachOption) (io.WriteCloser, error) {
+	attach, err := handleAttach(ctx, c.tr, c.id, pipeAttachConfig(WithAttachStdin, opts))
 	if err != nil {
 		return nil, err
 	}
@@ -226,8 +315,10 @@ func (c *Container) StdinPipe(ctx context.Context) (io.WriteCloser, error) {
 }
 
 // StdoutPipe opens a pipe to the container's stdout stream.
-func (c *Container) StdoutPipe(ctx context.Context) (io.ReadCloser, error) {
-	attach, err := handleAttach(ctx, c.tr, c.id, AttachConfig{Stdout: true, Stream: true})
+//
+// Options, such as `WithAttachWebSocket`, may be passed to configure the attach request.
+func (c *Container) StdoutPipe(ctx context.Context, opts ...AttachOption) (io.ReadCloser, error) {
+	attach, err := handleAttach(ctx, c.tr, c.id, pipeAttachConfig(WithAttachStdout, opts))
 	if err != nil {
 		return nil, err
 	}
@@ -235,8 +326,10 @@ func (c *Container) StdoutPipe(ctx context.Context) (io.ReadCloser, error) {
 }
 
 // StderrPipe opens a pipe to the container's stderr stream.
-func (c *Container) StderrPipe(ctx context.Context) (io.ReadCloser, error) {
-	attach, err := handleAttach(ctx, c.tr, c.id, AttachConfig{Stderr: true, Stream: true})
+//
+// Options, such as `WithAttachWebSocket`, may be passed to configure the attach request.
+func (c *Container) StderrPipe(ctx context.Context, opts ...AttachOption) (io.ReadCloser, error) {
+	attach, err := handleAttach(ctx, c.tr, c.id, pipeAttachConfig(WithAttachStderr, opts))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,54 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+// replayTests are the tests with a recorded cassette in testdata/cassettes.
+var replayTests = []string{
+	"TestChanges",
+	"TestContainerAttachNoTTY",
+	"TestExport",
+	"TestInspect",
+	"TestList",
+	"TestListFilter",
+	"TestListLimit",
+	"TestLogsSince",
+	"TestLogsTimestamps",
+	"TestLogsUntil",
+	"TestPause",
+	"TestRemove",
+	"TestRename",
+	"TestResize",
+	"TestStart",
+	"TestStats",
+	"TestStderrLogs",
+	"TestStdoutLogs",
+	"TestStdoutStderrLogs",
+	"TestStop",
+	"TestTop",
+}
+
+// TestCassetteReplay re-runs the recorded tests from their cassettes, without a daemon.
+func TestCassetteReplay(t *testing.T) {
+	if os.Getenv(testutils.EnvCassetteMode) != "" {
+		t.Skip("already running in cassette mode")
+	}
+
+	exe, err := os.Executable()
+	assert.NilError(t, err)
+
+	cmd := exec.Command(exe, "-test.count=1", "-test.run", "^("+strings.Join(replayTests, "|")+")$")
+	cmd.Env = append(os.Environ(),
+		testutils.EnvCassetteMode+"=replay",
+		"DOCKER_HOST=unix:///nonexistent/docker.sock",
+	)
+	out, err := cmd.CombinedOutput()
+	assert.NilError(t, err, string(out))
+}
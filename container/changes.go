@@ -0,0 +1,44 @@
+package container
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Changes lists the changes made to the filesystem of a container compared to its image.
+// The list can be long, so the response is not limited as a whole, only each entry is, see httputil.WithItemLimit.
+func (s *Service) Changes(ctx context.Context, name string) ([]containerapi.Change, error) {
+	return handleChanges(ctx, s.tr, name)
+}
+
+func handleChanges(ctx context.Context, tr transport.Doer, name string) ([]containerapi.Change, error) {
+	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+name+"/changes"))
+	})
+	if err != nil {
+		return nil, errdefs.Wrap(err, "error getting container changes")
+	}
+	defer resp.Body.Close()
+
+	var changes []containerapi.Change
+	for c, err := range httputil.DecodeArray[containerapi.Change](ctx, resp.Body) {
+		if err != nil {
+			return changes, errdefs.Wrap(err, "error decoding container changes")
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// Changes lists the changes made to the filesystem of the container compared to its image.
+// The list can be long, so the response is not limited as a whole, only each entry is, see httputil.WithItemLimit.
+func (c *Container) Changes(ctx context.Context) ([]containerapi.Change, error) {
+	return handleChanges(ctx, c.tr, c.id)
+}
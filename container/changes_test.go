@@ -0,0 +1,48 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	_, err := s.Changes(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("/bin/sh", "-c", "mkdir /data && touch /data/a && rm /etc/passwd"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	changes, err := c.Changes(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(changes, 0))
+
+	assert.NilError(t, c.Start(ctx))
+	waitForContainerExit(ctx, t, c)
+
+	changes, err = c.Changes(ctx)
+	assert.NilError(t, err)
+
+	kinds := make(map[string]containerapi.ChangeKind, len(changes))
+	for _, change := range changes {
+		kinds[change.Path] = change.Kind
+	}
+	assert.Check(t, cmp.DeepEqual(kinds, map[string]containerapi.ChangeKind{
+		"/data":       containerapi.ChangeAdd,
+		"/data/a":     containerapi.ChangeAdd,
+		"/etc":        containerapi.ChangeModify,
+		"/etc/passwd": containerapi.ChangeDelete,
+	}))
+}
@@ -20,7 +20,7 @@ func TestCommit(t *testing.T) {
 	defer s.Remove(ctx, c.ID(), WithRemoveForce)
 
 	repo := "test"
-	tag := "commit" + testutils.GenerateRandomString()
+	tag := "commit" + testutils.RandomString(t)
 
 	ref, err := c.Commit(ctx, func(cfg *CommitConfig) {
 		cfg.Reference = &CommitImageReference{
@@ -0,0 +1,49 @@
+package containerapi
+
+import "strconv"
+
+// ChangeKind is the kind of change made to a path of the container filesystem.
+type ChangeKind uint8
+
+const (
+	// ChangeModify is a path which was modified.
+	ChangeModify ChangeKind = iota
+	// ChangeAdd is a path which was added.
+	ChangeAdd
+	// ChangeDelete is a path which was deleted.
+	ChangeDelete
+)
+
+// String returns "modified", "added" or "deleted".
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeModify:
+		return "modified"
+	case ChangeAdd:
+		return "added"
+	case ChangeDelete:
+		return "deleted"
+	default:
+		return "unknown change kind " + strconv.Itoa(int(k))
+	}
+}
+
+// Short returns the single letter used by `docker diff`: "C", "A" or "D".
+func (k ChangeKind) Short() string {
+	switch k {
+	case ChangeModify:
+		return "C"
+	case ChangeAdd:
+		return "A"
+	case ChangeDelete:
+		return "D"
+	default:
+		return "?"
+	}
+}
+
+// Change is a change made to the container filesystem compared to its image, as returned by the changes API.
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
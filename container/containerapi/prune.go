@@ -0,0 +1,9 @@
+package containerapi
+
+// Prune is the result of pruning containers.
+type Prune struct {
+	// ContainersDeleted are the IDs of the removed containers.
+	ContainersDeleted []string
+	// SpaceReclaimed is the disk space freed, in bytes.
+	SpaceReclaimed int64
+}
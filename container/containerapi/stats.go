@@ -0,0 +1,238 @@
+package containerapi
+
+import (
+	"strings"
+	"time"
+)
+
+// Stats is the resource usage of a container, as returned by the stats API.
+//
+// The shape of some fields depends on the cgroup version used by the daemon:
+// with cgroup v2, CPUUsage.PercpuUsage, MemoryStats.MaxUsage and MemoryStats.Failcnt are not set, the keys of
+// MemoryStats.Stats are the ones of the cgroup v2 memory.stat file, and only BlkioStats.IoServiceBytesRecursive is
+// set. The helper methods handle both.
+type Stats struct {
+	Read    time.Time `json:"read"`
+	PreRead time.Time `json:"preread"`
+
+	PidsStats    PidsStats    `json:"pids_stats,omitempty"`
+	BlkioStats   BlkioStats   `json:"blkio_stats,omitempty"`
+	NumProcs     uint32       `json:"num_procs"`
+	StorageStats StorageStats `json:"storage_stats,omitempty"`
+	CPUStats     CPUStats     `json:"cpu_stats,omitempty"`
+	PreCPUStats  CPUStats     `json:"precpu_stats,omitempty"`
+	MemoryStats  MemoryStats  `json:"memory_stats,omitempty"`
+
+	Name     string                  `json:"name,omitempty"`
+	ID       string                  `json:"id,omitempty"`
+	Networks map[string]NetworkStats `json:"networks,omitempty"`
+}
+
+// ThrottlingData stores CPU throttling stats of one running container.
+type ThrottlingData struct {
+	// Number of periods with throttling active
+	Periods uint64 `json:"periods"`
+	// Number of periods when the container hit its throttling limit.
+	ThrottledPeriods uint64 `json:"throttled_periods"`
+	// Aggregate time the container was throttled for in nanoseconds.
+	ThrottledTime uint64 `json:"throttled_time"`
+}
+
+// CPUUsage stores All CPU stats aggregated since container inception.
+type CPUUsage struct {
+	// Total CPU time consumed in nanoseconds.
+	TotalUsage uint64 `json:"total_usage"`
+	// Total CPU time consumed per core in nanoseconds.
+	// Not set with cgroup v2.
+	PercpuUsage []uint64 `json:"percpu_usage,omitempty"`
+	// Time spent by tasks of the cgroup in kernel mode in nanoseconds.
+	UsageInKernelmode uint64 `json:"usage_in_kernelmode"`
+	// Time spent by tasks of the cgroup in user mode in nanoseconds.
+	UsageInUsermode uint64 `json:"usage_in_usermode"`
+}
+
+// CPUStats aggregates and wraps all CPU related info of container
+type CPUStats struct {
+	CPUUsage       CPUUsage       `json:"cpu_usage"`
+	SystemUsage    uint64         `json:"system_cpu_usage,omitempty"`
+	OnlineCPUs     uint32         `json:"online_cpus,omitempty"`
+	ThrottlingData ThrottlingData `json:"throttling_data,omitempty"`
+}
+
+// MemoryStats aggregates all memory stats since container inception on Linux.
+// Windows returns stats for commit and private working set only.
+type MemoryStats struct {
+	// Current res_counter usage for memory
+	Usage uint64 `json:"usage,omitempty"`
+	// Maximum usage ever recorded.
+	// Not set with cgroup v2.
+	MaxUsage uint64 `json:"max_usage,omitempty"`
+	// The contents of the memory.stat file of the cgroup.
+	Stats map[string]uint64 `json:"stats,omitempty"`
+	// Number of times memory usage hits limits.
+	// Not set with cgroup v2.
+	Failcnt uint64 `json:"failcnt,omitempty"`
+	Limit   uint64 `json:"limit,omitempty"`
+
+	// Committed bytes, Windows only.
+	Commit uint64 `json:"commitbytes,omitempty"`
+	// Peak committed bytes, Windows only.
+	CommitPeak uint64 `json:"commitpeakbytes,omitempty"`
+	// Private working set, Windows only.
+	PrivateWorkingSet uint64 `json:"privateworkingset,omitempty"`
+}
+
+// BlkioStatEntry is one small entity to store a piece of Blkio stats
+type BlkioStatEntry struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+// BlkioStats stores All IO service stats for data read and write.
+// With cgroup v2 only IoServiceBytesRecursive is set and the ops are lower case.
+type BlkioStats struct {
+	IoServiceBytesRecursive []BlkioStatEntry `json:"io_service_bytes_recursive"`
+	IoServicedRecursive     []BlkioStatEntry `json:"io_serviced_recursive"`
+	IoQueuedRecursive       []BlkioStatEntry `json:"io_queue_recursive"`
+	IoServiceTimeRecursive  []BlkioStatEntry `json:"io_service_time_recursive"`
+	IoWaitTimeRecursive     []BlkioStatEntry `json:"io_wait_time_recursive"`
+	IoMergedRecursive       []BlkioStatEntry `json:"io_merged_recursive"`
+	IoTimeRecursive         []BlkioStatEntry `json:"io_time_recursive"`
+	SectorsRecursive        []BlkioStatEntry `json:"sectors_recursive"`
+}
+
+// StorageStats is the disk I/O stats for read/write on Windows.
+type StorageStats struct {
+	ReadCountNormalized  uint64 `json:"read_count_normalized,omitempty"`
+	ReadSizeBytes        uint64 `json:"read_size_bytes,omitempty"`
+	WriteCountNormalized uint64 `json:"write_count_normalized,omitempty"`
+	WriteSizeBytes       uint64 `json:"write_size_bytes,omitempty"`
+}
+
+// NetworkStats aggregates the network stats of one container
+type NetworkStats struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+	// Endpoint ID, Windows only.
+	EndpointID string `json:"endpoint_id,omitempty"`
+	// Instance ID, Windows only.
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// PidsStats contains the stats of a container's pids
+type PidsStats struct {
+	// Current is the number of pids in the cgroup
+	Current uint64 `json:"current,omitempty"`
+	// Limit is the hard limit on the number of pids in the cgroup.
+	// A "Limit" of 0 means that there is no limit.
+	Limit uint64 `json:"limit,omitempty"`
+}
+
+// CPUPercent computes the CPU usage of the container between PreCPUStats and CPUStats, like `docker stats` does on
+// Linux.
+// 100% is one fully used CPU.
+func (s Stats) CPUPercent() float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(s.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if systemDelta <= 0 || cpuDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// UsageNoCache returns the memory usage excluding the page cache which can be reclaimed, like `docker stats` does.
+func (m MemoryStats) UsageNoCache() uint64 {
+	// cgroup v1
+	if v, ok := m.Stats["total_inactive_file"]; ok {
+		if v < m.Usage {
+			return m.Usage - v
+		}
+		return m.Usage
+	}
+	// cgroup v2
+	if v := m.Stats["inactive_file"]; v < m.Usage {
+		return m.Usage - v
+	}
+	return m.Usage
+}
+
+// MemoryPercent returns the memory usage, excluding the cache, as a percentage of the memory limit.
+func (s Stats) MemoryPercent() float64 {
+	if s.MemoryStats.Limit == 0 {
+		return 0
+	}
+	return float64(s.MemoryStats.UsageNoCache()) / float64(s.MemoryStats.Limit) * 100
+}
+
+// NetworkIO returns the bytes received and sent on all the networks of the container.
+func (s Stats) NetworkIO() (rx, tx uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+// BlockIO returns the bytes read from and written to block devices by the container.
+func (s Stats) BlockIO() (read, write uint64) {
+	for _, e := range s.BlkioStats.IoServiceBytesRecursive {
+		// The ops are capitalized with cgroup v1 and lower case with cgroup v2.
+		switch strings.ToLower(e.Op) {
+		case "read":
+			read += e.Value
+		case "write":
+			write += e.Value
+		}
+	}
+	return read, write
+}
+
+// IORates are I/O rates in bytes per second.
+type IORates struct {
+	NetworkRx  float64
+	NetworkTx  float64
+	BlockRead  float64
+	BlockWrite float64
+}
+
+// IORatesSince computes the I/O rates of the container between the prev sample and s, using the time each sample
+// was read at.
+// The rates are zero if prev is not older than s. Counters which decreased, such as when the container restarted,
+// give a zero rate.
+func (s Stats) IORatesSince(prev Stats) IORates {
+	secs := s.Read.Sub(prev.Read).Seconds()
+	if secs <= 0 {
+		return IORates{}
+	}
+
+	rate := func(cur, prev uint64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return float64(cur-prev) / secs
+	}
+
+	rx, tx := s.NetworkIO()
+	prevRx, prevTx := prev.NetworkIO()
+	read, write := s.BlockIO()
+	prevRead, prevWrite := prev.BlockIO()
+
+	return IORates{
+		NetworkRx:  rate(rx, prevRx),
+		NetworkTx:  rate(tx, prevTx),
+		BlockRead:  rate(read, prevRead),
+		BlockWrite: rate(write, prevWrite),
+	}
+}
@@ -0,0 +1,80 @@
+package containerapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// Trimmed down stats from a daemon using cgroup v1.
+const statsV1 = `{
+	"read": "2024-01-01T00:00:02Z",
+	"preread": "2024-01-01T00:00:01Z",
+	"cpu_stats": {"cpu_usage": {"total_usage": 300, "percpu_usage": [150, 150]}, "system_cpu_usage": 2000},
+	"precpu_stats": {"cpu_usage": {"total_usage": 100, "percpu_usage": [50, 50]}, "system_cpu_usage": 1000},
+	"memory_stats": {"usage": 1000, "max_usage": 2000, "limit": 4000, "stats": {"cache": 300, "total_inactive_file": 200}},
+	"blkio_stats": {"io_service_bytes_recursive": [
+		{"major": 8, "minor": 0, "op": "Read", "value": 100},
+		{"major": 8, "minor": 0, "op": "Write", "value": 50},
+		{"major": 8, "minor": 0, "op": "Total", "value": 150}
+	]},
+	"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 5, "tx_bytes": 5}}
+}`
+
+// Trimmed down stats from a daemon using cgroup v2.
+const statsV2 = `{
+	"read": "2024-01-01T00:00:02Z",
+	"cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 2000, "online_cpus": 4},
+	"precpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 1000, "online_cpus": 4},
+	"memory_stats": {"usage": 1000, "limit": 4000, "stats": {"file": 300, "inactive_file": 400}},
+	"blkio_stats": {"io_service_bytes_recursive": [
+		{"major": 8, "minor": 0, "op": "read", "value": 100},
+		{"major": 8, "minor": 0, "op": "write", "value": 50}
+	]}
+}`
+
+func TestStats(t *testing.T) {
+	var v1, v2 Stats
+	assert.NilError(t, json.Unmarshal([]byte(statsV1), &v1))
+	assert.NilError(t, json.Unmarshal([]byte(statsV2), &v2))
+
+	assert.Check(t, cmp.Equal(v1.CPUPercent(), 40.0))
+	assert.Check(t, cmp.Equal(v2.CPUPercent(), 80.0))
+
+	assert.Check(t, cmp.Equal(v1.MemoryStats.UsageNoCache(), uint64(800)))
+	assert.Check(t, cmp.Equal(v2.MemoryStats.UsageNoCache(), uint64(600)))
+	assert.Check(t, cmp.Equal(v2.MemoryPercent(), 15.0))
+
+	rx, tx := v1.NetworkIO()
+	assert.Check(t, cmp.Equal(rx, uint64(15)))
+	assert.Check(t, cmp.Equal(tx, uint64(25)))
+
+	for _, s := range []Stats{v1, v2} {
+		read, write := s.BlockIO()
+		assert.Check(t, cmp.Equal(read, uint64(100)))
+		assert.Check(t, cmp.Equal(write, uint64(50)))
+	}
+
+	// No samples to compare
+	assert.Check(t, cmp.Equal(Stats{}.CPUPercent(), 0.0))
+	assert.Check(t, cmp.Equal(Stats{}.MemoryPercent(), 0.0))
+}
+
+func TestIORatesSince(t *testing.T) {
+	var prev Stats
+	assert.NilError(t, json.Unmarshal([]byte(statsV1), &prev))
+
+	cur := prev
+	cur.Read = prev.Read.Add(2 * time.Second)
+	cur.Networks = map[string]NetworkStats{"eth0": {RxBytes: 215, TxBytes: 25}}
+	cur.BlkioStats.IoServiceBytesRecursive = []BlkioStatEntry{{Op: "Read", Value: 300}, {Op: "Write", Value: 10}}
+
+	rates := cur.IORatesSince(prev)
+	assert.Check(t, cmp.DeepEqual(rates, IORates{NetworkRx: 100, NetworkTx: 0, BlockRead: 100, BlockWrite: 0}))
+
+	// Samples out of order
+	assert.Check(t, cmp.DeepEqual(prev.IORatesSince(cur), IORates{}))
+}
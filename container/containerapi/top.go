@@ -0,0 +1,75 @@
+package containerapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Top is the process list of a container, as returned by the top API.
+//
+// The columns depend on the arguments passed to ps, e.g. `ps -ef` (the default) has UID, PID and CMD columns while
+// `ps aux` has USER, PID and COMMAND columns. On Windows the columns are Name, PID, CPU and Private Working Set.
+type Top struct {
+	// Titles are the column headers.
+	Titles []string
+	// Processes has one row per process, with a value per column.
+	Processes [][]string
+}
+
+// Index returns the index of the first column which has one of the titles, ignoring case, or -1 if there is none.
+func (t Top) Index(titles ...string) int {
+	for _, title := range titles {
+		for i, v := range t.Titles {
+			if strings.EqualFold(v, title) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Column returns the values of the first column which has one of the titles, ignoring case, for each process.
+// It returns false if there is no such column.
+func (t Top) Column(titles ...string) ([]string, bool) {
+	idx := t.Index(titles...)
+	if idx < 0 {
+		return nil, false
+	}
+	values := make([]string, len(t.Processes))
+	for i, p := range t.Processes {
+		if idx < len(p) {
+			values[i] = p[idx]
+		}
+	}
+	return values, true
+}
+
+// PIDs returns the PID of each process.
+// An error is returned if the ps arguments left out the PID column.
+func (t Top) PIDs() ([]int, error) {
+	values, ok := t.Column("PID")
+	if !ok {
+		return nil, errors.New("process list has no PID column")
+	}
+	pids := make([]int, len(values))
+	for i, v := range values {
+		pid, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID %q: %w", v, err)
+		}
+		pids[i] = pid
+	}
+	return pids, nil
+}
+
+// Users returns the user of each process, from the USER or the UID column.
+func (t Top) Users() ([]string, bool) {
+	return t.Column("USER", "UID")
+}
+
+// Commands returns the command of each process, from the CMD or the COMMAND column, or the Name column on Windows.
+func (t Top) Commands() ([]string, bool) {
+	return t.Column("CMD", "COMMAND", "Name")
+}
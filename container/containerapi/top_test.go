@@ -0,0 +1,50 @@
+package containerapi
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTop(t *testing.T) {
+	ef := Top{
+		Titles: []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"},
+		Processes: [][]string{
+			{"root", "1234", "1200", "0", "10:00", "?", "00:00:00", "top"},
+			{"nobody", "1300", "1200", "0", "10:01", "?", "00:00:00", "sleep 100"},
+		},
+	}
+	aux := Top{
+		Titles:    []string{"USER", "PID", "%CPU", "%MEM", "VSZ", "RSS", "TTY", "STAT", "START", "TIME", "COMMAND"},
+		Processes: [][]string{{"root", "1234", "0.0", "0.0", "1000", "100", "?", "Ss", "10:00", "0:00", "top"}},
+	}
+
+	pids, err := ef.PIDs()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(pids, []int{1234, 1300}))
+
+	users, ok := ef.Users()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(users, []string{"root", "nobody"}))
+	users, ok = aux.Users()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(users, []string{"root"}))
+
+	cmds, ok := ef.Commands()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(cmds, []string{"top", "sleep 100"}))
+	cmds, ok = aux.Commands()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(cmds, []string{"top"}))
+
+	assert.Check(t, cmp.Equal(aux.Index("%cpu"), 2))
+	assert.Check(t, cmp.Equal(aux.Index("nope"), -1))
+	_, ok = ef.Column("nope")
+	assert.Check(t, !ok)
+
+	_, err = Top{Titles: []string{"CMD"}, Processes: [][]string{{"top"}}}.PIDs()
+	assert.Check(t, cmp.ErrorContains(err, "no PID column"))
+	_, err = Top{Titles: []string{"PID"}, Processes: [][]string{{"x"}}}.PIDs()
+	assert.Check(t, cmp.ErrorContains(err, `invalid PID "x"`))
+}
@@ -120,8 +120,19 @@ func (s *Service) Create(ctx context.Context, img string, opts ...CreateOption)
 		return nil
 	}
 
+	var features []version.Feature
+	if c.Platform != "" {
+		features = append(features, version.CreatePlatform)
+	}
+	if hc := c.Spec.Config.Healthcheck; hc != nil && hc.StartPeriod != 0 {
+		features = append(features, version.HealthcheckStartPeriod)
+	}
+	if len(c.Spec.HostConfig.Mounts) > 0 {
+		features = append(features, version.Mounts)
+	}
+
 	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/create"), httputil.WithJSONBody(c.Spec), withName, withPlatform)
+		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/create"), version.Require(features...), httputil.WithJSONBody(c.Spec), withName, withPlatform)
 	})
 	if err != nil {
 		return nil, err
@@ -26,7 +26,7 @@ func TestCreate(t *testing.T) {
 		}
 	}
 
-	name := strings.ToLower(t.Name()) + testutils.GenerateRandomString()
+	name := strings.ToLower(t.Name()) + testutils.RandomString(t)
 	c, err = s.Create(ctx, "busybox:latest", WithCreateName(name))
 	assert.NilError(t, err)
 	defer func() {
@@ -0,0 +1,21 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// asConflict converts an error to an errdefs.Conflict error if its message contains any of msgs.
+// Older daemons return a 500 status code, rather than a 409, for some conflicts with the state of the container.
+func asConflict(err error, msgs ...string) error {
+	if err == nil || !errdefs.IsSystem(err) {
+		return err
+	}
+	for _, m := range msgs {
+		if strings.Contains(err.Error(), m) {
+			return errdefs.AsConflict(err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package container
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"gotest.tools/v3/assert"
+)
+
+func TestAsConflict(t *testing.T) {
+	errFor := func(code int, msg string) error {
+		return httputil.CheckResponseError(&http.Response{
+			StatusCode: code,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"message":"` + msg + `"}`)),
+		})
+	}
+
+	err := asConflict(errFor(http.StatusInternalServerError, "Container abc is not running"), "is not running")
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	err = asConflict(errFor(http.StatusInternalServerError, "something else"), "is not running")
+	assert.Check(t, !errdefs.IsConflict(err), err)
+	assert.Check(t, errdefs.IsSystem(err), err)
+
+	err = asConflict(errFor(http.StatusNotFound, "No such container: abc is not running"), "is not running")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	err = asConflict(errors.New("is not running"), "is not running")
+	assert.Check(t, !errdefs.IsConflict(err), err)
+
+	assert.Check(t, asConflict(nil, "is not running"))
+}
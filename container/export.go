@@ -0,0 +1,46 @@
+package container
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Export exports the filesystem of a container.
+// The returned reader is a tar archive of the container rootfs, it must be closed by the caller.
+//
+// The archive is as large as the container filesystem, so no response limit is applied unless one is set on the
+// context.
+func (s *Service) Export(ctx context.Context, name string) (io.ReadCloser, error) {
+	return handleExport(ctx, s.tr, name)
+}
+
+func handleExport(ctx context.Context, tr transport.Doer, name string) (io.ReadCloser, error) {
+	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+name+"/export"))
+	})
+	if err != nil {
+		return nil, errdefs.Wrap(err, "error exporting container")
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-tar" {
+		resp.Body.Close()
+		return nil, errdefs.Systemf("error exporting container: unexpected content type: %s", ct)
+	}
+	return resp.Body, nil
+}
+
+// Export exports the filesystem of the container.
+// The returned reader is a tar archive of the container rootfs, it must be closed by the caller.
+//
+// The archive is as large as the container filesystem, so no response limit is applied unless one is set on the
+// context.
+func (c *Container) Export(ctx context.Context) (io.ReadCloser, error) {
+	return handleExport(ctx, c.tr, c.id)
+}
@@ -0,0 +1,48 @@
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	_, err := s.Export(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("touch", "/hello"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+	assert.NilError(t, c.Start(ctx))
+	waitForContainerExit(ctx, t, c)
+
+	rdr, err := c.Export(ctx)
+	assert.NilError(t, err)
+	defer rdr.Close()
+
+	var found bool
+	tr := tar.NewReader(rdr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NilError(t, err)
+		if hdr.Name == "hello" {
+			found = true
+		}
+	}
+	assert.Check(t, found, "expected /hello in the exported filesystem")
+}
@@ -17,7 +17,7 @@ func TestInspect(t *testing.T) {
 
 	s, ctx := newTestService(t, context.Background())
 
-	_, err := s.Inspect(ctx, "notExist"+testutils.GenerateRandomString())
+	_, err := s.Inspect(ctx, "notExist"+testutils.RandomString(t))
 	assert.Check(t, errdefs.IsNotFound(err), err)
 
 	name := strings.ToLower(t.Name())
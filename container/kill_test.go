@@ -16,7 +16,7 @@ func TestKill(t *testing.T) {
 
 	s, ctx := newTestService(t, context.Background())
 
-	err := s.Kill(ctx, "notexist"+testutils.GenerateRandomString())
+	err := s.Kill(ctx, "notexist"+testutils.RandomString(t))
 	assert.Check(t, errdefs.IsNotFound(err), err)
 
 	c, err := s.Create(ctx, "busybox:latest", WithCreateName(strings.ToLower(t.Name())), WithCreateTTY, WithCreateCmd("/bin/sh", "-c", "trap 'exit 0' SIGTERM; while true; do usleep 100000; done"))
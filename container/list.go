@@ -2,19 +2,21 @@ package container
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
+	"iter"
 	"net/http"
 	"strconv"
 
 	"github.com/cpuguy83/go-docker/container/containerapi"
 	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/filters"
 	"github.com/cpuguy83/go-docker/httputil"
 
 	"github.com/cpuguy83/go-docker/version"
 )
 
 // ListFilter represents filters to process on the container list.
+//
+// Deprecated: use filters.Args, see ListConfig.Filters.
 type ListFilter struct {
 	Ancestor  []string `json:"ancestor,omitempty"`
 	Before    []string `json:"before,omitempty"`
@@ -33,11 +35,47 @@ type ListFilter struct {
 	Volume    []string `json:"volume,omitempty"`
 }
 
+// Args converts the filter to filters.Args.
+func (f ListFilter) Args() filters.Args {
+	var a filters.Args
+	f.addTo(&a)
+	return a
+}
+
+func (f ListFilter) addTo(a *filters.Args) {
+	for key, values := range map[string][]string{
+		"ancestor":  f.Ancestor,
+		"before":    f.Before,
+		"expose":    f.Expose,
+		"exited":    f.Exited,
+		"health":    f.Health,
+		"id":        f.ID,
+		"isolation": f.Isolation,
+		"is-task":   f.IsTask,
+		"label":     f.Label,
+		"name":      f.Name,
+		"network":   f.Network,
+		"publish":   f.Publish,
+		"since":     f.Since,
+		"status":    f.Status,
+		"volume":    f.Volume,
+	} {
+		for _, v := range values {
+			a.Add(key, v)
+		}
+	}
+}
+
 // ListConfig holds the options for listing containers
 type ListConfig struct {
-	All    bool
-	Limit  int
-	Size   bool
+	All   bool
+	Limit int
+	Size  bool
+	// Filters is validated against filters.ContainerList.
+	Filters filters.Args
+	// Filter is added to Filters.
+	//
+	// Deprecated: use Filters.
 	Filter ListFilter
 }
 
@@ -45,8 +83,34 @@ type ListConfig struct {
 // ListOption configure a ListConfig.
 type ListOption func(config *ListConfig)
 
+// WithListFilters sets the filters used to select the containers to list, e.g. filters.Status or filters.Label.
+func WithListFilters(f filters.Args) ListOption {
+	return func(cfg *ListConfig) {
+		cfg.Filters = f
+	}
+}
+
 // List fetches a list of containers.
+// An empty, non-nil, list is returned if there are no containers, and nil on error.
+//
+// The list is decoded as a stream, see ListIter.
 func (s *Service) List(ctx context.Context, opts ...ListOption) ([]containerapi.Container, error) {
+	containers := []containerapi.Container{}
+	for c, err := range s.ListIter(ctx, opts...) {
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// ListIter fetches a list of containers and decodes the response one container at a time.
+//
+// The response is not limited by the response limit set in the context, unless one is set explicitly, since the
+// memory used is bounded by the size of each container instead (see httputil.WithItemLimit).
+// The request is made when the iterator is used and any error ends the iteration.
+func (s *Service) ListIter(ctx context.Context, opts ...ListOption) iter.Seq2[containerapi.Container, error] {
 	cfg := ListConfig{
 		Limit: -1,
 	}
@@ -59,36 +123,33 @@ func (s *Service) List(ctx context.Context, opts ...ListOption) ([]containerapi.
 		q.Add("all", strconv.FormatBool(cfg.All))
 		q.Add("limit", strconv.Itoa(cfg.Limit))
 		q.Add("size", strconv.FormatBool(cfg.Size))
-		filterJSON, err := json.Marshal(cfg.Filter)
-
-		if err != nil {
-			return err
-		}
-		q.Add("filters", string(filterJSON))
 
 		req.URL.RawQuery = q.Encode()
 		return nil
 	}
 
-	var containers []containerapi.Container
-
-	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/json"), withListConfig)
-	})
-	if err != nil {
-		return containers, err
-	}
-
-	defer resp.Body.Close()
+	args := cfg.Filters.Clone()
+	cfg.Filter.addTo(&args)
 
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return containers, nil
-	}
-
-	if err := json.Unmarshal(data, &containers); err != nil {
-		return containers, errdefs.Wrap(err, "error unmarshalling container json")
+	return func(yield func(containerapi.Container, error) bool) {
+		ctx := httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+		resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+			return s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/json"), withListConfig, filters.ContainerList.WithFilters(args))
+		})
+		if err != nil {
+			yield(containerapi.Container{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		for c, err := range httputil.DecodeArray[containerapi.Container](ctx, resp.Body) {
+			if err != nil {
+				yield(c, errdefs.Wrap(err, "error unmarshalling container json"))
+				return
+			}
+			if !yield(c, nil) {
+				return
+			}
+		}
 	}
-
-	return containers, nil
 }
@@ -65,7 +65,12 @@ func (c *Container) Logs(ctx context.Context, opts ...LogsReadOption) error {
 
 	// Here we do not want to limit the response size since we are returning a log stream, so we perform this manually
 	//  instead of with httputil.DoRequest
-	resp, err := c.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+c.id+"/logs"), withLogConfig)
+	var features []version.Feature
+	if cfg.Until != "" {
+		features = append(features, version.LogsUntil)
+	}
+
+	resp, err := c.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+c.id+"/logs"), version.Require(features...), withLogConfig)
 	if err != nil {
 		return err
 	}
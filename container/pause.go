@@ -0,0 +1,57 @@
+package container
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Pause suspends all processes in the container.
+// An errdefs.Conflict error is returned if the container is not running or is already paused.
+func (s *Service) Pause(ctx context.Context, name string) error {
+	return handlePause(ctx, s.tr, name)
+}
+
+func handlePause(ctx context.Context, tr transport.Doer, name string) error {
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/pause"))
+	})
+	if err != nil {
+		return errdefs.Wrap(asConflict(err, "is not running", "is already paused"), "error pausing container")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Pause suspends all processes in the container.
+// An errdefs.Conflict error is returned if the container is not running or is already paused.
+func (c *Container) Pause(ctx context.Context) error {
+	return handlePause(ctx, c.tr, c.id)
+}
+
+// Unpause resumes the processes of a paused container.
+// An errdefs.Conflict error is returned if the container is not paused.
+func (s *Service) Unpause(ctx context.Context, name string) error {
+	return handleUnpause(ctx, s.tr, name)
+}
+
+func handleUnpause(ctx context.Context, tr transport.Doer, name string) error {
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/unpause"))
+	})
+	if err != nil {
+		return errdefs.Wrap(asConflict(err, "is not running", "is not paused"), "error unpausing container")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Unpause resumes the processes of a paused container.
+// An errdefs.Conflict error is returned if the container is not paused.
+func (c *Container) Unpause(ctx context.Context) error {
+	return handleUnpause(ctx, c.tr, c.id)
+}
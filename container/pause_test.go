@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+func TestPause(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	err := s.Pause(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	err = c.Pause(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+
+	err = c.Unpause(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Pause(ctx))
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.State.Paused)
+
+	err = c.Pause(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Unpause(ctx))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, !inspect.State.Paused)
+	assert.Check(t, inspect.State.Running)
+}
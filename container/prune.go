@@ -0,0 +1,69 @@
+package container
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/filters"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// PruneConfig holds the options for pruning containers.
+type PruneConfig struct {
+	// Filters is validated against filters.ContainerPrune.
+	Filters filters.Args
+}
+
+// PruneOption is used as functional arguments to prune containers. PruneOption
+// configure a PruneConfig.
+type PruneOption func(config *PruneConfig)
+
+// WithPruneFilters sets the filters used to select the containers to prune, e.g. filters.Until or filters.Label.
+func WithPruneFilters(f filters.Args) PruneOption {
+	return func(cfg *PruneConfig) {
+		cfg.Filters = f
+	}
+}
+
+// Prune removes all stopped containers which match the filters.
+// The list of removed containers can be long, so the response is not limited as a whole, only each entry is, see
+// httputil.WithItemLimit.
+func (s *Service) Prune(ctx context.Context, opts ...PruneOption) (containerapi.Prune, error) {
+	cfg := PruneConfig{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	var prune containerapi.Prune
+
+	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/prune"), version.Require(version.ContainerPrune), filters.ContainerPrune.WithFilters(cfg.Filters))
+	})
+	if err != nil {
+		return prune, errdefs.Wrap(err, "error pruning containers")
+	}
+	defer resp.Body.Close()
+
+	err = httputil.DecodeObject(ctx, resp.Body, func(name string, f *httputil.Field) error {
+		switch name {
+		case "ContainersDeleted":
+			for id, err := range httputil.DecodeElements[string](f) {
+				if err != nil {
+					return err
+				}
+				prune.ContainersDeleted = append(prune.ContainersDeleted, id)
+			}
+		case "SpaceReclaimed":
+			return f.Decode(&prune.SpaceReclaimed)
+		}
+		return nil
+	})
+	if err != nil {
+		return prune, errdefs.Wrap(err, "error decoding prune response")
+	}
+	return prune, nil
+}
@@ -0,0 +1,48 @@
+package container
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/filters"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	value := testutils.RandomString(t)
+	withLabel := WithCreateConfigOpt(func(cfg *containerapi.Config) {
+		cfg.Labels = map[string]string{"test-prune": value}
+	})
+
+	stopped, err := s.Create(ctx, "busybox:latest", WithCreateCmd("true"), withLabel)
+	assert.NilError(t, err)
+	defer s.Remove(ctx, stopped.ID(), WithRemoveForce)
+	assert.NilError(t, stopped.Start(ctx))
+	waitForContainerExit(ctx, t, stopped)
+
+	running, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"), withLabel)
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, running.ID(), WithRemoveForce))
+	}()
+	assert.NilError(t, running.Start(ctx))
+
+	_, err = s.Prune(ctx, WithPruneFilters(filters.NewArgs(filters.Status("exited"))))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+
+	report, err := s.Prune(ctx, WithPruneFilters(filters.NewArgs(filters.Label("test-prune="+value))))
+	assert.NilError(t, err)
+	assert.Check(t, slices.Contains(report.ContainersDeleted, stopped.ID()), report.ContainersDeleted)
+	assert.Check(t, !slices.Contains(report.ContainersDeleted, running.ID()), report.ContainersDeleted)
+
+	_, err = stopped.Inspect(ctx)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
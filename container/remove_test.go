@@ -14,7 +14,7 @@ func TestRemove(t *testing.T) {
 
 	s, ctx := newTestService(t, context.Background())
 
-	err := s.Remove(ctx, "notexist"+testutils.GenerateRandomString())
+	err := s.Remove(ctx, "notexist"+testutils.RandomString(t))
 	assert.Check(t, errdefs.IsNotFound(err))
 
 	c, err := s.Create(ctx, "busybox:latest")
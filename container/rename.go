@@ -0,0 +1,41 @@
+package container
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Rename changes the name of a container.
+// An errdefs.Conflict error is returned if the new name is already in use.
+func (s *Service) Rename(ctx context.Context, name, newName string) error {
+	return handleRename(ctx, s.tr, name, newName)
+}
+
+func handleRename(ctx context.Context, tr transport.Doer, name, newName string) error {
+	withName := func(req *http.Request) error {
+		q := req.URL.Query()
+		q.Set("name", newName)
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/rename"), withName)
+	})
+	if err != nil {
+		return errdefs.Wrap(asConflict(err, "is already in use"), "error renaming container")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Rename changes the name of the container.
+// An errdefs.Conflict error is returned if the new name is already in use.
+func (c *Container) Rename(ctx context.Context, newName string) error {
+	return handleRename(ctx, c.tr, c.id, newName)
+}
@@ -0,0 +1,46 @@
+package container
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	name := strings.ToLower(t.Name()) + testutils.RandomString(t)
+
+	err := s.Rename(ctx, "notexist"+testutils.RandomString(t), name)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateName(name))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	other, err := s.Create(ctx, "busybox:latest", WithCreateName(name+"-other"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, other.ID(), WithRemoveForce))
+	}()
+
+	err = c.Rename(ctx, name+"-other")
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, s.Rename(ctx, name, name+"-renamed"))
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.Name, "/"+name+"-renamed"))
+
+	_, err = s.Inspect(ctx, name)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// ResizeConfig holds the options for resizing a container TTY
+type ResizeConfig struct {
+	Width  int
+	Height int
+}
+
+// Resize resizes the TTY of the container.
+// An errdefs.Conflict error is returned if the container is not running.
+func (s *Service) Resize(ctx context.Context, name string, cfg ResizeConfig) error {
+	return handleResize(ctx, s.tr, name, cfg)
+}
+
+func handleResize(ctx context.Context, tr transport.Doer, name string, cfg ResizeConfig) error {
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/resize"), func(req *http.Request) error {
+			q := req.URL.Query()
+			q.Add("w", strconv.Itoa(cfg.Width))
+			q.Add("h", strconv.Itoa(cfg.Height))
+			req.URL.RawQuery = q.Encode()
+			return nil
+		})
+	})
+	if err != nil {
+		return errdefs.Wrap(asConflict(err, "is not running"), "error resizing container")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Resize resizes the TTY of the container.
+// An errdefs.Conflict error is returned if the container is not running.
+func (c *Container) Resize(ctx context.Context, cfg ResizeConfig) error {
+	return handleResize(ctx, c.tr, c.id, cfg)
+}
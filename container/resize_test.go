@@ -0,0 +1,32 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+func TestResize(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	err := s.Resize(ctx, "notexist"+testutils.RandomString(t), ResizeConfig{Width: 80, Height: 24})
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateTTY, WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	err = c.Resize(ctx, ResizeConfig{Width: 80, Height: 24})
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+	assert.NilError(t, c.Resize(ctx, ResizeConfig{Width: 80, Height: 24}))
+	assert.NilError(t, s.Resize(ctx, c.ID(), ResizeConfig{Width: 120, Height: 40}))
+}
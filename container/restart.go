@@ -0,0 +1,83 @@
+package container
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// RestartOption is used as functional arguments to container restart
+// RestartOptions configure a RestartConfig
+type RestartOption func(*RestartConfig)
+
+// RestartConfig holds the options for restarting a container
+type RestartConfig struct {
+	// Signal is sent to stop the container, instead of the stop signal configured on the container.
+	Signal  string
+	Timeout *time.Duration
+}
+
+// WithRestartSignal sets the signal sent to stop the container.
+func WithRestartSignal(signal string) RestartOption {
+	return func(cfg *RestartConfig) {
+		cfg.Signal = signal
+	}
+}
+
+// WithRestartTimeout sets the timeout for a restart request.
+// Docker waits up to the timeout duration for the container to stop before forcefully terminating the process and
+// starting the container again.
+func WithRestartTimeout(dur time.Duration) RestartOption {
+	return func(cfg *RestartConfig) {
+		cfg.Timeout = &dur
+	}
+}
+
+// Restart stops, if it is running, and starts the container.
+func (s *Service) Restart(ctx context.Context, name string, opts ...RestartOption) error {
+	return handleRestart(ctx, s.tr, name, opts...)
+}
+
+func handleRestart(ctx context.Context, tr transport.Doer, name string, opts ...RestartOption) error {
+	var cfg RestartConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	var features []version.Feature
+	if cfg.Signal != "" {
+		features = append(features, version.RestartSignal)
+	}
+
+	withQuery := func(req *http.Request) error {
+		q := req.URL.Query()
+		if cfg.Signal != "" {
+			q.Set("signal", cfg.Signal)
+		}
+		if cfg.Timeout != nil {
+			q.Set("t", strconv.FormatFloat(cfg.Timeout.Seconds(), 'f', 0, 64))
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/restart"), version.Require(features...), withQuery)
+	})
+	if err != nil {
+		return errdefs.Wrap(err, "error restarting container")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Restart stops, if it is running, and starts the container.
+func (c *Container) Restart(ctx context.Context, opts ...RestartOption) error {
+	return handleRestart(ctx, c.tr, c.id, opts...)
+}
@@ -0,0 +1,44 @@
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+)
+
+func TestRestart(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	err := s.Restart(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	// A container which is not running is started.
+	assert.NilError(t, c.Restart(ctx))
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, inspect.State.Running)
+	pid := inspect.State.Pid
+
+	assert.NilError(t, c.Restart(ctx, WithRestartTimeout(time.Second)))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.State.Running)
+	assert.Check(t, inspect.State.Pid != pid, "expected a new process")
+
+	assert.NilError(t, c.Restart(ctx, WithRestartSignal("SIGKILL")))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.State.Running)
+}
@@ -2,6 +2,7 @@ package container
 
 import (
 	"context"
+	"os"
 	"sync"
 	"testing"
 
@@ -18,20 +19,28 @@ var (
 
 func negoiateTestAPIVersion(t testing.TB, tr transport.Doer) {
 	versionOnce.Do(func() {
-		ctx := context.Background()
-		ctx, err := system.NewService(tr).NegotiateAPIVersion(ctx)
-		if err != nil {
-			t.Fatalf("error negotiating api version: %v", err)
-		}
-		negotiatedAPIVersion = version.APIVersion(ctx)
+		negotiatedAPIVersion = negotiateAPIVersion(t, tr)
 	})
 }
 
+func negotiateAPIVersion(t testing.TB, tr transport.Doer) string {
+	ctx, err := system.NewService(tr).NegotiateAPIVersion(context.Background())
+	if err != nil {
+		t.Fatalf("error negotiating api version: %v", err)
+	}
+	return version.APIVersion(ctx)
+}
+
 func newTestServiceNoTap(t *testing.T, ctx context.Context, noTap bool) (*Service, context.Context) {
 	tr, _ := testutils.NewDefaultTestTransport(t, noTap)
 	if version.APIVersion(ctx) == "" {
-		negoiateTestAPIVersion(t, tr)
-		ctx = version.WithAPIVersion(ctx, negotiatedAPIVersion)
+		if os.Getenv(testutils.EnvCassetteMode) != "" {
+			// Each test is recorded on its own, so its cassette must have the negotiation.
+			ctx = version.WithAPIVersion(ctx, negotiateAPIVersion(t, tr))
+		} else {
+			negoiateTestAPIVersion(t, tr)
+			ctx = version.WithAPIVersion(ctx, negotiatedAPIVersion)
+		}
 	}
 	return NewService(tr), ctx
 }
@@ -0,0 +1,127 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"net/http"
+	"strconv"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// StatsConfig holds the options for getting container stats.
+type StatsConfig struct {
+	// OneShot makes the daemon return the stats right away instead of waiting for a second sample to fill in
+	// PreCPUStats. It only applies to `Stats`.
+	OneShot bool
+}
+
+// StatsOption is used as functional arguments to container stats
+// StatsOption configure a StatsConfig.
+type StatsOption func(*StatsConfig)
+
+// WithStatsOneShot is a StatsOption which sets the OneShot option.
+func WithStatsOneShot(cfg *StatsConfig) {
+	cfg.OneShot = true
+}
+
+func withStatsConfig(stream bool, cfg StatsConfig) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		q := req.URL.Query()
+		q.Set("stream", strconv.FormatBool(stream))
+		if cfg.OneShot {
+			q.Set("one-shot", "true")
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
+
+// Stats gets a single sample of the resource usage of the container.
+func (s *Service) Stats(ctx context.Context, name string, opts ...StatsOption) (containerapi.Stats, error) {
+	return handleStats(ctx, s.tr, name, opts...)
+}
+
+func handleStats(ctx context.Context, tr transport.Doer, name string, opts ...StatsOption) (containerapi.Stats, error) {
+	var cfg StatsConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	var features []version.Feature
+	if cfg.OneShot {
+		features = append(features, version.StatsOneShot)
+	}
+
+	var stats containerapi.Stats
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+name+"/stats"), version.Require(features...), withStatsConfig(false, cfg))
+	})
+	if err != nil {
+		return stats, errdefs.Wrap(err, "error getting container stats")
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return stats, errdefs.Wrap(err, "error decoding container stats")
+	}
+	return stats, nil
+}
+
+// Stats gets a single sample of the resource usage of the container.
+func (c *Container) Stats(ctx context.Context, opts ...StatsOption) (containerapi.Stats, error) {
+	return handleStats(ctx, c.tr, c.id, opts...)
+}
+
+// StatsStream streams the resource usage of the container, as sampled by the daemon about once per second.
+//
+// The request is made when the iterator is used, and the stream ends when the iteration stops, the container
+// exits, or the context is cancelled. Any error ends the iteration.
+// The size of each sample is limited by httputil.WithItemLimit.
+func (s *Service) StatsStream(ctx context.Context, name string, opts ...StatsOption) iter.Seq2[containerapi.Stats, error] {
+	return handleStatsStream(ctx, s.tr, name, opts...)
+}
+
+func handleStatsStream(ctx context.Context, tr transport.Doer, name string, opts ...StatsOption) iter.Seq2[containerapi.Stats, error] {
+	var cfg StatsConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	return func(yield func(containerapi.Stats, error) bool) {
+		if cfg.OneShot {
+			yield(containerapi.Stats{}, errdefs.Invalid("one-shot is not supported when streaming stats"))
+			return
+		}
+
+		ctx := httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+		resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+			return tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+name+"/stats"), withStatsConfig(true, cfg))
+		})
+		if err != nil {
+			yield(containerapi.Stats{}, errdefs.Wrap(err, "error getting container stats"))
+			return
+		}
+		defer resp.Body.Close()
+
+		for stats, err := range httputil.DecodeStream[containerapi.Stats](ctx, resp.Body) {
+			if err != nil {
+				yield(stats, errdefs.Wrap(err, "error decoding container stats"))
+				return
+			}
+			if !yield(stats, nil) {
+				return
+			}
+		}
+	}
+}
+
+// StatsStream streams the resource usage of the container, see `Service.StatsStream`.
+func (c *Container) StatsStream(ctx context.Context, opts ...StatsOption) iter.Seq2[containerapi.Stats, error] {
+	return handleStatsStream(ctx, c.tr, c.id, opts...)
+}
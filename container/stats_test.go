@@ -0,0 +1,50 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	_, err := s.Stats(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+	assert.NilError(t, c.Start(ctx))
+
+	stats, err := c.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stats.ID, c.ID()))
+	assert.Check(t, !stats.Read.IsZero())
+
+	var samples int
+	for stats, err := range c.StatsStream(ctx) {
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(stats.ID, c.ID()))
+		samples++
+		if samples == 2 {
+			break
+		}
+	}
+	assert.Check(t, cmp.Equal(samples, 2))
+
+	var errs []error
+	for _, err := range c.StatsStream(ctx, WithStatsOneShot) {
+		errs = append(errs, err)
+	}
+	assert.Assert(t, cmp.Len(errs, 1))
+	assert.Check(t, errdefs.IsInvalid(errs[0]), errs[0])
+}
@@ -14,7 +14,7 @@ func TestStop(t *testing.T) {
 
 	s, ctx := newTestService(t, context.Background())
 
-	c := s.NewContainer(ctx, "notexist"+testutils.GenerateRandomString())
+	c := s.NewContainer(ctx, "notexist"+testutils.RandomString(t))
 	err := c.Stop(ctx)
 	assert.Assert(t, errdefs.IsNotFound(err), err)
 
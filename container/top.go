@@ -0,0 +1,60 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Top lists the processes running in the container.
+// The psArgs are passed to ps, which defaults to "-ef". They are not supported on Windows.
+// An errdefs.Conflict error is returned if the container is not running.
+//
+// The response is not limited by the response limit set in the context, unless one is set explicitly, since the
+// process list can be arbitrarily large.
+func (s *Service) Top(ctx context.Context, name string, psArgs ...string) (containerapi.Top, error) {
+	return handleTop(ctx, s.tr, name, psArgs...)
+}
+
+func handleTop(ctx context.Context, tr transport.Doer, name string, psArgs ...string) (containerapi.Top, error) {
+	var top containerapi.Top
+
+	withArgs := func(req *http.Request) error {
+		if len(psArgs) > 0 {
+			q := req.URL.Query()
+			q.Set("ps_args", strings.Join(psArgs, " "))
+			req.URL.RawQuery = q.Encode()
+		}
+		return nil
+	}
+
+	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodGet, version.Join(ctx, "/containers/"+name+"/top"), withArgs)
+	})
+	if err != nil {
+		return top, errdefs.Wrap(asConflict(err, "is not running"), "error listing container processes")
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&top); err != nil {
+		return top, errdefs.Wrap(err, "error decoding container processes")
+	}
+	return top, nil
+}
+
+// Top lists the processes running in the container.
+// The psArgs are passed to ps, which defaults to "-ef". They are not supported on Windows.
+// An errdefs.Conflict error is returned if the container is not running.
+//
+// The response is not limited by the response limit set in the context, unless one is set explicitly.
+func (c *Container) Top(ctx context.Context, psArgs ...string) (containerapi.Top, error) {
+	return handleTop(ctx, c.tr, c.id, psArgs...)
+}
@@ -0,0 +1,77 @@
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTop(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	_, err := s.Top(ctx, "notexist"+testutils.RandomString(t))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+
+	_, err = c.Top(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+
+	top, err := c.Top(ctx)
+	assert.NilError(t, err)
+	pids, err := top.PIDs()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(pids, []int{inspect.State.Pid}))
+	cmds, ok := top.Commands()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(cmds, []string{"top"}))
+
+	top, err = s.Top(ctx, c.ID(), "aux")
+	assert.NilError(t, err)
+	users, ok := top.Users()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(users, []string{"root"}))
+}
+
+func TestTopLarge(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	// The process list is larger than the default response limit.
+	const n = 400
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("/bin/sh", "-c", "for i in $(seq 400); do sleep 1000 & done; wait"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+	assert.NilError(t, c.Start(ctx))
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		top, err := c.Top(ctx)
+		assert.NilError(t, err)
+		if len(top.Processes) > n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected more than %d processes, got %d", n, len(top.Processes))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// UpdateOption is used as functional arguments to container update
+// UpdateOptions configure an UpdateConfig
+type UpdateOption func(*UpdateConfig)
+
+// UpdateConfig holds the changes to make to a container.
+// Resources which are left at their zero value are not changed, neither is the restart policy when its name is empty.
+type UpdateConfig struct {
+	containerapi.Resources
+	RestartPolicy containerapi.RestartPolicy
+}
+
+// WithUpdateResources sets the resources to update.
+func WithUpdateResources(r containerapi.Resources) UpdateOption {
+	return func(cfg *UpdateConfig) {
+		cfg.Resources = r
+	}
+}
+
+// WithUpdateRestartPolicy sets the new restart policy of the container.
+func WithUpdateRestartPolicy(p containerapi.RestartPolicy) UpdateOption {
+	return func(cfg *UpdateConfig) {
+		cfg.RestartPolicy = p
+	}
+}
+
+type containerUpdateResponse struct {
+	Warnings []string
+}
+
+// Update changes the resource limits and the restart policy of the container.
+// Running containers are updated in place.
+//
+// The warnings returned by the daemon, such as for limits which are not supported by the host, are returned.
+func (s *Service) Update(ctx context.Context, name string, opts ...UpdateOption) ([]string, error) {
+	return handleUpdate(ctx, s.tr, name, opts...)
+}
+
+func handleUpdate(ctx context.Context, tr transport.Doer, name string, opts ...UpdateOption) ([]string, error) {
+	var cfg UpdateConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+name+"/update"), version.Require(version.ContainerUpdate), httputil.WithJSONBody(cfg))
+	})
+	if err != nil {
+		return nil, errdefs.Wrap(err, "error updating container")
+	}
+	defer resp.Body.Close()
+
+	var r containerUpdateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, errdefs.Wrap(err, "error decoding update response")
+	}
+	return r.Warnings, nil
+}
+
+// Update changes the resource limits and the restart policy of the container.
+// Running containers are updated in place.
+//
+// The warnings returned by the daemon, such as for limits which are not supported by the host, are returned.
+func (c *Container) Update(ctx context.Context, opts ...UpdateOption) ([]string, error) {
+	return handleUpdate(ctx, c.tr, c.id, opts...)
+}
@@ -0,0 +1,57 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/testutils"
+	"github.com/cpuguy83/go-docker/version"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestService(t, context.Background())
+
+	_, err := s.Update(ctx, "notexist"+testutils.RandomString(t), WithUpdateResources(containerapi.Resources{Memory: 64 << 20}))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest", WithCreateCmd("top"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.Check(t, s.Remove(ctx, c.ID(), WithRemoveForce))
+	}()
+	assert.NilError(t, c.Start(ctx))
+
+	_, err = c.Update(ctx,
+		WithUpdateResources(containerapi.Resources{Memory: 64 << 20, MemorySwap: 128 << 20}),
+		WithUpdateRestartPolicy(containerapi.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}),
+	)
+	assert.NilError(t, err)
+
+	// Zero values are left unchanged
+	_, err = s.Update(ctx, c.ID(), func(cfg *UpdateConfig) {
+		cfg.CPUShares = 512
+	})
+	assert.NilError(t, err)
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.State.Running)
+	assert.Check(t, cmp.Equal(inspect.HostConfig.Memory, int64(64<<20)))
+	assert.Check(t, cmp.Equal(inspect.HostConfig.CPUShares, int64(512)))
+	assert.Check(t, cmp.DeepEqual(inspect.HostConfig.RestartPolicy, containerapi.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}))
+
+	_, err = c.Update(ctx, WithUpdateResources(containerapi.Resources{Memory: 1 << 20}))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+
+	_, err = c.Update(ctx, WithUpdateRestartPolicy(containerapi.RestartPolicy{Name: "sometimes"}))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+
+	_, err = c.Update(version.WithAPIVersion(ctx, "1.21"), WithUpdateResources(containerapi.Resources{Memory: 64 << 20}))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"sync"
 
-	"github.com/cpuguy83/go-docker/errdefs"
 	"github.com/cpuguy83/go-docker/httputil"
 	"github.com/cpuguy83/go-docker/version"
 )
@@ -82,17 +81,18 @@ func (c *Container) Wait(ctx context.Context, opts ...WaitOption) (ExitStatus, e
 		o(&cfg)
 	}
 
-	if version.LessThan(version.APIVersion(ctx), "1.30") {
-		// Before 1.30:
-		//   - wait condition is not supported
-		//   - The API blocks until wait is completed
-		//
-		// On 2nd point above, this would require running the request in a goroutine.
-		// Not difficult but for now just return an error.
-		return nil, errdefs.NotImplemented("container wait requires API version 1.30 or higher")
+	// Before 1.30:
+	//   - wait condition is not supported
+	//   - The API blocks until wait is completed
+	//
+	// On 2nd point above, this would require running the request in a goroutine.
+	// Not difficult but for now just return an error.
+	features := []version.Feature{version.ContainerWait}
+	if cfg.Condition != "" {
+		features = append(features, version.WaitCondition)
 	}
 	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return c.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+c.id+"/wait"), func(req *http.Request) error {
+		return c.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/containers/"+c.id+"/wait"), version.Require(features...), func(req *http.Request) error {
 			q := req.URL.Query()
 			q.Add("condition", string(cfg.Condition))
 			req.URL.RawQuery = q.Encode()
@@ -16,7 +16,7 @@ func TestWait(t *testing.T) {
 
 	s, ctx := newTestService(t, context.Background())
 
-	c := s.NewContainer(ctx, "notexist"+testutils.GenerateRandomString())
+	c := s.NewContainer(ctx, "notexist"+testutils.RandomString(t))
 	_, err := c.Wait(ctx)
 	assert.Assert(t, errdefs.IsNotFound(err), err)
 
@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/system"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// defaultsDoer applies the client level defaults to requests that do not set them through their context.
+type defaultsDoer struct {
+	d       transport.Doer
+	cfg     NewClientConfig
+	headers map[string]string
+
+	// sys negotiates the API version, so the result is available from the system service of the client.
+	sys *system.Service
+
+	mu         sync.Mutex
+	negotiated string
+}
+
+func newDefaultsDoer(d transport.Doer, cfg NewClientConfig, configHeaders map[string]string) *defaultsDoer {
+	// Like the docker CLI, headers from the config file are overridden by the ones set on the client.
+	headers := make(map[string]string, len(configHeaders)+len(cfg.Headers)+1)
+	for k, v := range configHeaders {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range cfg.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	if cfg.UserAgent != "" {
+		headers["User-Agent"] = cfg.UserAgent
+	}
+	dd := &defaultsDoer{d: d, cfg: cfg, headers: headers}
+	dd.sys = system.NewService(dd)
+	return dd
+}
+
+// withHeaders is a RequestOpt which adds the default headers, unless the request already has them.
+func (d *defaultsDoer) withHeaders(req *http.Request) error {
+	if len(d.headers) == 0 {
+		return nil
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for k, v := range d.headers {
+		if _, ok := req.Header[k]; !ok {
+			req.Header.Set(k, v)
+		}
+	}
+	return nil
+}
+
+// apiVersion returns the API version to use for requests which do not have one.
+func (d *defaultsDoer) apiVersion(ctx context.Context) (string, error) {
+	if !d.cfg.NegotiateAPIVersion {
+		return d.cfg.APIVersion, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.negotiated != "" {
+		return d.negotiated, nil
+	}
+
+	// The configured version, if any, is the maximum version the client may use.
+	ctx, err := d.sys.NegotiateAPIVersion(version.WithAPIVersion(ctx, d.cfg.APIVersion))
+	if err != nil {
+		return "", errdefs.Wrap(err, "error negotiating API version")
+	}
+	d.negotiated = version.APIVersion(ctx)
+	return d.negotiated, nil
+}
+
+// uri adds the default API version to the uri if it does not have one already.
+func (d *defaultsDoer) uri(ctx context.Context, uri string) (string, error) {
+	if version.FromPath(uri) != "" {
+		return uri, nil
+	}
+	if d.cfg.NegotiateAPIVersion && uri == "/version" {
+		// Used for negotiation, which must not wait on itself.
+		return uri, nil
+	}
+	v, err := d.apiVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return version.Join(version.WithAPIVersion(ctx, v), uri), nil
+}
+
+func (d *defaultsDoer) Do(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (*http.Response, error) {
+	uri, err := d.uri(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.ResponseLimit != 0 {
+		// Only applied by httputil.LimitResponse, so responses which are streamed are not limited.
+		ctx = httputil.WithDefaultResponseLimit(ctx, d.cfg.ResponseLimit)
+	}
+	return d.d.Do(ctx, method, uri, append(opts[:len(opts):len(opts)], d.withHeaders)...)
+}
+
+func (d *defaultsDoer) DoRaw(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (net.Conn, error) {
+	uri, err := d.uri(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return d.d.DoRaw(ctx, method, uri, append(opts[:len(opts):len(opts)], d.withHeaders)...)
+}
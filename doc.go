@@ -34,7 +34,7 @@ Once you have a transport you can create a client:
 	}
 	client := NewClient(WithTransport(tr))
 
-Or if you don’t provide a transport, the default for the platform will be used.
+Or if you don’t provide a transport, one will be created from the DOCKER_HOST, DOCKER_TLS_VERIFY, and DOCKER_CERT_PATH environment variables (see transport.FromEnvContext) when the first request is made, finding the daemon if DOCKER_HOST is not set.
 
 Perform actions on a container:
 
@@ -22,6 +22,11 @@ func (w *wrapped) Unwrap() error {
 	return w.cause
 }
 
+// As allows errors.As to find errors in the wrapped error, which is not returned by Unwrap.
+func (w *wrapped) As(target interface{}) bool {
+	return errors.As(w.e, target)
+}
+
 func (w *wrapped) Is(target error) bool {
 	if errors.Is(w.e, target) {
 		return true
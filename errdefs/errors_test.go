@@ -26,6 +26,7 @@ func TestErrors(t *testing.T) {
 		"not modified":    {ErrNotModified, NotModified, NotModifiedf, IsNotModified, AsNotModified},
 		"unauthorized":    {ErrUnauthorized, Unauthorized, Unauthorizedf, IsUnauthorized, AsUnauthorized},
 		"unavailable":     {ErrUnavailable, Unavailable, Unavailablef, IsUnavailable, AsUnavailable},
+		"system":          {ErrSystem, System, Systemf, IsSystem, AsSystem},
 	}
 
 	for name, tc := range cases {
@@ -57,13 +58,25 @@ func testError(tc testCase) func(t *testing.T) {
 			t.Fatalf("%s did not return true after creating error with %s", getFunctionName(tc.Is), getFunctionName(tc.Newf))
 		}
 
-		e = errors.New(t.Name())
-		e = tc.As(e)
+		orig := &testErr{t.Name()}
+		e = tc.As(orig)
 		if !errors.Is(e, tc.Err) {
 			t.Fatalf("expected error to be wrapped by %v", tc.Err)
 		}
+		var target *testErr
+		if !errors.As(e, &target) || target != orig {
+			t.Fatalf("expected to find the original error with errors.As after wrapping with %s", getFunctionName(tc.As))
+		}
 		if !tc.Is(e) {
 			t.Fatalf("%s did not return true after wrapping error with %s", getFunctionName(tc.Is), getFunctionName(tc.As))
 		}
 	}
 }
+
+type testErr struct {
+	msg string
+}
+
+func (e *testErr) Error() string {
+	return e.msg
+}
@@ -0,0 +1,29 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSystem is for unexpected errors in the daemon, i.e. 5xx responses which are not covered by another class.
+var ErrSystem = errors.New("system error")
+
+// System makes an ErrSystem from the provided error message
+func System(msg string) error {
+	return fmt.Errorf("%w: %s", ErrSystem, msg)
+}
+
+// Systemf makes an ErrSystem from the provided error format and args
+func Systemf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrSystem, fmt.Sprintf(format, args...))
+}
+
+// IsSystem determines if the passed in error is of type ErrSystem
+func IsSystem(err error) bool {
+	return errors.Is(err, ErrSystem)
+}
+
+// AsSystem returns a wrapped error which will return true for IsSystem
+func AsSystem(err error) error {
+	return as(err, ErrSystem)
+}
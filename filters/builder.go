@@ -0,0 +1,84 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Label matches objects with the label.
+// The label is either a key, which must be set, or "key=value".
+func Label(label string) KeyValuePair {
+	return Arg("label", label)
+}
+
+// NotLabel matches objects which do not have the label.
+// The label is either a key or "key=value".
+func NotLabel(label string) KeyValuePair {
+	return Arg("label!", label)
+}
+
+// Status matches containers in the state, such as "running" or "exited".
+func Status(status string) KeyValuePair {
+	return Arg("status", status)
+}
+
+// Name matches objects by name.
+func Name(name string) KeyValuePair {
+	return Arg("name", name)
+}
+
+// ID matches objects by ID.
+func ID(id string) KeyValuePair {
+	return Arg("id", id)
+}
+
+// Ancestor matches containers created from the image or one of its descendants.
+func Ancestor(image string) KeyValuePair {
+	return Arg("ancestor", image)
+}
+
+// Before matches objects created before the referenced object.
+func Before(ref string) KeyValuePair {
+	return Arg("before", ref)
+}
+
+// Since matches objects created after the referenced object.
+func Since(ref string) KeyValuePair {
+	return Arg("since", ref)
+}
+
+// Reference matches images by reference, such as "busybox" or "busybox:latest".
+func Reference(ref string) KeyValuePair {
+	return Arg("reference", ref)
+}
+
+// Dangling matches images which are, or are not, dangling.
+func Dangling(dangling bool) KeyValuePair {
+	return Arg("dangling", strconv.FormatBool(dangling))
+}
+
+// Until matches objects created before t.
+func Until(t time.Time) KeyValuePair {
+	return Arg("until", fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond()))
+}
+
+// Type matches events by the type of object, such as "container" or "image".
+func Type(typ string) KeyValuePair {
+	return Arg("type", typ)
+}
+
+// Event matches events by action, such as "start" or "die".
+func Event(action string) KeyValuePair {
+	return Arg("event", action)
+}
+
+// Container matches events for the container name or ID.
+func Container(container string) KeyValuePair {
+	return Arg("container", container)
+}
+
+// Image matches events for the image name or ID.
+func Image(image string) KeyValuePair {
+	return Arg("image", image)
+}
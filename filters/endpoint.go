@@ -0,0 +1,105 @@
+package filters
+
+import (
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+// Endpoint is an Engine API endpoint which accepts filters.
+type Endpoint struct {
+	// Name is used in error messages.
+	Name string
+	// Keys are the filter keys accepted by the endpoint.
+	Keys []string
+}
+
+// The endpoints supported by this module and the filters they accept.
+// See https://docs.docker.com/engine/api/v1.41/ for the meaning of each filter.
+var (
+	ContainerList = Endpoint{
+		Name: "container list",
+		Keys: []string{"ancestor", "before", "expose", "exited", "health", "id", "isolation", "is-task", "label", "name", "network", "publish", "since", "status", "volume"},
+	}
+	ContainerPrune = Endpoint{
+		Name: "container prune",
+		Keys: []string{"label", "label!", "until"},
+	}
+	ImageList = Endpoint{
+		Name: "image list",
+		Keys: []string{"before", "dangling", "label", "reference", "since", "until"},
+	}
+	ImagePrune = Endpoint{
+		Name: "image prune",
+		Keys: []string{"dangling", "label", "label!", "until"},
+	}
+	Events = Endpoint{
+		Name: "events",
+		Keys: []string{"config", "container", "daemon", "event", "image", "label", "network", "node", "plugin", "scope", "secret", "service", "type", "volume"},
+	}
+)
+
+// Validate returns an errdefs.Invalid error if any of the filters is not accepted by the endpoint.
+func (e Endpoint) Validate(a Args) error {
+	for _, k := range a.Keys() {
+		var ok bool
+		for _, accepted := range e.Keys {
+			if k == accepted {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return errdefs.Invalidf("invalid filter %q for %s", k, e.Name)
+		}
+	}
+	return nil
+}
+
+// legacyVersion is the first API version which accepts the map form of filters.
+const legacyVersion = "1.22"
+
+// Encode validates the filters for the endpoint and encodes them for the API version.
+// API versions before 1.22 get the legacy array form, all others, including an empty version, get the map form.
+func (e Endpoint) Encode(a Args, apiVersion string) (string, error) {
+	if err := e.Validate(a); err != nil {
+		return "", err
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if version.LessThan(apiVersion, legacyVersion) {
+		data, err = a.legacyJSON()
+	} else {
+		data, err = a.MarshalJSON()
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// WithFilters is a transport.RequestOpt which sets the "filters" query parameter of the request, if there are any filters.
+//
+// The encoding is picked from the API version in the request path, which is the version negotiated by the client
+// when it does not come from the request context.
+func (e Endpoint) WithFilters(a Args) transport.RequestOpt {
+	return func(req *http.Request) error {
+		if a.Len() == 0 {
+			return nil
+		}
+
+		s, err := e.Encode(a, version.FromPath(req.URL.Path))
+		if err != nil {
+			return err
+		}
+		q := req.URL.Query()
+		q.Set("filters", s)
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
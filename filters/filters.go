@@ -0,0 +1,158 @@
+// Package filters provides a typed builder for the "filters" query parameter accepted by the list, prune and
+// events endpoints of the Engine API.
+//
+//	args := filters.NewArgs(filters.Label("com.example=foo"), filters.Status("running"))
+package filters
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// KeyValuePair is a single filter.
+type KeyValuePair struct {
+	Key   string
+	Value string
+}
+
+// Arg creates a filter for an arbitrary key.
+// Prefer the typed helpers, such as Label, when one exists for the key.
+func Arg(key, value string) KeyValuePair {
+	return KeyValuePair{Key: key, Value: value}
+}
+
+// Args is a set of filters.
+// A value matches a key if it matches any of the values for that key, and all keys must match.
+//
+// The zero value is an empty set of filters ready to use.
+type Args struct {
+	fields map[string]map[string]bool
+}
+
+// NewArgs creates a set of filters from the passed in pairs.
+func NewArgs(pairs ...KeyValuePair) Args {
+	var a Args
+	for _, p := range pairs {
+		a.Add(p.Key, p.Value)
+	}
+	return a
+}
+
+// Add adds a value to the filter for key.
+func (a *Args) Add(key, value string) {
+	if a.fields == nil {
+		a.fields = make(map[string]map[string]bool)
+	}
+	if _, ok := a.fields[key]; !ok {
+		a.fields[key] = make(map[string]bool)
+	}
+	a.fields[key][value] = true
+}
+
+// Del removes a value from the filter for key.
+func (a *Args) Del(key, value string) {
+	values, ok := a.fields[key]
+	if !ok {
+		return
+	}
+	delete(values, value)
+	if len(values) == 0 {
+		delete(a.fields, key)
+	}
+}
+
+// Get returns the sorted values for key.
+func (a Args) Get(key string) []string {
+	values := a.fields[key]
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for v := range values {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Keys returns the sorted keys which have filters.
+func (a Args) Keys() []string {
+	keys := make([]string, 0, len(a.fields))
+	for k := range a.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Contains returns true if there are filters for key.
+func (a Args) Contains(key string) bool {
+	return len(a.fields[key]) > 0
+}
+
+// Len returns the number of keys which have filters.
+func (a Args) Len() int {
+	return len(a.fields)
+}
+
+// Clone returns a copy of the filters.
+func (a Args) Clone() Args {
+	var c Args
+	for k, values := range a.fields {
+		for v := range values {
+			c.Add(k, v)
+		}
+	}
+	return c
+}
+
+// MarshalJSON implements json.Marshaler using the map form of filters: `{"key":{"value":true}}`.
+func (a Args) MarshalJSON() ([]byte, error) {
+	if a.fields == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a.fields)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Both the map form and the legacy array form (`{"key":["value"]}`) are accepted.
+func (a *Args) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errdefs.Invalidf("invalid filters: %v", err)
+	}
+
+	var out Args
+	for k, v := range raw {
+		var values []string
+		if err := json.Unmarshal(v, &values); err == nil {
+			for _, value := range values {
+				out.Add(k, value)
+			}
+			continue
+		}
+
+		var m map[string]bool
+		if err := json.Unmarshal(v, &m); err != nil {
+			return errdefs.Invalidf("invalid filter value for %q: %v", k, err)
+		}
+		for value, ok := range m {
+			if ok {
+				out.Add(k, value)
+			}
+		}
+	}
+	*a = out
+	return nil
+}
+
+// legacyJSON encodes the filters using the array form used by API versions before 1.22.
+func (a Args) legacyJSON() ([]byte, error) {
+	m := make(map[string][]string, len(a.fields))
+	for k := range a.fields {
+		m[k] = a.Get(k)
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,98 @@
+package filters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestArgs(t *testing.T) {
+	var a Args
+	assert.Check(t, cmp.Equal(a.Len(), 0))
+
+	a = NewArgs(Label("b"), Label("a=1"), Status("running"))
+	a.Add("status", "exited")
+	assert.Check(t, cmp.DeepEqual(a.Keys(), []string{"label", "status"}))
+	assert.Check(t, cmp.DeepEqual(a.Get("label"), []string{"a=1", "b"}))
+	assert.Check(t, a.Contains("status"))
+	assert.Check(t, !a.Contains("name"))
+
+	c := a.Clone()
+	a.Del("status", "running")
+	a.Del("status", "exited")
+	assert.Check(t, !a.Contains("status"))
+	assert.Check(t, cmp.DeepEqual(c.Get("status"), []string{"exited", "running"}))
+
+	assert.Check(t, cmp.Equal(Until(time.Unix(10, 5)).Value, "10.000000005"))
+	assert.Check(t, cmp.Equal(Dangling(true).Value, "true"))
+}
+
+func TestJSON(t *testing.T) {
+	a := NewArgs(Label("a=1"), Label("b"))
+
+	data, err := json.Marshal(a)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), `{"label":{"a=1":true,"b":true}}`))
+
+	data, err = json.Marshal(Args{})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), `{}`))
+
+	for _, s := range []string{`{"label":{"a=1":true,"b":true,"c":false}}`, `{"label":["a=1","b"]}`} {
+		var decoded Args
+		assert.NilError(t, json.Unmarshal([]byte(s), &decoded), s)
+		assert.Check(t, cmp.DeepEqual(decoded.Get("label"), []string{"a=1", "b"}), s)
+	}
+
+	var decoded Args
+	err = json.Unmarshal([]byte(`{"label":"a"}`), &decoded)
+	assert.Check(t, errdefs.IsInvalid(err), err)
+}
+
+func TestEndpoint(t *testing.T) {
+	a := NewArgs(Label("a=1"), Status("running"))
+
+	s, err := ContainerList.Encode(a, "1.41")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(s, `{"label":{"a=1":true},"status":{"running":true}}`))
+
+	s, err = ContainerList.Encode(a, "")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(s, `{"label":{"a=1":true},"status":{"running":true}}`))
+
+	s, err = ContainerList.Encode(a, "1.21")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(s, `{"label":["a=1"],"status":["running"]}`))
+
+	_, err = ImagePrune.Encode(a, "1.41")
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	assert.Check(t, cmp.ErrorContains(err, `invalid filter "status" for image prune`))
+}
+
+func TestWithFilters(t *testing.T) {
+	a := NewArgs(Reference("busybox"))
+
+	for path, expected := range map[string]string{
+		"/v1.41/images/json": `{"reference":{"busybox":true}}`,
+		"/v1.20/images/json": `{"reference":["busybox"]}`,
+		"/images/json":       `{"reference":{"busybox":true}}`,
+	} {
+		req := &http.Request{URL: &url.URL{Path: path, RawQuery: "all=true"}}
+		assert.NilError(t, ImageList.WithFilters(a)(req), path)
+		assert.Check(t, cmp.Equal(req.URL.Query().Get("filters"), expected), path)
+		assert.Check(t, cmp.Equal(req.URL.Query().Get("all"), "true"), path)
+	}
+
+	req := &http.Request{URL: &url.URL{Path: "/v1.41/images/json"}}
+	assert.NilError(t, ImageList.WithFilters(Args{})(req))
+	assert.Check(t, !req.URL.Query().Has("filters"))
+
+	err := Events.WithFilters(a)(req)
+	assert.Check(t, errdefs.IsInvalid(err), err)
+}
@@ -7,10 +7,9 @@ toolchain go1.24.3
 require (
 	github.com/Microsoft/go-winio v0.6.2
 	github.com/opencontainers/go-digest v1.0.0
+	golang.org/x/crypto v0.40.0
+	golang.org/x/sys v0.34.0
 	gotest.tools/v3 v3.5.2
 )
 
-require (
-	github.com/google/go-cmp v0.5.9 // indirect
-	golang.org/x/sys v0.34.0 // indirect
-)
+require github.com/google/go-cmp v0.5.9 // indirect
@@ -9,6 +9,9 @@ import (
 var (
 	// The default limit used by the client when reading response bodies.
 	DefaultResponseLimit int64 = 16 * 1024
+
+	// The default limit used by the client for each item of a streamed list response.
+	DefaultItemLimit int64 = 1024 * 1024
 )
 
 const (
@@ -33,9 +36,19 @@ func WithResponseLimitIfEmpty(ctx context.Context, limit int64) context.Context
 	return WithResponseLimit(ctx, limit)
 }
 
+type defaultResponseLimit struct{}
+
+// WithDefaultResponseLimit sets the limit used by `LimitResponse` when a limit is not set with `WithResponseLimit`.
+// This allows a transport.Doer to set the default limit for the responses it returns, by setting it in the context of
+// the requests it makes.
+func WithDefaultResponseLimit(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, defaultResponseLimit{}, limit)
+}
+
 // LimitResponse limits the size of the response body.
 // This is used throughout the client to prevent a bad response from consuming too much memory.
-// If a response limit is not set in the context, DefaultResponseLimit will be used.
+// If a response limit is not set in the context, the limit set with `WithDefaultResponseLimit` in the context of the
+// request is used, if any, otherwise DefaultResponseLimit is used.
 //
 // The value used is taken from the passed in context.
 // Set this value by using:
@@ -43,10 +56,16 @@ func WithResponseLimitIfEmpty(ctx context.Context, limit int64) context.Context
 // 	ctx = WithResponseLimit(ctx, limit)
 func LimitResponse(ctx context.Context, resp *http.Response) {
 	limit := DefaultResponseLimit
-	v := ctx.Value(responseLimit{})
-	if v != nil {
+	if v := ctx.Value(responseLimit{}); v != nil {
+		limit = v.(int64)
+	} else if v := ctx.Value(defaultResponseLimit{}); v != nil {
 		limit = v.(int64)
+	} else if resp.Request != nil {
+		if v := resp.Request.Context().Value(defaultResponseLimit{}); v != nil {
+			limit = v.(int64)
+		}
 	}
+
 	if limit == UnlimitedResponseLimit {
 		return
 	}
@@ -54,6 +73,25 @@ func LimitResponse(ctx context.Context, resp *http.Response) {
 	resp.Body = &wrapBody{limited, resp.Body}
 }
 
+type itemLimit struct{}
+
+// WithItemLimit sets a limit for the max size of each item decoded from a streamed list response, such as the one
+// returned by `container.Service.ListIter`.
+// Since the items are decoded one at a time, this bounds the memory used by the client regardless of the number of
+// items in the response.
+func WithItemLimit(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, itemLimit{}, limit)
+}
+
+// ItemLimit gets the item limit set with `WithItemLimit`, or DefaultItemLimit if one is not set.
+func ItemLimit(ctx context.Context) int64 {
+	v := ctx.Value(itemLimit{})
+	if v == nil {
+		return DefaultItemLimit
+	}
+	return v.(int64)
+}
+
 type wrapBody struct {
 	io.Reader
 	io.Closer
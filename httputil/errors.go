@@ -6,38 +6,85 @@ import (
 	"net/http"
 
 	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/version"
 )
 
 type errorResponse struct {
 	Message string `json:"message"`
 }
 
-func (e errorResponse) Error() string {
-	return e.Message
+// APIError is an error response from the daemon.
+// Errors returned by CheckResponseError can be converted to an APIError with errors.As.
+type APIError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Method and Path are the method and path of the request.
+	// These are empty if the response does not reference the request it was for.
+	Method string
+	Path   string
+	// APIVersion is the API version the request was made with.
+	// If the request path does not include an API version, this is the version reported by the daemon, which is the
+	// version the daemon uses for such requests.
+	APIVersion string
+	// Message is the error message sent by the daemon.
+	Message string
+	// Header holds the response headers.
+	Header http.Header
+	// Err is the error decoding the response body, if it could not be decoded.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return fmt.Sprintf("%s: error in response, status code: %d", msg, e.StatusCode)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
 }
 
 // CheckResponseError checks the http response for standard error codes.
 //
-// For the most part this should return error implemented from the `errdefs` package
+// For the most part this should return error implemented from the `errdefs` package.
+// The returned error holds an *APIError, which can be retrieved with errors.As.
 func CheckResponseError(resp *http.Response) error {
 	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
 		return nil
 	}
 
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		APIVersion: resp.Header.Get("API-Version"),
+	}
+	if req := resp.Request; req != nil {
+		apiErr.Method = req.Method
+		if req.URL != nil {
+			apiErr.Path = req.URL.Path
+			if v := version.FromPath(req.URL.Path); v != "" {
+				apiErr.APIVersion = v
+			}
+		}
+	}
+
 	var e errorResponse
 	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
 		resp.Body.Close()
-		return errdefs.Wrap(fromStatusCode(err, resp.StatusCode), "error unmarshaling server error response")
+		apiErr.Err = err
+		return errdefs.Wrap(fromStatusCode(apiErr, resp.StatusCode), "error unmarshaling server error response")
 	}
+	apiErr.Message = e.Message
 
-	return fromStatusCode(&e, resp.StatusCode)
+	return fromStatusCode(apiErr, resp.StatusCode)
 }
 
 func fromStatusCode(err error, statusCode int) error {
 	if err == nil {
 		return err
 	}
-	err = fmt.Errorf("%w: error in response, status code: %d", err, statusCode)
 	switch statusCode {
 	case http.StatusNotFound:
 		err = errdefs.AsNotFound(err)
@@ -59,6 +106,9 @@ func fromStatusCode(err error, statusCode int) error {
 		if statusCode >= 400 && statusCode < 500 {
 			err = errdefs.AsInvalid(err)
 		}
+		if statusCode >= 500 {
+			err = errdefs.AsSystem(err)
+		}
 	}
 	return err
 }
@@ -0,0 +1,71 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestCheckResponseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Api-Version", "1.41")
+		switch req.URL.Path {
+		case "/v1.40/containers/foo/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No such container: foo"}`))
+		case "/containers/json":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message": "something broke"}`))
+		case "/_ping":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`not json`))
+		default:
+			w.Write([]byte("OK"))
+		}
+	}))
+	defer srv.Close()
+
+	check := func(method, path string) error {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		assert.NilError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+		return CheckResponseError(resp)
+	}
+
+	assert.NilError(t, check(http.MethodGet, "/version"))
+
+	err := check(http.MethodGet, "/v1.40/containers/foo/json")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+	var apiErr *APIError
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Check(t, cmp.Equal(apiErr.StatusCode, http.StatusNotFound))
+	assert.Check(t, cmp.Equal(apiErr.Method, http.MethodGet))
+	assert.Check(t, cmp.Equal(apiErr.Path, "/v1.40/containers/foo/json"))
+	assert.Check(t, cmp.Equal(apiErr.APIVersion, "1.40"))
+	assert.Check(t, cmp.Equal(apiErr.Message, "No such container: foo"))
+	assert.Check(t, cmp.Equal(apiErr.Header.Get("Content-Type"), "application/json"))
+	assert.Check(t, cmp.ErrorContains(err, "No such container: foo"))
+
+	err = check(http.MethodPost, "/containers/json")
+	assert.Check(t, errdefs.IsSystem(err), err)
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Check(t, cmp.Equal(apiErr.Method, http.MethodPost))
+	assert.Check(t, cmp.Equal(apiErr.APIVersion, "1.41"))
+	assert.Check(t, cmp.Equal(apiErr.Message, "something broke"))
+
+	err = check(http.MethodGet, "/_ping")
+	assert.Check(t, errdefs.IsSystem(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "error unmarshaling server error response"))
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Check(t, cmp.Equal(apiErr.StatusCode, http.StatusBadGateway))
+	assert.Check(t, apiErr.Err != nil)
+}
@@ -0,0 +1,214 @@
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// DecodeArray decodes the JSON array read from r one element at a time.
+//
+// The size of each element is limited by the item limit set in the context (see `WithItemLimit`).
+// Decoding stops at the first error, which is yielded along with the zero value of T.
+func DecodeArray[T any](ctx context.Context, r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		lr := &itemLimitReader{r: r, limit: ItemLimit(ctx)}
+		decodeElements(ctx, json.NewDecoder(lr), lr, yield)
+	}
+}
+
+// decodeElements decodes the array at the current position of dec one element at a time.
+func decodeElements[T any](ctx context.Context, dec *json.Decoder, lr *itemLimitReader, yield func(T, error) bool) {
+	var zero T
+
+	lr.n = 0
+	tok, err := dec.Token()
+	if err != nil {
+		yield(zero, noEOF(err))
+		return
+	}
+	if tok == nil {
+		// null
+		return
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		yield(zero, fmt.Errorf("expected a JSON array, got %v", tok))
+		return
+	}
+
+	for {
+		lr.n = 0
+		if !dec.More() {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			yield(zero, noEOF(err))
+			return
+		}
+		if !yield(v, nil) {
+			return
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		yield(zero, noEOF(err))
+	}
+}
+
+// DecodeObject decodes the JSON object read from r one field at a time, calling fn with the name and value of each
+// field.
+// fn decodes the value with `Field.Decode`, or `DecodeElements` when the value is an array that may be large. The value
+// of a field which fn does not decode is skipped.
+//
+// The size of each value, or of each element of an array, is limited by the item limit set in the context (see
+// `WithItemLimit`).
+func DecodeObject(ctx context.Context, r io.Reader, fn func(name string, f *Field) error) error {
+	lr := &itemLimitReader{r: r, limit: ItemLimit(ctx)}
+	dec := json.NewDecoder(lr)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return noEOF(err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("expected a JSON object, got %v", tok)
+	}
+
+	for {
+		lr.n = 0
+		if !dec.More() {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return noEOF(err)
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected a field name, got %v", tok)
+		}
+
+		f := &Field{ctx: ctx, dec: dec, lr: lr}
+		if err := fn(name, f); err != nil {
+			return err
+		}
+		if !f.decoded {
+			if err := f.Decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = dec.Token()
+	return noEOF(err)
+}
+
+// Field is the value of a field of an object decoded with `DecodeObject`.
+type Field struct {
+	ctx     context.Context
+	dec     *json.Decoder
+	lr      *itemLimitReader
+	decoded bool
+}
+
+// Decode decodes the value of the field into v.
+func (f *Field) Decode(v any) error {
+	f.decoded = true
+	f.lr.n = 0
+	return noEOF(f.dec.Decode(v))
+}
+
+// DecodeElements decodes the value of the field, which must be an array or null, one element at a time.
+// Decoding stops at the first error, which is yielded along with the zero value of T. If the iteration is stopped
+// early, the rest of the object cannot be decoded, so fn must return an error.
+func DecodeElements[T any](f *Field) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		f.decoded = true
+		decodeElements(f.ctx, f.dec, f.lr, yield)
+	}
+}
+
+// DecodeStream decodes the stream of JSON values read from r, such as the ones sent by streaming endpoints, one
+// value at a time, until the end of the stream.
+//
+// The size of each value is limited by the item limit set in the context (see `WithItemLimit`).
+// Decoding stops at the first error, which is yielded along with the zero value of T.
+func DecodeStream[T any](ctx context.Context, r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		lr := &itemLimitReader{r: r, limit: ItemLimit(ctx)}
+		dec := json.NewDecoder(lr)
+
+		for {
+			lr.n = 0
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				if err == io.EOF {
+					return
+				}
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					err = ctxErr
+				}
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// itemLimitReader limits the number of bytes read for each item.
+// n must be reset before decoding each item.
+//
+// The limit is approximate since the decoder reads ahead: the bytes of an item may have been read while decoding
+// the previous one.
+type itemLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *itemLimitReader) Read(p []byte) (int, error) {
+	if l.limit != UnlimitedResponseLimit {
+		if l.n >= l.limit {
+			return 0, &itemLimitError{limit: l.limit}
+		}
+		if int64(len(p)) > l.limit-l.n {
+			p = p[:l.limit-l.n]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, since the stream must end with the end of the array.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type itemLimitError struct {
+	limit int64
+}
+
+func (e *itemLimitError) Error() string {
+	return fmt.Sprintf("item exceeds the size limit of %d bytes", e.limit)
+}
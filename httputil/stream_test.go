@@ -0,0 +1,154 @@
+package httputil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type testItem struct {
+	Name string
+}
+
+func collect(ctx context.Context, s string) ([]string, error) {
+	var names []string
+	for item, err := range DecodeArray[testItem](ctx, strings.NewReader(s)) {
+		if err != nil {
+			return names, err
+		}
+		names = append(names, item.Name)
+	}
+	return names, nil
+}
+
+func TestDecodeArray(t *testing.T) {
+	ctx := context.Background()
+
+	names, err := collect(ctx, `[{"Name":"a"}, {"Name":"b"}]`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(names, []string{"a", "b"}))
+
+	names, err = collect(ctx, `null`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(names, 0))
+
+	_, err = collect(ctx, `{"Name":"a"}`)
+	assert.Check(t, cmp.ErrorContains(err, "expected a JSON array"))
+
+	names, err = collect(ctx, `[{"Name":"a"}, {"Name":`)
+	assert.Check(t, cmp.ErrorContains(err, "unexpected EOF"))
+	assert.Check(t, cmp.DeepEqual(names, []string{"a"}))
+
+	// Each item is limited, not the whole stream.
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = `{"Name":"` + strings.Repeat("x", 50) + `"}`
+	}
+	names, err = collect(WithItemLimit(ctx, 100), "["+strings.Join(items, ",")+"]")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(names, 100))
+
+	names, err = collect(WithItemLimit(ctx, 100), `[{"Name":"a"}, {"Name":"`+strings.Repeat("x", 200)+`"}]`)
+	assert.Check(t, cmp.ErrorContains(err, "size limit of 100 bytes"))
+	assert.Check(t, cmp.DeepEqual(names, []string{"a"}))
+
+	names, err = collect(WithItemLimit(ctx, UnlimitedResponseLimit), `[{"Name":"`+strings.Repeat("x", 200)+`"}]`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(names, 1))
+
+	// Stopping early
+	var n int
+	for range DecodeArray[testItem](ctx, strings.NewReader(`[{"Name":"a"}, {"Name":"b"}]`)) {
+		n++
+		break
+	}
+	assert.Check(t, cmp.Equal(n, 1))
+}
+
+func TestDecodeStream(t *testing.T) {
+	ctx := context.Background()
+
+	var names []string
+	for item, err := range DecodeStream[testItem](ctx, strings.NewReader("{\"Name\":\"a\"}\n{\"Name\":\"b\"}\n")) {
+		assert.NilError(t, err)
+		names = append(names, item.Name)
+	}
+	assert.Check(t, cmp.DeepEqual(names, []string{"a", "b"}))
+
+	names = nil
+	var err error
+	for item, e := range DecodeStream[testItem](WithItemLimit(ctx, 50), strings.NewReader("{\"Name\":\"a\"}\n{\"Name\":\""+strings.Repeat("x", 100)+"\"}\n")) {
+		if e != nil {
+			err = e
+			break
+		}
+		names = append(names, item.Name)
+	}
+	assert.Check(t, cmp.ErrorContains(err, "size limit of 50 bytes"))
+	assert.Check(t, cmp.DeepEqual(names, []string{"a"}))
+}
+
+type testObject struct {
+	Names []string
+	Count int
+}
+
+func collectObject(ctx context.Context, s string) (testObject, error) {
+	var obj testObject
+	err := DecodeObject(ctx, strings.NewReader(s), func(name string, f *Field) error {
+		switch name {
+		case "Items":
+			for item, err := range DecodeElements[testItem](f) {
+				if err != nil {
+					return err
+				}
+				obj.Names = append(obj.Names, item.Name)
+			}
+		case "Count":
+			return f.Decode(&obj.Count)
+		}
+		return nil
+	})
+	return obj, err
+}
+
+func TestDecodeObject(t *testing.T) {
+	ctx := context.Background()
+
+	obj, err := collectObject(ctx, `{"Items":[{"Name":"a"}, {"Name":"b"}], "Other":{"x":[1,2]}, "Count":2}`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(obj, testObject{Names: []string{"a", "b"}, Count: 2}))
+
+	obj, err = collectObject(ctx, `{"Items":null,"Count":0}`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(obj, testObject{}))
+
+	_, err = collectObject(ctx, `[{"Name":"a"}]`)
+	assert.Check(t, cmp.ErrorContains(err, "expected a JSON object"))
+
+	_, err = collectObject(ctx, `{"Items":{"Name":"a"}}`)
+	assert.Check(t, cmp.ErrorContains(err, "expected a JSON array"))
+
+	obj, err = collectObject(ctx, `{"Items":[{"Name":"a"}], "Count":`)
+	assert.Check(t, cmp.ErrorContains(err, "unexpected EOF"))
+	assert.Check(t, cmp.DeepEqual(obj.Names, []string{"a"}))
+
+	// Each element and field is limited, not the whole object.
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = `{"Name":"` + strings.Repeat("x", 50) + `"}`
+	}
+	obj, err = collectObject(WithItemLimit(ctx, 100), `{"Items":[`+strings.Join(items, ",")+`], "Count":100}`)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(obj.Names, 100))
+	assert.Check(t, cmp.Equal(obj.Count, 100))
+
+	_, err = collectObject(WithItemLimit(ctx, 100), `{"Items":[{"Name":"`+strings.Repeat("x", 200)+`"}]}`)
+	assert.Check(t, cmp.ErrorContains(err, "size limit of 100 bytes"))
+
+	_, err = collectObject(WithItemLimit(ctx, 100), `{"Other":"`+strings.Repeat("x", 200)+`"}`)
+	assert.Check(t, cmp.ErrorContains(err, "size limit of 100 bytes"))
+}
@@ -2,12 +2,12 @@ package image
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"iter"
 	"net/http"
 	"strconv"
 
+	"github.com/cpuguy83/go-docker/filters"
 	"github.com/cpuguy83/go-docker/httputil"
 	"github.com/cpuguy83/go-docker/image/imageapi"
 	"github.com/cpuguy83/go-docker/version"
@@ -16,6 +16,8 @@ import (
 // ListFilter represents filters to process on the image list. See the official
 // docker docs for the meaning of each field
 // https://docs.docker.com/engine/api/v1.41/#operation/ImageList
+//
+// Deprecated: use filters.Args, see ListConfig.Filters.
 type ListFilter struct {
 	Before    []string `json:"before,omitempty"`
 	Dangling  []string `json:"dangling,omitempty"`
@@ -24,19 +26,71 @@ type ListFilter struct {
 	Since     []string `json:"since,omitempty"`
 }
 
+// Args converts the filter to filters.Args.
+func (f ListFilter) Args() filters.Args {
+	var a filters.Args
+	f.addTo(&a)
+	return a
+}
+
+func (f ListFilter) addTo(a *filters.Args) {
+	for key, values := range map[string][]string{
+		"before":    f.Before,
+		"dangling":  f.Dangling,
+		"label":     f.Label,
+		"reference": f.Reference,
+		"since":     f.Since,
+	} {
+		for _, v := range values {
+			a.Add(key, v)
+		}
+	}
+}
+
 // ListConfig holds the options for listing images.
 type ListConfig struct {
 	All     bool
 	Digests bool
-	Filter  ListFilter
+	// Filters is validated against filters.ImageList.
+	Filters filters.Args
+	// Filter is added to Filters.
+	//
+	// Deprecated: use Filters.
+	Filter ListFilter
 }
 
 // ListOption is used as functional arguments to list images.
 // ListOption configure a ListConfig.
 type ListOption func(config *ListConfig)
 
+// WithListFilters sets the filters used to select the images to list, e.g. filters.Reference or filters.Dangling.
+func WithListFilters(f filters.Args) ListOption {
+	return func(cfg *ListConfig) {
+		cfg.Filters = f
+	}
+}
+
 // List lists images.
+// An empty, non-nil, list is returned if there are no images, and nil on error.
+//
+// The list is decoded as a stream, see ListIter.
 func (s *Service) List(ctx context.Context, opts ...ListOption) ([]imageapi.Image, error) {
+	images := []imageapi.Image{}
+	for img, err := range s.ListIter(ctx, opts...) {
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// ListIter lists images and decodes the response one image at a time.
+//
+// The response is not limited by the response limit set in the context, unless one is set explicitly, since the
+// memory used is bounded by the size of each image instead (see httputil.WithItemLimit).
+// The request is made when the iterator is used and any error ends the iteration.
+func (s *Service) ListIter(ctx context.Context, opts ...ListOption) iter.Seq2[imageapi.Image, error] {
 	cfg := ListConfig{}
 	for _, o := range opts {
 		o(&cfg)
@@ -46,36 +100,33 @@ func (s *Service) List(ctx context.Context, opts ...ListOption) ([]imageapi.Imag
 		q := req.URL.Query()
 		q.Add("all", strconv.FormatBool(cfg.All))
 		q.Add("digests", strconv.FormatBool(cfg.Digests))
-		filterJSON, err := json.Marshal(cfg.Filter)
-
-		if err != nil {
-			return err
-		}
-		q.Add("filters", string(filterJSON))
 
 		req.URL.RawQuery = q.Encode()
 		return nil
 	}
 
-	var images []imageapi.Image
-
-	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/images/json"), withListConfig)
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
+	args := cfg.Filters.Clone()
+	cfg.Filter.addTo(&args)
 
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return func(yield func(imageapi.Image, error) bool) {
+		ctx := httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
+		resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+			return s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/images/json"), withListConfig, filters.ImageList.WithFilters(args))
+		})
+		if err != nil {
+			yield(imageapi.Image{}, err)
+			return
+		}
+		defer resp.Body.Close()
 
-	if err := json.Unmarshal(data, &images); err != nil {
-		return nil, fmt.Errorf("unmarshaling container json: %s", err)
+		for img, err := range httputil.DecodeArray[imageapi.Image](ctx, resp.Body) {
+			if err != nil {
+				yield(img, fmt.Errorf("unmarshaling image json: %w", err))
+				return
+			}
+			if !yield(img, nil) {
+				return
+			}
+		}
 	}
-
-	return images, nil
 }
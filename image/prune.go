@@ -2,11 +2,10 @@ package image
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 
+	"github.com/cpuguy83/go-docker/filters"
 	"github.com/cpuguy83/go-docker/httputil"
 	"github.com/cpuguy83/go-docker/image/imageapi"
 	"github.com/cpuguy83/go-docker/version"
@@ -15,6 +14,8 @@ import (
 // PruneFilter represents filters to process on the prune list. See the official
 // docker docs for the meaning of each field
 // https://docs.docker.com/engine/api/v1.41/#operation/ImagePrune
+//
+// Deprecated: use filters.Args, see PruneConfig.FilterArgs.
 type PruneFilter struct {
 	Dangling []string `json:"dangling,omitempty"`
 	Label    []string `json:"label,omitempty"`
@@ -22,8 +23,33 @@ type PruneFilter struct {
 	Until    []string `json:"until,omitempty"`
 }
 
+// Args converts the filter to filters.Args.
+func (f PruneFilter) Args() filters.Args {
+	var a filters.Args
+	f.addTo(&a)
+	return a
+}
+
+func (f PruneFilter) addTo(a *filters.Args) {
+	for key, values := range map[string][]string{
+		"dangling": f.Dangling,
+		"label":    f.Label,
+		"label!":   f.NotLabel,
+		"until":    f.Until,
+	} {
+		for _, v := range values {
+			a.Add(key, v)
+		}
+	}
+}
+
 // PruneConfig holds the options for pruning images.
 type PruneConfig struct {
+	// FilterArgs is validated against filters.ImagePrune.
+	FilterArgs filters.Args
+	// Filters is added to FilterArgs.
+	//
+	// Deprecated: use FilterArgs.
 	Filters PruneFilter
 }
 
@@ -31,39 +57,50 @@ type PruneConfig struct {
 // configure a PruneConfig.
 type PruneOption func(config *PruneConfig)
 
+// WithPruneFilters sets the filters used to select the images to prune, e.g. filters.Dangling or filters.Until.
+func WithPruneFilters(f filters.Args) PruneOption {
+	return func(cfg *PruneConfig) {
+		cfg.FilterArgs = f
+	}
+}
+
 // prune prunes container images.
+// The list of removed images can be long, so the response is not limited as a whole, only each entry is, see
+// httputil.WithItemLimit.
 func (s *Service) Prune(ctx context.Context, opts ...PruneOption) (imageapi.Prune, error) {
 	cfg := PruneConfig{}
 	for _, o := range opts {
 		o(&cfg)
 	}
 
-	withPruneConfig := func(req *http.Request) error {
-		q := req.URL.Query()
-		filterJSON, err := json.Marshal(cfg.Filters)
-		if err != nil {
-			return err
-		}
-		_ = filterJSON
-		q.Add("filters", string(filterJSON))
-		req.URL.RawQuery = q.Encode()
-		return nil
-	}
+	args := cfg.FilterArgs.Clone()
+	cfg.Filters.addTo(&args)
 
+	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
 	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/images/prune"), withPruneConfig)
+		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/images/prune"), version.Require(version.ImagePrune), filters.ImagePrune.WithFilters(args))
 	})
 	if err != nil {
 		return imageapi.Prune{}, fmt.Errorf("pruning images: %w", err)
 	}
 	defer resp.Body.Close()
 
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return imageapi.Prune{}, err
-	}
 	var prune imageapi.Prune
-	if err := json.Unmarshal(data, &prune); err != nil {
+	err = httputil.DecodeObject(ctx, resp.Body, func(name string, f *httputil.Field) error {
+		switch name {
+		case "ImagesDeleted":
+			for deleted, err := range httputil.DecodeElements[imageapi.DeletedImage](f) {
+				if err != nil {
+					return err
+				}
+				prune.ImagesDeleted = append(prune.ImagesDeleted, deleted)
+			}
+		case "SpaceReclaimed":
+			return f.Decode(&prune.SpaceReclaimed)
+		}
+		return nil
+	})
+	if err != nil {
 		return imageapi.Prune{}, fmt.Errorf("reading response body: %w", err)
 	}
 	return prune, nil
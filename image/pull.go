@@ -171,11 +171,16 @@ func (s *Service) Pull(ctx context.Context, remote Remote, opts ...PullOption) e
 		return nil
 	}
 
+	var features []version.Feature
+	if cfg.Platform != "" {
+		features = append(features, version.PullPlatform)
+	}
+
 	// Set unlimited response size since this is going to be consumed by a progress reader.
 	// It's also pretty important to read the full body.
 	ctx = httputil.WithResponseLimitIfEmpty(ctx, httputil.UnlimitedResponseLimit)
 	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
-		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/images/create"), withConfig)
+		return s.tr.Do(ctx, http.MethodPost, version.Join(ctx, "/images/create"), version.Require(features...), withConfig)
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// BearerTokens creates an Authenticator which identifies clients by the bearer token in the Authorization header.
+// tokens maps each accepted token to the identity of the client which uses it.
+//
+// The Authorization header is removed from requests once they are authenticated so it is not forwarded.
+func BearerTokens(tokens map[string]string) Authenticator {
+	return func(req *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", errdefs.Unauthorized("missing bearer token")
+		}
+
+		var client string
+		var found bool
+		// Compare against every token so the time taken does not depend on which token matched.
+		for t, c := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				client, found = c, true
+			}
+		}
+		if !found {
+			return "", errdefs.Unauthorized("invalid bearer token")
+		}
+
+		req.Header.Del("Authorization")
+		return client, nil
+	}
+}
@@ -0,0 +1,439 @@
+// Package proxy serves the Docker Engine API on a listener and forwards requests to an upstream transport.Doer.
+//
+// This can be used to expose a daemon to clients which should only get restricted access to it, e.g. a container
+// which is given a socket to a proxy rather than the daemon socket.
+// Requests may be authenticated, rewritten, and filtered before they are forwarded, see `Config`.
+//
+// Streaming responses (such as events, logs, and pulls) are flushed to the client as they are received, and requests
+// which ask to upgrade the connection (such as attach, exec, /session, and /grpc) are forwarded with DoRaw and then
+// copied in both directions until either side is done.
+package proxy
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/transport"
+)
+
+// Authenticator identifies the client which sent a request.
+// The returned identity is made available to filters and rewriters through `ClientFromContext`.
+//
+// Returning an error rejects the request, it is sent back to the client as a 401, or as a 403 for errdefs.Forbidden
+// errors.
+type Authenticator func(req *http.Request) (string, error)
+
+// Filter decides whether a request may be forwarded.
+// Returning an error rejects the request. The status code sent to the client is derived from the errdefs class of
+// the error, e.g. errdefs.Forbidden is sent as a 403.
+type Filter func(req *http.Request) error
+
+// Rewriter modifies a request before it is forwarded, e.g. to add labels to containers created through the proxy.
+// The method, URL path and query, headers, and body of the request are forwarded.
+type Rewriter func(req *http.Request) error
+
+// Config holds the options for a Server.
+//
+// Requests are first authenticated, then rewritten, and then filtered, so filters see the request that is forwarded.
+// Note that transport.WithPolicy may also be used on the upstream Doer to filter requests by what they are able to do.
+type Config struct {
+	// Authenticate, if set, is called for every request.
+	Authenticate Authenticator
+	// Rewriters are called in order for every request.
+	Rewriters []Rewriter
+	// Filters are called in order for every request, all of them must allow the request.
+	Filters []Filter
+	// Logger is used to log errors forwarding requests.
+	// Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Option is used as functional arguments to `New`.
+type Option func(*Config)
+
+// WithAuthenticator sets the Authenticator used to identify clients.
+func WithAuthenticator(a Authenticator) Option {
+	return func(cfg *Config) {
+		cfg.Authenticate = a
+	}
+}
+
+// WithRewriter adds a Rewriter which is called for every request.
+func WithRewriter(r Rewriter) Option {
+	return func(cfg *Config) {
+		cfg.Rewriters = append(cfg.Rewriters, r)
+	}
+}
+
+// WithFilter adds a Filter which is called for every request.
+func WithFilter(f Filter) Option {
+	return func(cfg *Config) {
+		cfg.Filters = append(cfg.Filters, f)
+	}
+}
+
+// WithLogger sets the logger used to log errors forwarding requests.
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *Config) {
+		cfg.Logger = l
+	}
+}
+
+// Server forwards Engine API requests to an upstream Doer.
+// It implements http.Handler, so it can be used with any http.Server, or use `Serve` to serve it on a listener.
+type Server struct {
+	upstream transport.Doer
+	cfg      Config
+
+	mu       sync.Mutex
+	servers  []*http.Server
+	hijacked map[net.Conn]struct{}
+	closed   bool
+}
+
+// New creates a Server which forwards requests to upstream.
+func New(upstream transport.Doer, opts ...Option) *Server {
+	var cfg Config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Server{upstream: upstream, cfg: cfg, hijacked: make(map[net.Conn]struct{})}
+}
+
+type clientKey struct{}
+type connKey struct{}
+
+// ClientFromContext returns the client identity returned by the Authenticator for the request the context belongs to.
+// The returned bool is false if there is no Authenticator.
+func ClientFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(clientKey{}).(string)
+	return v, ok
+}
+
+// ConnFromContext returns the client connection a request was received on.
+// This is only available for requests served by `Server.Serve`.
+//
+// This can be used by an Authenticator to identify clients by their connection, e.g. by the credentials of the peer
+// process on a unix socket.
+func ConnFromContext(ctx context.Context) (net.Conn, bool) {
+	c, ok := ctx.Value(connKey{}).(net.Conn)
+	return c, ok
+}
+
+// Serve accepts connections on the listener and serves the API on them.
+// It blocks until the listener is closed or the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	srv := &http.Server{
+		Handler: s,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close stops all listeners started with `Serve` and closes all connections, including upgraded ones.
+// The upstream Doer is not closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	servers := s.servers
+	s.servers = nil
+	hijacked := s.hijacked
+	s.hijacked = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	for _, srv := range servers {
+		srv.Close()
+	}
+	for c := range hijacked {
+		c.Close()
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.cfg.Authenticate != nil {
+		client, err := s.cfg.Authenticate(req)
+		if err != nil {
+			if !errdefs.IsForbidden(err) {
+				err = errdefs.AsUnauthorized(err)
+			}
+			writeError(w, err)
+			return
+		}
+		req = req.WithContext(context.WithValue(req.Context(), clientKey{}, client))
+	}
+
+	for _, r := range s.cfg.Rewriters {
+		if err := r(req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	for _, f := range s.cfg.Filters {
+		if err := f(req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	if req.Header.Get("Upgrade") != "" {
+		s.forwardUpgrade(w, req)
+		return
+	}
+	s.forward(w, req)
+}
+
+// hopHeaders are the headers which only apply to a single connection and so are not forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
+
+// withRequest is a RequestOpt which copies the query, headers, and body of the client request to the upstream request.
+func withRequest(in *http.Request) transport.RequestOpt {
+	return func(req *http.Request) error {
+		req.URL.RawQuery = in.URL.RawQuery
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		copyHeader(req.Header, in.Header)
+		if in.Body != nil && in.Body != http.NoBody {
+			req.Body = in.Body
+			req.ContentLength = in.ContentLength
+		}
+		return nil
+	}
+}
+
+func (s *Server) forward(w http.ResponseWriter, req *http.Request) {
+	resp, err := s.upstream.Do(req.Context(), req.Method, req.URL.Path, withRequest(req))
+	if err != nil {
+		s.upstreamError(w, req, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	copyHeader(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+
+	// Flush the headers and then every read so that streaming responses reach the client as soon as they are received.
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			rc.Flush()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && req.Context().Err() == nil {
+				s.cfg.Logger.LogAttrs(req.Context(), slog.LevelDebug, "error reading upstream response",
+					slog.String("method", req.Method),
+					slog.String("path", req.URL.Path),
+					slog.String("error", err.Error()),
+				)
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) forwardUpgrade(w http.ResponseWriter, req *http.Request) {
+	proto := req.Header.Get("Upgrade")
+	// The upstream response headers are forwarded as is, e.g. clients use the Content-Type to know whether attach
+	// output is multiplexed.
+	var upgraded *http.Response
+	ctx := transport.WithUpgradeResponse(req.Context(), func(resp *http.Response) { upgraded = resp })
+	upstream, err := s.upstream.DoRaw(ctx, req.Method, req.URL.Path, withRequest(req), transport.WithUpgrade(proto))
+	if err != nil {
+		s.upstreamError(w, req, err)
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		s.upstreamError(w, req, err)
+		return
+	}
+
+	if !s.track(conn) {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+	defer s.untrack(conn)
+	defer conn.Close()
+	defer upstream.Close()
+
+	if upgraded != nil {
+		rw.WriteString("HTTP/1.1 " + upgraded.Status + "\r\n")
+		upgraded.Header.Write(rw)
+	} else {
+		// Not every upstream Doer has the response headers, in which case the WebSocket handshake is answered here.
+		rw.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: " + proto + "\r\n")
+		if key := req.Header.Get("Sec-WebSocket-Key"); key != "" && strings.EqualFold(proto, "websocket") {
+			rw.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n")
+		}
+	}
+	rw.WriteString("\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	// The client may have sent data after the request which is already buffered.
+	client := io.MultiReader(io.LimitReader(rw.Reader, int64(rw.Reader.Buffered())), conn)
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, client)
+		closeWrite(upstream)
+		close(done)
+	}()
+
+	io.Copy(conn, upstream)
+	closeWrite(conn)
+	<-done
+}
+
+// track records a hijacked connection so that it can be closed by `Close`.
+// It returns false if the server is already closed.
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.hijacked[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.mu.Lock()
+	delete(s.hijacked, c)
+	s.mu.Unlock()
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept header for a WebSocket handshake, see RFC 6455 section 4.2.2.
+func webSocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// closeWrite signals the end of the stream to the other side of the connection.
+// Connections which cannot be half-closed are closed.
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(closeWriter); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
+
+func (s *Server) upstreamError(w http.ResponseWriter, req *http.Request, err error) {
+	if req.Context().Err() != nil {
+		return
+	}
+	s.cfg.Logger.LogAttrs(req.Context(), slog.LevelWarn, "error forwarding request",
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("error", err.Error()),
+	)
+
+	code := statusCode(err)
+	if code == http.StatusInternalServerError {
+		code = http.StatusBadGateway
+	}
+	writeJSON(w, code, errorResponse{Message: err.Error()})
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the error in the same format as the daemon, with the status code derived from the errdefs class.
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), errorResponse{Message: err.Error()})
+}
+
+func statusCode(err error) int {
+	switch {
+	case errdefs.IsNotFound(err):
+		return http.StatusNotFound
+	case errdefs.IsInvalid(err):
+		return http.StatusBadRequest
+	case errdefs.IsConflict(err):
+		return http.StatusConflict
+	case errdefs.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	case errdefs.IsForbidden(err):
+		return http.StatusForbidden
+	case errdefs.IsUnavailable(err):
+		return http.StatusServiceUnavailable
+	case errdefs.IsNotModified(err):
+		return http.StatusNotModified
+	case errdefs.IsNotImplemented(err):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
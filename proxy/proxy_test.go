@@ -0,0 +1,283 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container"
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/system"
+	"github.com/cpuguy83/go-docker/testutils/fakeengine"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// newTestProxy serves a proxy to a fake engine on a unix socket and returns a transport connected to it.
+func newTestProxy(t *testing.T, opts ...Option) (*transport.Transport, context.Context) {
+	t.Helper()
+
+	e := fakeengine.New()
+	t.Cleanup(func() { e.Close() })
+	e.AddImage(fakeengine.Image{RepoTags: []string{"busybox:latest"}, Config: fakeengine.ImageConfig{Cmd: []string{"sh"}}})
+
+	s := New(e.Doer(), opts...)
+	t.Cleanup(func() { s.Close() })
+
+	sock := filepath.Join(t.TempDir(), "proxy.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	go s.Serve(l)
+
+	tr, err := transport.UnixSocketTransport(sock)
+	assert.NilError(t, err)
+	t.Cleanup(func() { tr.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return tr, version.WithAPIVersion(ctx, fakeengine.DefaultAPIVersion)
+}
+
+func TestProxy(t *testing.T) {
+	tr, ctx := newTestProxy(t)
+	s := container.NewService(tr)
+
+	_, err := s.Inspect(ctx, "notexist")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	// Events are streamed as they happen.
+	events, err := system.NewService(tr).Events(ctx)
+	assert.NilError(t, err)
+
+	c, err := s.Create(ctx, "busybox:latest", container.WithCreateCmd("/bin/sh", "-c", "echo hello; >&2 echo world"))
+	assert.NilError(t, err)
+
+	ev, err := events()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(ev.Action, "create"))
+	assert.Check(t, cmp.Equal(ev.Actor.ID, c.ID()))
+
+	// Attach is upgraded.
+	stdout, err := c.StdoutPipe(ctx)
+	assert.NilError(t, err)
+	defer stdout.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	data, err := io.ReadAll(stdout)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	es, err := c.Wait(ctx)
+	assert.NilError(t, err)
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 0))
+
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+	assert.NilError(t, c.Logs(ctx, func(cfg *container.LogReadConfig) {
+		cfg.Stdout = outW
+		cfg.Stderr = errW
+	}))
+	go io.Copy(io.Discard, errR)
+	data, err = io.ReadAll(outR)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+}
+
+func TestProxyExec(t *testing.T) {
+	tr, ctx := newTestProxy(t)
+	s := container.NewService(tr)
+
+	c, err := s.Create(ctx, "busybox:latest", container.WithCreateCmd("top"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Start(ctx))
+
+	r, w := io.Pipe()
+	defer r.Close()
+	ep, err := c.Exec(ctx, container.WithExecCmd("cat"), func(cfg *container.ExecConfig) {
+		cfg.Stdin = io.NopCloser(strings.NewReader("hello\n"))
+		cfg.Stdout = w
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ep.Start(ctx))
+
+	data, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+}
+
+func TestProxyUpgradeHeaders(t *testing.T) {
+	tr, ctx := newTestProxy(t)
+	s := container.NewService(tr)
+
+	for _, tc := range []struct {
+		name        string
+		opts        []container.CreateOption
+		contentType string
+	}{
+		{name: "tty", opts: []container.CreateOption{container.WithCreateTTY}, contentType: "application/vnd.docker.raw-stream"},
+		{name: "no tty", contentType: "application/vnd.docker.multiplexed-stream"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := s.Create(ctx, "busybox:latest", append(tc.opts, container.WithCreateCmd("top"))...)
+			assert.NilError(t, err)
+
+			var resp *http.Response
+			ctx := transport.WithUpgradeResponse(ctx, func(r *http.Response) { resp = r })
+			conn, err := tr.DoRaw(ctx, http.MethodPost, version.Join(ctx, "/containers/"+c.ID()+"/attach"), transport.WithUpgrade("tcp"), func(req *http.Request) error {
+				req.URL.RawQuery = "stream=1&stdout=1"
+				return nil
+			})
+			assert.NilError(t, err)
+			defer conn.Close()
+
+			assert.Assert(t, resp != nil)
+			assert.Check(t, cmp.Equal(resp.StatusCode, http.StatusSwitchingProtocols))
+			assert.Check(t, cmp.Equal(resp.Header.Get("Content-Type"), tc.contentType))
+			assert.Check(t, cmp.Equal(resp.Header.Get("Upgrade"), "tcp"))
+		})
+	}
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	tr, ctx := newTestProxy(t)
+	s := container.NewService(tr)
+
+	c, err := s.Create(ctx, "busybox:latest", container.WithCreateCmd("echo", "hello"))
+	assert.NilError(t, err)
+
+	stdout, err := c.StdoutPipe(ctx, container.WithAttachWebSocket)
+	assert.NilError(t, err)
+	defer stdout.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	data, err := io.ReadAll(stdout)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	// RFC 6455 section 1.3
+	assert.Check(t, cmp.Equal(webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="))
+}
+
+func TestProxyFilter(t *testing.T) {
+	denyKill := func(req *http.Request) error {
+		if strings.HasSuffix(req.URL.Path, "/kill") {
+			return errdefs.Forbidden("kill is not allowed")
+		}
+		return nil
+	}
+	tr, ctx := newTestProxy(t, WithFilter(denyKill))
+	s := container.NewService(tr)
+
+	c, err := s.Create(ctx, "busybox:latest", container.WithCreateCmd("top"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Start(ctx))
+
+	err = c.Kill(ctx)
+	assert.Check(t, errdefs.IsForbidden(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "kill is not allowed"))
+	assert.NilError(t, c.Stop(ctx))
+}
+
+func TestProxyUpstreamPolicy(t *testing.T) {
+	e := fakeengine.New()
+	defer e.Close()
+
+	srv := New(transport.Chain(e.Doer(), transport.WithPolicy(transport.ReadOnlyPolicy())))
+	defer srv.Close()
+
+	sock := filepath.Join(t.TempDir(), "proxy.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	go srv.Serve(l)
+
+	tr, err := transport.UnixSocketTransport(sock)
+	assert.NilError(t, err)
+	defer tr.Close()
+	ctx := version.WithAPIVersion(context.Background(), fakeengine.DefaultAPIVersion)
+
+	s := container.NewService(tr)
+	_, err = s.List(ctx)
+	assert.NilError(t, err)
+
+	_, err = s.Create(ctx, "busybox:latest")
+	assert.Check(t, errdefs.IsForbidden(err), err)
+}
+
+func TestProxyRewrite(t *testing.T) {
+	// Label all containers with the client which created them.
+	addOwner := func(req *http.Request) error {
+		if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/containers/create") {
+			return nil
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return errdefs.Invalid(err.Error())
+		}
+		labels, _ := body["Labels"].(map[string]interface{})
+		if labels == nil {
+			labels = map[string]interface{}{}
+		}
+		client, _ := ClientFromContext(req.Context())
+		labels["owner"] = client
+		body["Labels"] = labels
+
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		return nil
+	}
+
+	tr, ctx := newTestProxy(t,
+		WithAuthenticator(BearerTokens(map[string]string{"secret": "alice"})),
+		WithRewriter(addOwner),
+	)
+	s := container.NewService(tr)
+
+	_, err := s.Create(ctx, "busybox:latest")
+	assert.Check(t, errdefs.IsUnauthorized(err), err)
+
+	authed := container.NewService(transport.Chain(tr, withToken("wrong")))
+	_, err = authed.Create(ctx, "busybox:latest")
+	assert.Check(t, errdefs.IsUnauthorized(err), err)
+
+	authed = container.NewService(transport.Chain(tr, withToken("secret")))
+	c, err := authed.Create(ctx, "busybox:latest", container.WithCreateConfigOpt(func(cfg *containerapi.Config) {
+		cfg.Labels = map[string]string{"foo": "bar"}
+	}))
+	assert.NilError(t, err)
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(inspect.Config.Labels, map[string]string{"foo": "bar", "owner": "alice"}))
+}
+
+// withToken creates a Middleware which adds a bearer token to all requests.
+func withToken(token string) transport.Middleware {
+	return transport.WithHooks(transport.Hooks{
+		BeforeRequest: func(req *http.Request) error {
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		},
+	})
+}
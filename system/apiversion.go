@@ -2,19 +2,57 @@ package system
 
 import (
 	"context"
+	"strings"
 
 	"github.com/cpuguy83/go-docker/version"
 )
 
+// Negotiation is the result of negotiating the API version with the daemon.
+type Negotiation struct {
+	// APIVersion is the negotiated API version.
+	APIVersion string
+	// ServerAPIVersion is the maximum API version supported by the daemon.
+	ServerAPIVersion string
+	// ServerMinAPIVersion is the minimum API version supported by the daemon.
+	// This is empty for daemons which do not report one.
+	ServerMinAPIVersion string
+}
+
 // NegoitateAPIVersion negotiates the API version to use with the server.
 // The returned context stores the version.
 // Pass that ctx into calls that you want to use this negoiated version with.
+//
+// The version stored in ctx, if any, is the maximum version which may be negotiated.
+// An errdefs.NotImplemented error is returned if the client and the server do not support a common version.
+// The result is also available from Negotiated.
 func (s *Service) NegotiateAPIVersion(ctx context.Context) (context.Context, error) {
-	p, err := s.Ping(ctx)
+	v, err := s.Version(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx, err = version.NegotiateRange(ctx, v.MinAPIVersion, v.APIVersion)
 	if err != nil {
-		if p.APIVersion == "" {
-			return ctx, err
-		}
+		return ctx, err
+	}
+
+	s.mu.Lock()
+	s.negotiated = &Negotiation{
+		APIVersion:          strings.TrimPrefix(version.APIVersion(ctx), "v"),
+		ServerAPIVersion:    v.APIVersion,
+		ServerMinAPIVersion: v.MinAPIVersion,
+	}
+	s.mu.Unlock()
+	return ctx, nil
+}
+
+// Negotiated returns the result of the last successful call to NegotiateAPIVersion.
+// The bool is false if the API version was not negotiated.
+func (s *Service) Negotiated() (Negotiation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.negotiated == nil {
+		return Negotiation{}, false
 	}
-	return version.Negotiate(ctx, p.APIVersion), nil
+	return *s.negotiated, true
 }
@@ -0,0 +1,43 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/version"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	tr := &mockDoer{}
+	tr.registerHandler(http.MethodGet, "/version", func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"Version":"27.0.0","ApiVersion":"1.46","MinAPIVersion":"1.24"}`)),
+		}
+	})
+	s := NewService(tr)
+
+	v, err := s.Version(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(v.Version, "27.0.0"))
+
+	_, ok := s.Negotiated()
+	assert.Check(t, !ok)
+
+	ctx, err := s.NegotiateAPIVersion(version.WithAPIVersion(context.Background(), "1.45"))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(version.APIVersion(ctx), "1.45"))
+
+	n, ok := s.Negotiated()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(n, Negotiation{APIVersion: "1.45", ServerAPIVersion: "1.46", ServerMinAPIVersion: "1.24"}))
+
+	_, err = s.NegotiateAPIVersion(version.WithAPIVersion(context.Background(), "1.23"))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/cpuguy83/go-docker/filters"
 	"github.com/cpuguy83/go-docker/version"
 )
 
@@ -36,14 +37,16 @@ type EventActor struct {
 
 // EventConfig is used to configure the event stream.
 type EventConfig struct {
-	Since        *time.Time
-	Until        *time.Time
-	FieldFilters FieldFilter
+	Since *time.Time
+	Until *time.Time
+	// FieldFilters is validated against filters.Events.
+	FieldFilters filters.Args
 }
 
-type FieldFilter struct {
-	fields map[string]map[string]bool
-}
+// FieldFilter is the set of filters for the event stream.
+//
+// Deprecated: use filters.Args.
+type FieldFilter = filters.Args
 
 // WithEventsBetween is an EventOption that sets the time range for the event stream.
 func WithEventsBetween(since, until time.Time) EventOption {
@@ -53,22 +56,12 @@ func WithEventsBetween(since, until time.Time) EventOption {
 	}
 }
 
-func (f *FieldFilter) Add(key, value string) {
-	if f.fields == nil {
-		f.fields = make(map[string]map[string]bool)
-	}
-	if _, ok := f.fields[key]; !ok {
-		f.fields[key] = make(map[string]bool)
-	}
-	f.fields[key][value] = true
-}
-
 // EventOption is a function that can be passed to Events to configure the event stream.
 type EventOption func(*EventConfig)
 
 // WithFilters is an EventOption that adds filters to the event stream.
 // These filters are passed to the docker daemon and are used to filter the events returned.
-func WithEventFilters(f FieldFilter) EventOption {
+func WithEventFilters(f filters.Args) EventOption {
 	return func(cfg *EventConfig) {
 		cfg.FieldFilters = f
 	}
@@ -94,15 +87,8 @@ func (s *Service) Events(ctx context.Context, opts ...EventOption) (func() (*Eve
 		o(&cfg)
 	}
 
-	resp, err := s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/events"), func(req *http.Request) error {
+	withEventConfig := func(req *http.Request) error {
 		q := url.Values{}
-		if len(cfg.FieldFilters.fields) > 0 {
-			dt, err := json.Marshal(cfg.FieldFilters.fields)
-			if err != nil {
-				return err
-			}
-			q.Set("filters", string(dt))
-		}
 		if cfg.Since != nil {
 			q.Set("since", strconv.FormatInt(cfg.Since.Unix(), 10))
 		}
@@ -114,7 +100,9 @@ func (s *Service) Events(ctx context.Context, opts ...EventOption) (func() (*Eve
 			req.URL.RawQuery = q.Encode()
 		}
 		return nil
-	})
+	}
+
+	resp, err := s.tr.Do(ctx, http.MethodGet, version.Join(ctx, "/events"), withEventConfig, filters.Events.WithFilters(cfg.FieldFilters))
 	if err != nil {
 		return nil, err
 	}
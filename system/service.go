@@ -1,15 +1,22 @@
 package system
 
-import "github.com/cpuguy83/go-docker/transport"
+import (
+	"sync"
+
+	"github.com/cpuguy83/go-docker/transport"
+)
 
 // Service facilitates all communication with Docker's container endpoints.
 // Create one with `NewService`
 type Service struct {
 	tr transport.Doer
+
+	mu         sync.Mutex
+	negotiated *Negotiation
 }
 
 // NewService creates a Service.
 // This is the entrypoint to this package.
 func NewService(tr transport.Doer) *Service {
-	return &Service{tr}
+	return &Service{tr: tr}
 }
@@ -0,0 +1,42 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/httputil"
+)
+
+// Version is the version information reported by the daemon.
+type Version struct {
+	Version       string
+	APIVersion    string `json:"ApiVersion"`
+	MinAPIVersion string `json:"MinAPIVersion,omitempty"`
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+	KernelVersion string `json:",omitempty"`
+	Experimental  bool   `json:",omitempty"`
+	BuildTime     string `json:",omitempty"`
+}
+
+// Version gets the version information of the daemon.
+func (s *Service) Version(ctx context.Context) (Version, error) {
+	var v Version
+
+	resp, err := httputil.DoRequest(ctx, func(ctx context.Context) (*http.Response, error) {
+		return s.tr.Do(ctx, http.MethodGet, "/version")
+	})
+	if err != nil {
+		return v, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return v, errdefs.Wrap(err, "error decoding version response")
+	}
+	return v, nil
+}
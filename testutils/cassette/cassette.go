@@ -0,0 +1,142 @@
+// Package cassette provides Doers which record interactions with a docker daemon to a file and replay them later.
+//
+// This allows test suites which normally require a live daemon to run without one:
+// run the suite once against a daemon with a Recorder, save the cassette, and then run it with a Replayer.
+// Tests must be deterministic for their requests to match on replay: random values, such as container names, must be
+// recorded with `Recorder.Value` and read back with `Replayer.Value`.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cpuguy83/go-docker/transport"
+)
+
+// Cassette is a recorded list of interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+	// Values are values generated by the test while recording, such as random container names.
+	// They are returned again on replay, in the same order, so that the requests which use them match.
+	Values []string `json:"values,omitempty"`
+}
+
+// Interaction is a single recorded request and its result.
+// Exactly one of Response, Raw, or Error is set.
+type Interaction struct {
+	Request  Request   `json:"request"`
+	Response *Response `json:"response,omitempty"`
+	Raw      *Raw      `json:"raw,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Response is a recorded response from `Do`.
+// The body is stored as the chunks in which it was read, which preserves the timing of streamed responses.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []Chunk     `json:"body,omitempty"`
+}
+
+// Chunk is a piece of a recorded stream.
+type Chunk struct {
+	// Delay is the time since the previous chunk (or since the stream was opened for the first chunk).
+	Delay time.Duration `json:"delay"`
+	Data  []byte        `json:"data"`
+}
+
+// Direction is the direction of data on a hijacked connection.
+type Direction string
+
+const (
+	// DirectionRead is data read from the daemon.
+	DirectionRead Direction = "read"
+	// DirectionWrite is data written to the daemon.
+	DirectionWrite Direction = "write"
+)
+
+// RawEvent is a chunk of data sent or received on a hijacked connection.
+type RawEvent struct {
+	Direction Direction `json:"direction"`
+	Chunk
+}
+
+// Raw is a recorded hijacked connection from `DoRaw`.
+type Raw struct {
+	Events []RawEvent `json:"events,omitempty"`
+}
+
+// Load reads a cassette from the provided file.
+func Load(p string) (*Cassette, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error decoding cassette %s: %w", p, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to the provided file, creating any parent directories.
+func (c *Cassette) Save(p string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+// credential headers are not stored in cassettes
+var redactHeaders = []string{"X-Registry-Auth", "X-Registry-Config", "Authorization"}
+
+// captureRequest makes a RequestOpt which records the final state of the request into r.
+// It must be the last option applied.
+func captureRequest(r *Request) transport.RequestOpt {
+	return func(req *http.Request) error {
+		r.Method = req.Method
+		if req.URL != nil {
+			r.Path = req.URL.Path
+			r.Query = req.URL.Query().Encode()
+		}
+
+		if len(req.Header) > 0 {
+			r.Header = req.Header.Clone()
+			for _, k := range redactHeaders {
+				if r.Header.Get(k) != "" {
+					r.Header.Set(k, "<REDACTED>")
+				}
+			}
+		}
+
+		if req.Body != nil {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(data))
+			r.Body = transport.RedactJSON(data)
+		}
+		return nil
+	}
+}
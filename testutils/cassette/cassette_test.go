@@ -0,0 +1,170 @@
+package cassette
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/httputil"
+	"github.com/cpuguy83/go-docker/transport"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func newTestServer(t *testing.T) *transport.Transport {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/stream":
+			for _, s := range []string{"one\n", "two\n", "three\n"} {
+				w.Write([]byte(s))
+				w.(http.Flusher).Flush()
+			}
+		case "/echo":
+			data, _ := io.ReadAll(req.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		case "/attach":
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+			buf.Flush()
+			line, _ := buf.ReadString('\n')
+			conn.Write([]byte("echo: " + line))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	tr, err := transport.TCPTransport(u.Host)
+	assert.NilError(t, err)
+	return tr
+}
+
+func exercise(t *testing.T, d transport.Doer) {
+	t.Helper()
+	ctx := context.Background()
+
+	resp, err := d.Do(ctx, http.MethodGet, "/stream", func(req *http.Request) error {
+		req.URL.RawQuery = url.Values{"b": {"2"}, "a": {"1"}}.Encode()
+		return nil
+	})
+	assert.NilError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "one\ntwo\nthree\n"))
+
+	body := map[string]string{"username": "me", "password": "secret"}
+	resp, err = d.Do(ctx, http.MethodPost, "/echo", httputil.WithJSONBody(body))
+	assert.NilError(t, err)
+	data, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(string(data), `"username":"me"`))
+
+	resp, err = d.Do(ctx, http.MethodGet, "/notexist")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, cmp.Equal(resp.StatusCode, http.StatusNotFound))
+
+	conn, err := d.DoRaw(ctx, http.MethodPost, "/attach", transport.WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello\n"))
+	assert.NilError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(line, "echo: hello\n"))
+}
+
+func TestRecordReplay(t *testing.T) {
+	rec := NewRecorder(newTestServer(t))
+	exercise(t, rec)
+
+	p := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NilError(t, rec.Save(p))
+
+	c, err := Load(p)
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(c.Interactions, 4))
+	assert.Check(t, cmp.Equal(c.Interactions[0].Request.Query, "a=1&b=2"))
+	var streamed []byte
+	for _, chunk := range c.Interactions[0].Response.Body {
+		streamed = append(streamed, chunk.Data...)
+	}
+	assert.Check(t, cmp.Equal(string(streamed), "one\ntwo\nthree\n"))
+	assert.Check(t, !strings.Contains(string(c.Interactions[1].Request.Body), "secret"))
+
+	replay := NewReplayer(c)
+	exercise(t, replay)
+	assert.Check(t, cmp.Len(replay.Unused(), 0))
+
+	// Everything has been used up
+	_, err = replay.Do(context.Background(), http.MethodGet, "/notexist")
+	assert.Check(t, cmp.ErrorContains(err, "no recorded interaction"))
+}
+
+func TestReplayMatch(t *testing.T) {
+	c := &Cassette{Interactions: []*Interaction{
+		{
+			Request:  Request{Method: http.MethodGet, Path: "/foo", Query: "a=1"},
+			Response: &Response{StatusCode: http.StatusOK, Body: []Chunk{{Data: []byte("a")}}},
+		},
+		{
+			Request:  Request{Method: http.MethodGet, Path: "/foo", Query: "a=2"},
+			Response: &Response{StatusCode: http.StatusOK, Body: []Chunk{{Data: []byte("b")}}},
+		},
+	}}
+
+	replay := NewReplayer(c)
+	ctx := context.Background()
+
+	_, err := replay.Do(ctx, http.MethodPost, "/foo")
+	assert.Check(t, cmp.ErrorContains(err, "no recorded interaction"))
+
+	resp, err := replay.Do(ctx, http.MethodGet, "/foo", func(req *http.Request) error {
+		req.URL.RawQuery = "a=2"
+		return nil
+	})
+	assert.NilError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "b"))
+
+	assert.Check(t, cmp.Len(replay.Unused(), 1))
+}
+
+func TestRecordReplayValues(t *testing.T) {
+	rec := NewRecorder(newTestServer(t))
+	assert.Check(t, cmp.Equal(rec.Value("a"), "a"))
+	assert.Check(t, cmp.Equal(rec.Value("b"), "b"))
+
+	p := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NilError(t, rec.Save(p))
+	c, err := Load(p)
+	assert.NilError(t, err)
+
+	replay := NewReplayer(c)
+	for _, want := range []string{"a", "b"} {
+		v, err := replay.Value()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(v, want))
+	}
+	_, err = replay.Value()
+	assert.Check(t, cmp.ErrorContains(err, "no more recorded values"))
+}
@@ -0,0 +1,187 @@
+package cassette
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cpuguy83/go-docker/transport"
+)
+
+// Recorder is a transport.Doer which records all interactions with the wrapped Doer.
+// Create one with `NewRecorder`.
+type Recorder struct {
+	d transport.Doer
+
+	mu sync.Mutex
+	c  Cassette
+}
+
+// NewRecorder creates a Recorder which passes all requests along to the provided Doer.
+func NewRecorder(d transport.Doer) *Recorder {
+	return &Recorder{d: d}
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+//
+// Response bodies and hijacked connections are recorded as they are consumed,
+// so these should be closed before calling this.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &Cassette{
+		Interactions: make([]*Interaction, 0, len(r.c.Interactions)),
+		Values:       append([]string(nil), r.c.Values...),
+	}
+	for _, i := range r.c.Interactions {
+		cp := *i
+		if i.Response != nil {
+			resp := *i.Response
+			resp.Body = append([]Chunk(nil), i.Response.Body...)
+			cp.Response = &resp
+		}
+		if i.Raw != nil {
+			cp.Raw = &Raw{Events: append([]RawEvent(nil), i.Raw.Events...)}
+		}
+		c.Interactions = append(c.Interactions, &cp)
+	}
+	return c
+}
+
+// Save writes the recorded interactions to the provided file.
+func (r *Recorder) Save(p string) error {
+	return r.Cassette().Save(p)
+}
+
+// Value records a value generated by the test, such as a random container name, and returns it.
+// `Replayer.Value` returns the recorded values in the same order.
+func (r *Recorder) Value(v string) string {
+	r.mu.Lock()
+	r.c.Values = append(r.c.Values, v)
+	r.mu.Unlock()
+	return v
+}
+
+func (r *Recorder) add(i *Interaction) {
+	r.mu.Lock()
+	r.c.Interactions = append(r.c.Interactions, i)
+	r.mu.Unlock()
+}
+
+// Do implements the transport.Doer interface
+func (r *Recorder) Do(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (*http.Response, error) {
+	i := &Interaction{}
+	opts = append(opts[:len(opts):len(opts)], captureRequest(&i.Request))
+
+	resp, err := r.d.Do(ctx, method, uri, opts...)
+	if err != nil {
+		i.Error = err.Error()
+		r.add(i)
+		return resp, err
+	}
+
+	i.Response = &Response{StatusCode: resp.StatusCode, Header: resp.Header.Clone()}
+	r.add(i)
+
+	resp.Body = &recordReader{
+		ReadCloser: resp.Body,
+		last:       time.Now(),
+		record: func(c Chunk) {
+			r.mu.Lock()
+			i.Response.Body = append(i.Response.Body, c)
+			r.mu.Unlock()
+		},
+	}
+	return resp, nil
+}
+
+// DoRaw implements the transport.Doer interface
+func (r *Recorder) DoRaw(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (net.Conn, error) {
+	i := &Interaction{}
+	opts = append(opts[:len(opts):len(opts)], captureRequest(&i.Request))
+
+	conn, err := r.d.DoRaw(ctx, method, uri, opts...)
+	if err != nil {
+		i.Error = err.Error()
+		r.add(i)
+		return conn, err
+	}
+
+	i.Raw = &Raw{}
+	r.add(i)
+
+	rc := &recordConn{Conn: conn, last: time.Now()}
+	rc.record = func(dir Direction, c Chunk) {
+		r.mu.Lock()
+		i.Raw.Events = append(i.Raw.Events, RawEvent{Direction: dir, Chunk: c})
+		r.mu.Unlock()
+	}
+	if _, ok := conn.(closeWriter); ok {
+		return &recordConnCloseWrite{rc}, nil
+	}
+	return rc, nil
+}
+
+type recordReader struct {
+	io.ReadCloser
+	last   time.Time
+	record func(Chunk)
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		now := time.Now()
+		r.record(Chunk{Delay: now.Sub(r.last), Data: append([]byte(nil), p[:n]...)})
+		r.last = now
+	}
+	return n, err
+}
+
+type recordConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	last   time.Time
+	record func(Direction, Chunk)
+}
+
+func (c *recordConn) add(dir Direction, p []byte) {
+	c.mu.Lock()
+	now := time.Now()
+	c.record(dir, Chunk{Delay: now.Sub(c.last), Data: append([]byte(nil), p...)})
+	c.last = now
+	c.mu.Unlock()
+}
+
+func (c *recordConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.add(DirectionRead, p[:n])
+	}
+	return n, err
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.add(DirectionWrite, p[:n])
+	}
+	return n, err
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+type recordConnCloseWrite struct {
+	*recordConn
+}
+
+func (c *recordConnCloseWrite) CloseWrite() error {
+	return c.Conn.(closeWriter).CloseWrite()
+}
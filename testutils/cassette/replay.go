@@ -0,0 +1,250 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cpuguy83/go-docker/transport"
+)
+
+// ReplayConfig holds the options for a Replayer.
+type ReplayConfig struct {
+	// Realtime makes the replayer wait between chunks of streamed responses and hijacked connections as was recorded.
+	// By default data is replayed as fast as it is consumed.
+	Realtime bool
+}
+
+// ReplayOption is used as functional arguments to `NewReplayer`.
+type ReplayOption func(*ReplayConfig)
+
+// WithRealtime is a ReplayOption which enables realtime replay.
+func WithRealtime(cfg *ReplayConfig) {
+	cfg.Realtime = true
+}
+
+// Replayer is a transport.Doer which serves responses from a cassette.
+// Create one with `NewReplayer`.
+//
+// Requests are matched against the recorded interactions by method, path, query, and body.
+// Each recorded interaction is only used once, in the order they were recorded.
+type Replayer struct {
+	cfg ReplayConfig
+
+	mu     sync.Mutex
+	c      *Cassette
+	used   []bool
+	values int
+}
+
+// NewReplayer creates a Replayer which serves the interactions in the provided cassette.
+func NewReplayer(c *Cassette, opts ...ReplayOption) *Replayer {
+	var cfg ReplayConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return &Replayer{cfg: cfg, c: c, used: make([]bool, len(c.Interactions))}
+}
+
+// Unused returns the interactions which have not been replayed.
+// This is useful to assert that a test made all the requests it made when it was recorded.
+func (r *Replayer) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []*Interaction
+	for idx, i := range r.c.Interactions {
+		if !r.used[idx] {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// Value returns the next value recorded with `Recorder.Value`.
+func (r *Replayer) Value() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values >= len(r.c.Values) {
+		return "", errors.New("cassette: no more recorded values")
+	}
+	v := r.c.Values[r.values]
+	r.values++
+	return v, nil
+}
+
+func (r *Replayer) match(ctx context.Context, method, uri string, opts []transport.RequestOpt) (*Interaction, error) {
+	req := (&http.Request{
+		Method: method,
+		URL:    &url.URL{Path: uri},
+		Header: http.Header{},
+	}).WithContext(ctx)
+
+	var got Request
+	opts = append(opts[:len(opts):len(opts)], captureRequest(&got))
+	for _, o := range opts {
+		if err := o(req); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, i := range r.c.Interactions {
+		if r.used[idx] {
+			continue
+		}
+		want := i.Request
+		if want.Method != got.Method || want.Path != got.Path || want.Query != got.Query || !bytes.Equal(want.Body, got.Body) {
+			continue
+		}
+		r.used[idx] = true
+		return i, nil
+	}
+	return nil, fmt.Errorf("cassette: no recorded interaction matches %s %s?%s", got.Method, got.Path, got.Query)
+}
+
+func (r *Replayer) wait(ctx context.Context, d time.Duration) error {
+	if !r.cfg.Realtime || d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Do implements the transport.Doer interface
+func (r *Replayer) Do(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (*http.Response, error) {
+	i, err := r.match(ctx, method, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+	if i.Response == nil {
+		if i.Error != "" {
+			return nil, errors.New(i.Error)
+		}
+		return nil, fmt.Errorf("cassette: recorded interaction for %s %s has no response", method, uri)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		for _, c := range i.Response.Body {
+			if err := r.wait(ctx, c.Delay); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(c.Data); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	return &http.Response{
+		StatusCode: i.Response.StatusCode,
+		Status:     fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		Header:     i.Response.Header.Clone(),
+		Body:       pr,
+	}, nil
+}
+
+// DoRaw implements the transport.Doer interface
+//
+// The returned connection replays the data read from the daemon.
+// Data written to the connection is consumed according to the recording, but it is not checked.
+func (r *Replayer) DoRaw(ctx context.Context, method, uri string, opts ...transport.RequestOpt) (net.Conn, error) {
+	i, err := r.match(ctx, method, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+	if i.Raw == nil {
+		if i.Error != "" {
+			return nil, errors.New(i.Error)
+		}
+		return nil, fmt.Errorf("cassette: recorded interaction for %s %s is not a raw connection", method, uri)
+	}
+
+	conn := newReplayConn()
+	go func() {
+		defer func() {
+			conn.serverW.Close()
+			// Consume anything written after the recording ended so writers do not block.
+			io.Copy(io.Discard, conn.serverR)
+		}()
+		for _, ev := range i.Raw.Events {
+			if err := r.wait(ctx, ev.Delay); err != nil {
+				conn.serverW.CloseWithError(err)
+				return
+			}
+			switch ev.Direction {
+			case DirectionRead:
+				if _, err := conn.serverW.Write(ev.Data); err != nil {
+					return
+				}
+			case DirectionWrite:
+				if _, err := io.CopyN(io.Discard, conn.serverR, int64(len(ev.Data))); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return conn, nil
+}
+
+// replayConn is a net.Conn which supports CloseWrite like the connections returned by transport.Transport.
+type replayConn struct {
+	clientR *io.PipeReader
+	clientW *io.PipeWriter
+	serverR *io.PipeReader
+	serverW *io.PipeWriter
+}
+
+func newReplayConn() *replayConn {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	return &replayConn{clientR: cr, clientW: cw, serverR: sr, serverW: sw}
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.clientR.Read(p)
+}
+
+func (c *replayConn) Write(p []byte) (int, error) {
+	return c.clientW.Write(p)
+}
+
+func (c *replayConn) CloseWrite() error {
+	return c.clientW.Close()
+}
+
+func (c *replayConn) Close() error {
+	c.clientW.Close()
+	c.clientR.Close()
+	return nil
+}
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "cassette" }
+func (replayAddr) String() string  { return "cassette" }
+
+func (c *replayConn) LocalAddr() net.Addr              { return replayAddr{} }
+func (c *replayConn) RemoteAddr() net.Addr             { return replayAddr{} }
+func (c *replayConn) SetDeadline(time.Time) error      { return nil }
+func (c *replayConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *replayConn) SetWriteDeadline(time.Time) error { return nil }
@@ -2,36 +2,112 @@ package testutils
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/cpuguy83/go-docker/testutils/cassette"
 	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/transport/dockercontext"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+const (
+	// EnvCassetteMode is the environment variable used to record or replay the interactions of tests using the default test transport.
+	// Set it to "record" to save each test's interactions with the daemon, or "replay" to serve them back without a daemon.
+	// Random values the test needs, such as container names, must come from `RandomString` so they are the same on replay.
+	EnvCassetteMode = "DOCKER_TEST_CASSETTE"
+	// EnvCassetteDir is the environment variable used to set the directory cassettes are stored in.
+	// Defaults to testdata/cassettes (relative to the package being tested).
+	EnvCassetteDir = "DOCKER_TEST_CASSETTE_DIR"
 )
 
 // NewDefaultTestTransport creates a default test transport
+// The transport is resolved from the current docker context the same way the docker CLI does it.
+//
+// See EnvCassetteMode for recording and replaying tests.
 func NewDefaultTestTransport(t *testing.T, noTap bool) (*Transport, error) {
-	if os.Getenv("DOCKER_HOST") != "" || os.Getenv("DOCKER_CONTEXT") != "" {
-		t.Log("Using docker cli transport")
-		tr := transport.FromDockerCLI(func(cfg *transport.DockerCLIConnectionConfig) error {
-			cfg.StderrPipe = &testWriter{t}
-			return nil
-		})
-		return NewTransport(t, tr, noTap), nil
+	if c := testCassetteFor(t); c != nil {
+		if c.rep != nil {
+			t.Log("Using cassette replay transport")
+			return NewTransport(t, c.rep, noTap), nil
+		}
+		return NewTransport(t, c.rec, noTap), nil
 	}
+	return NewTransport(t, contextTransport(t), noTap), nil
+}
 
-	t.Log("Using system default transport")
-	tr, err := transport.DefaultTransport()
+func contextTransport(t testing.TB) transport.Doer {
+	store := dockercontext.NewStore("")
+	name, err := store.Current()
 	assert.NilError(t, err)
 
-	return NewTransport(t, tr, noTap), nil
+	t.Logf("Using docker context %q", name)
+	tr, err := store.Transport(name)
+	assert.NilError(t, err)
+	return tr
 }
 
-type testWriter struct {
-	t *testing.T
+// testCassette is the cassette a test is recorded to or replayed from.
+// Exactly one of rec or rep is set.
+type testCassette struct {
+	rec *cassette.Recorder
+	rep *cassette.Replayer
+}
+
+var (
+	testCassettesMu sync.Mutex
+	testCassettes   = map[string]*testCassette{}
+)
+
+// testCassetteFor returns the cassette of the test, which is shared by all the transports and random strings of the
+// test, or nil if tests are not being recorded or replayed.
+func testCassetteFor(t testing.TB) *testCassette {
+	t.Helper()
+
+	mode := os.Getenv(EnvCassetteMode)
+	if mode != "record" && mode != "replay" {
+		return nil
+	}
+
+	testCassettesMu.Lock()
+	defer testCassettesMu.Unlock()
+
+	if c, ok := testCassettes[t.Name()]; ok {
+		return c
+	}
+
+	c := &testCassette{}
+	p := cassettePath(t)
+	if mode == "replay" {
+		loaded, err := cassette.Load(p)
+		assert.NilError(t, err)
+		c.rep = cassette.NewReplayer(loaded)
+	} else {
+		c.rec = cassette.NewRecorder(contextTransport(t))
+	}
+	testCassettes[t.Name()] = c
+
+	t.Cleanup(func() {
+		testCassettesMu.Lock()
+		delete(testCassettes, t.Name())
+		testCassettesMu.Unlock()
+
+		if c.rec != nil {
+			assert.Check(t, c.rec.Save(p))
+			return
+		}
+		assert.Check(t, cmp.Len(c.rep.Unused(), 0), "not all recorded requests were made")
+	})
+	return c
 }
 
-func (t *testWriter) Write(p []byte) (int, error) {
-	t.t.Helper()
-	t.t.Log(string(p))
-	return len(p), nil
+func cassettePath(t testing.TB) string {
+	dir := os.Getenv(EnvCassetteDir)
+	if dir == "" {
+		dir = filepath.Join("testdata", "cassettes")
+	}
+	return filepath.Join(dir, strings.ReplaceAll(t.Name(), "/", "_")+".json")
 }
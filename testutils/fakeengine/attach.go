@@ -0,0 +1,352 @@
+package fakeengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/streamutil"
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// streamConfig configures how container output is copied to a client.
+type streamConfig struct {
+	stdout     bool
+	stderr     bool
+	timestamps bool
+	// raw disables multiplexing of stdout and stderr even if the container does not have a TTY.
+	raw   bool
+	since time.Time
+	until time.Time
+	// done is called with c.mu held after each batch of output to determine if the stream is finished.
+	done func() bool
+}
+
+// streamOutput copies the container output starting at log entry idx to w until cfg.done returns true.
+func (c *container) streamOutput(ctx context.Context, w io.Writer, idx int, cfg streamConfig) error {
+	stdout, stderr := newStdioWriters(w, c.config.Tty || cfg.raw)
+	for {
+		c.mu.Lock()
+		entries := c.logs[idx:]
+		idx = len(c.logs)
+		done := cfg.done()
+		ch := c.notify
+		c.mu.Unlock()
+
+		for _, entry := range entries {
+			if !cfg.since.IsZero() && entry.time.Before(cfg.since) {
+				continue
+			}
+			if !cfg.until.IsZero() && entry.time.After(cfg.until) {
+				continue
+			}
+
+			out := stdout
+			if entry.stream == streamutil.Stderr {
+				if !cfg.stderr {
+					continue
+				}
+				out = stderr
+			} else if !cfg.stdout {
+				continue
+			}
+
+			data := entry.data
+			if cfg.timestamps {
+				data = append([]byte(entry.time.Format(time.RFC3339Nano)+" "), data...)
+			}
+			if _, err := out.Write(data); err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// attachOptions are the query parameters of an attach request.
+type attachOptions struct {
+	stdin, stdout, stderr, logs, stream bool
+	// detachKeys ends the attach when read from stdin.
+	// Unlike the daemon, there is no default sequence.
+	detachKeys []byte
+}
+
+func parseAttachOptions(req *http.Request) (attachOptions, error) {
+	q := req.URL.Query()
+	var opts attachOptions
+	for k, v := range map[string]*bool{"stdin": &opts.stdin, "stdout": &opts.stdout, "stderr": &opts.stderr, "logs": &opts.logs, "stream": &opts.stream} {
+		*v, _ = strconv.ParseBool(q.Get(k))
+	}
+	if !opts.stream && !opts.logs {
+		return opts, errdefs.Invalid("Bad parameters: you must choose at least one stream")
+	}
+	if keys := q.Get("detachKeys"); keys != "" {
+		var err error
+		opts.detachKeys, err = parseDetachKeys(keys)
+		if err != nil {
+			return opts, errdefs.Invalidf("Invalid detach keys (%s) provided", keys)
+		}
+	}
+	return opts, nil
+}
+
+// parseDetachKeys parses a comma separated key sequence, such as "ctrl-p,ctrl-q", the same way as the daemon.
+func parseDetachKeys(s string) ([]byte, error) {
+	var keys []byte
+	for _, k := range strings.Split(s, ",") {
+		switch {
+		case len(k) == 1:
+			keys = append(keys, k[0])
+		case len(k) == len("ctrl-x") && strings.HasPrefix(k, "ctrl-"):
+			switch c := k[len(k)-1]; {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c >= '@' && c <= '_':
+				keys = append(keys, c-'@')
+			default:
+				return nil, errors.New("unknown character: " + k)
+			}
+		default:
+			return nil, errors.New("unknown character: " + k)
+		}
+	}
+	return keys, nil
+}
+
+// copyDetach copies from r to w until keys are read, which are not copied.
+// It returns true if the keys were read.
+func copyDetach(w io.Writer, r io.Reader, keys []byte) bool {
+	if len(keys) == 0 {
+		io.Copy(w, r)
+		return false
+	}
+
+	buf := make([]byte, 32*1024)
+	var matched int
+	for {
+		n, err := r.Read(buf)
+		out := make([]byte, 0, n+matched)
+		for _, b := range buf[:n] {
+			if b == keys[matched] {
+				matched++
+				if matched == len(keys) {
+					w.Write(out)
+					return true
+				}
+				continue
+			}
+			// The keys read so far were not a detach after all.
+			out = append(out, keys[:matched]...)
+			matched = 0
+			if b == keys[0] {
+				matched = 1
+				continue
+			}
+			out = append(out, b)
+		}
+		if _, werr := w.Write(out); werr != nil || err != nil {
+			return false
+		}
+	}
+}
+
+// attachPoint is the state of a container when a client attached to it.
+type attachPoint struct {
+	idx        int
+	runs       int
+	wasRunning bool
+	r          *run
+}
+
+// attachPoint must be taken before the client is told that it is attached, so that no output is missed.
+func (c *container) attachPoint(logs bool) attachPoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := attachPoint{idx: len(c.logs), runs: c.runs, wasRunning: c.current != nil, r: c.current}
+	if logs {
+		p.idx = 0
+	}
+	return p
+}
+
+func (e *Engine) handleContainerAttach(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	opts, err := parseAttachOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ct := mediaTypeMultiplexed
+	if c.config.Tty {
+		ct = mediaTypeRawStream
+	}
+
+	p := c.attachPoint(opts.logs)
+	conn, buf, err := hijack(w, req, ct)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	e.attach(c, p, opts, conn, buf, false)
+}
+
+// handleContainerAttachWebSocket is like handleContainerAttach, but over a WebSocket.
+// Like the daemon, output is never multiplexed.
+func (e *Engine) handleContainerAttachWebSocket(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	opts, err := parseAttachOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	p := c.attachPoint(opts.logs)
+	ws, err := hijackWebSocket(w, req)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	e.attach(c, p, opts, ws, ws, true)
+}
+
+// attach copies stdin from r to the container and the container output to w.
+// raw disables multiplexing of the output.
+func (e *Engine) attach(c *container, p attachPoint, opts attachOptions, w io.Writer, r io.Reader, raw bool) {
+	e.events.add("container", "attach", c.id, c.attributes())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if !opts.stdin || !c.config.OpenStdin {
+			// Nothing is expected from the client, so any read result means the client went away.
+			io.Copy(io.Discard, r)
+			cancel()
+			return
+		}
+
+		// Stdin goes to the current run or the next one if the container is not started yet.
+		run := p.r
+		if run == nil {
+			c.waitFor(ctx, func() bool { return c.runs > p.runs || c.removed })
+			c.mu.Lock()
+			run = c.current
+			c.mu.Unlock()
+		}
+		if run == nil {
+			return
+		}
+		if copyDetach(run.stdinW, r, opts.detachKeys) {
+			cancel()
+			return
+		}
+		if c.config.StdinOnce {
+			run.stdinW.Close()
+		}
+	}()
+
+	if !opts.stream {
+		c.streamOutput(ctx, w, p.idx, streamConfig{stdout: opts.stdout, stderr: opts.stderr, raw: raw, done: func() bool { return true }})
+		return
+	}
+
+	c.streamOutput(ctx, w, p.idx, streamConfig{
+		stdout: opts.stdout,
+		stderr: opts.stderr,
+		raw:    raw,
+		done: func() bool {
+			if c.removed {
+				return true
+			}
+			// Attach follows the current run, or the next one if the container is not running.
+			return c.current == nil && (p.wasRunning || c.runs > p.runs)
+		},
+	})
+}
+
+func (e *Engine) handleContainerLogs(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	q := req.URL.Query()
+	var stdout, stderr, follow, timestamps bool
+	for k, v := range map[string]*bool{"stdout": &stdout, "stderr": &stderr, "follow": &follow, "timestamps": &timestamps} {
+		*v, _ = strconv.ParseBool(q.Get(k))
+	}
+	if !stdout && !stderr {
+		writeError(w, errdefs.Invalid("Bad parameters: you must choose at least one stream"))
+		return
+	}
+
+	cfg := streamConfig{stdout: stdout, stderr: stderr, timestamps: timestamps}
+	for k, t := range map[string]*time.Time{"since": &cfg.since, "until": &cfg.until} {
+		if v := q.Get(k); v != "" && v != "0" {
+			*t, err = parseTimestamp(v)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	}
+
+	c.mu.Lock()
+	idx := 0
+	if tail := q.Get("tail"); tail != "" && tail != "all" {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 0 {
+			c.mu.Unlock()
+			writeError(w, errdefs.Invalidf("invalid tail value: %q", tail))
+			return
+		}
+		if n < len(c.logs) {
+			idx = len(c.logs) - n
+		}
+	}
+	c.mu.Unlock()
+
+	if follow {
+		cfg.done = func() bool { return c.current == nil || c.removed }
+	} else {
+		cfg.done = func() bool { return true }
+	}
+
+	ct := mediaTypeMultiplexed
+	if c.config.Tty {
+		ct = mediaTypeRawStream
+	}
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	c.streamOutput(req.Context(), &flushWriter{w}, idx, cfg)
+}
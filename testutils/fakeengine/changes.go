@@ -0,0 +1,187 @@
+package fakeengine
+
+import (
+	"archive/tar"
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+)
+
+// changeSize is the emulated size of each path added or modified in a container filesystem.
+const changeSize = 4096
+
+type containerKey struct{}
+
+// withContainer returns a context for the commands run in the container.
+func withContainer(ctx context.Context, c *container) context.Context {
+	return context.WithValue(ctx, containerKey{}, c)
+}
+
+// RecordChange records a change to the filesystem of the container running the Command which was passed ctx, so
+// that it is reported by the changes API.
+// Relative paths are resolved from the root of the container filesystem.
+// The container filesystem is not emulated: paths which were not changed before are assumed to exist in the image,
+// except when they are added.
+// The parent directories of the path are recorded as modified, like the daemon does.
+func RecordChange(ctx context.Context, p string, kind containerapi.ChangeKind) {
+	c, ok := ctx.Value(containerKey{}).(*container)
+	if !ok {
+		return
+	}
+
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.changes == nil {
+		c.changes = make(map[string]containerapi.ChangeKind)
+	}
+
+	prev, changed := c.changes[p]
+	switch kind {
+	case containerapi.ChangeDelete:
+		for k := range c.changes {
+			if strings.HasPrefix(k, p+"/") {
+				delete(c.changes, k)
+			}
+		}
+		if changed && prev == containerapi.ChangeAdd {
+			// It was never in the image
+			delete(c.changes, p)
+		} else {
+			c.changes[p] = kind
+		}
+	case containerapi.ChangeAdd:
+		switch {
+		case !changed:
+			c.changes[p] = kind
+		case prev == containerapi.ChangeDelete:
+			// It was deleted from the image and added back
+			c.changes[p] = containerapi.ChangeModify
+		}
+	default:
+		if !changed {
+			c.changes[p] = kind
+		}
+	}
+
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := c.changes[dir]; !ok {
+			c.changes[dir] = containerapi.ChangeModify
+		}
+	}
+}
+
+func (e *Engine) handleContainerChanges(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c.mu.Lock()
+	var changes []containerapi.Change
+	for p, kind := range c.changes {
+		changes = append(changes, containerapi.Change{Path: p, Kind: kind})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// sizeRw returns the emulated size of the writable layer of the container.
+// c.mu must be held.
+func (c *container) sizeRw() int64 {
+	var size int64
+	for _, kind := range c.changes {
+		if kind != containerapi.ChangeDelete {
+			size += changeSize
+		}
+	}
+	return size
+}
+
+// handleContainerExport writes a tar archive of the emulated container filesystem: a few files in /etc and the
+// paths which were added or modified, as empty files or directories, minus the deleted paths.
+func (e *Engine) handleContainerExport(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c.mu.Lock()
+	files := map[string]string{
+		"/etc/hostname": c.id[:12] + "\n",
+		"/etc/hosts":    "127.0.0.1\tlocalhost\n",
+	}
+	for p, kind := range c.changes {
+		if kind != containerapi.ChangeDelete {
+			continue
+		}
+		for f := range files {
+			if f == p || strings.HasPrefix(f, p+"/") {
+				delete(files, f)
+			}
+		}
+	}
+	for p, kind := range c.changes {
+		if _, ok := files[p]; !ok && kind != containerapi.ChangeDelete {
+			files[p] = ""
+		}
+	}
+	modTime := c.created
+	c.mu.Unlock()
+
+	// Directories are the parents of any other path.
+	dirs := map[string]bool{}
+	for p := range files {
+		for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for dir := range dirs {
+		delete(files, dir)
+	}
+
+	paths := make([]string, 0, len(files)+len(dirs))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Strings(paths)
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.WriteHeader(http.StatusOK)
+
+	tw := tar.NewWriter(w)
+	for _, p := range paths {
+		name := strings.TrimPrefix(p, "/")
+		hdr := &tar.Header{Name: name, Mode: 0o644, ModTime: modTime, Typeflag: tar.TypeReg}
+		if dirs[p] {
+			hdr.Name += "/"
+			hdr.Mode = 0o755
+			hdr.Typeflag = tar.TypeDir
+		} else {
+			hdr.Size = int64(len(files[p]))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return
+		}
+		if _, err := tw.Write([]byte(files[p])); err != nil {
+			return
+		}
+	}
+	tw.Close()
+}
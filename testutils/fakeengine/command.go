@@ -0,0 +1,180 @@
+package fakeengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+)
+
+// Stdio holds the stdio streams of an emulated command.
+type Stdio struct {
+	// Stdin is nil unless stdin is open for the container or exec.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Command is an emulated command which is run as the process of a container or an exec.
+// args includes the command name as the first element.
+//
+// The context is cancelled when a signal is sent to the container, at which point the command should return promptly.
+// Use `Signal` to get the signal that was sent.
+// The returned value is used as the exit code of the process, unless the process was killed with SIGKILL.
+type Command func(ctx context.Context, args []string, stdio Stdio) int
+
+type signalError struct {
+	sig int
+}
+
+func (e signalError) Error() string {
+	return "received signal " + strconv.Itoa(e.sig)
+}
+
+// Signal returns the signal which cancelled the context passed to a Command.
+// It returns 0 if no signal was sent.
+func Signal(ctx context.Context) int {
+	var se signalError
+	if errors.As(context.Cause(ctx), &se) {
+		return se.sig
+	}
+	return 0
+}
+
+// exitCode returns the exit code for a process which was interrupted by a signal, or code if there was no signal.
+func exitCode(ctx context.Context, code int) int {
+	if sig := Signal(ctx); sig != 0 {
+		return 128 + sig
+	}
+	return code
+}
+
+// defaultCommands returns the commands that are available in every engine.
+//
+//   - echo: writes its arguments to stdout
+//   - true, false: exit with 0 or 1
+//   - cat: copies stdin to stdout
+//   - sleep, usleep: sleep for the specified number of seconds or microseconds
+//   - sh: runs simple scripts with `sh -c`, see `Engine.shell`
+//   - touch, mkdir, rm: record changes to the container filesystem, see `RecordChange`
+//
+// Any other command blocks until the container is stopped.
+func defaultCommands() map[string]Command {
+	return map[string]Command{
+		"echo": func(_ context.Context, args []string, stdio Stdio) int {
+			io.WriteString(stdio.Stdout, strings.Join(args[1:], " ")+"\n")
+			return 0
+		},
+		"true": func(context.Context, []string, Stdio) int {
+			return 0
+		},
+		"false": func(context.Context, []string, Stdio) int {
+			return 1
+		},
+		"cat": func(ctx context.Context, _ []string, stdio Stdio) int {
+			if stdio.Stdin == nil {
+				return 0
+			}
+			if _, err := io.Copy(stdio.Stdout, stdio.Stdin); err != nil {
+				return exitCode(ctx, 1)
+			}
+			return 0
+		},
+		"sleep":  sleepCommand(time.Second),
+		"usleep": sleepCommand(time.Microsecond),
+		"touch":  changeCommand(containerapi.ChangeAdd),
+		"mkdir":  changeCommand(containerapi.ChangeAdd),
+		"rm":     changeCommand(containerapi.ChangeDelete),
+	}
+}
+
+// changeCommand records a change of the kind for each of its arguments.
+// Flags are ignored.
+func changeCommand(kind containerapi.ChangeKind) Command {
+	return func(ctx context.Context, args []string, stdio Stdio) int {
+		if len(args) < 2 {
+			io.WriteString(stdio.Stderr, args[0]+": missing operand\n")
+			return 1
+		}
+		for _, p := range args[1:] {
+			if !strings.HasPrefix(p, "-") {
+				RecordChange(ctx, p, kind)
+			}
+		}
+		return 0
+	}
+}
+
+func sleepCommand(unit time.Duration) Command {
+	return func(ctx context.Context, args []string, stdio Stdio) int {
+		if len(args) < 2 {
+			io.WriteString(stdio.Stderr, args[0]+": missing operand\n")
+			return 1
+		}
+		n, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			io.WriteString(stdio.Stderr, args[0]+": invalid number "+strconv.Quote(args[1])+"\n")
+			return 1
+		}
+		timer := time.NewTimer(time.Duration(n * float64(unit)))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return exitCode(ctx, 0)
+		case <-timer.C:
+			return 0
+		}
+	}
+}
+
+// blockingCommand is used for commands which have no emulation.
+func blockingCommand(ctx context.Context, _ []string, _ Stdio) int {
+	<-ctx.Done()
+	return exitCode(ctx, 0)
+}
+
+// lookupCommand finds the command to emulate for the provided args.
+// Commands are looked up by their base name, so "/bin/echo" runs "echo".
+func (e *Engine) lookupCommand(args []string) Command {
+	if len(args) == 0 {
+		return blockingCommand
+	}
+	name := args[0]
+	if cmd, ok := e.cfg.Commands[name]; ok {
+		return cmd
+	}
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		if cmd, ok := e.cfg.Commands[name[idx+1:]]; ok {
+			return cmd
+		}
+	}
+	return blockingCommand
+}
+
+var signals = map[string]int{
+	"HUP":  1,
+	"INT":  2,
+	"QUIT": 3,
+	"KILL": 9,
+	"USR1": 10,
+	"USR2": 12,
+	"TERM": 15,
+}
+
+const (
+	sigKill = 9
+	sigTerm = 15
+)
+
+// parseSignal parses a signal the same way the daemon does, e.g. "SIGKILL", "KILL", or "9".
+func parseSignal(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n > 0
+	}
+	n, ok := signals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]
+	return n, ok
+}
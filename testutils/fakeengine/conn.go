@@ -0,0 +1,197 @@
+package fakeengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeListener is a net.Listener for in-memory connections.
+type pipeListener struct {
+	conns     chan net.Conn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// Dial creates a new connection to the listener.
+func (l *pipeListener) Dial(ctx context.Context) (net.Conn, error) {
+	client, server := newPipeConn()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, errors.New("fakeengine: engine is closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "fakeengine" }
+
+// pipeConn is an in-memory net.Conn which behaves like a TCP or unix socket:
+// writes are buffered instead of waiting for the peer to read them, and the write side can be closed independently.
+// net.Pipe does neither, which deadlocks HTTP clients that are still writing the request when the response is sent.
+type pipeConn struct {
+	r *pipeBuffer
+	w *pipeBuffer
+}
+
+func newPipeConn() (*pipeConn, *pipeConn) {
+	b1, b2 := newPipeBuffer(), newPipeBuffer()
+	return &pipeConn{r: b1, w: b2}, &pipeConn{r: b2, w: b1}
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c *pipeConn) CloseWrite() error {
+	c.w.closeWrite()
+	return nil
+}
+
+func (c *pipeConn) Close() error {
+	c.w.closeWrite()
+	c.r.closeRead()
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr{} }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr{} }
+
+// SetDeadline sets the read deadline.
+// Writes never block, so there is no write deadline.
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	return c.r.setReadDeadline(t)
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return c.r.setReadDeadline(t)
+}
+
+func (c *pipeConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// pipeBuffer holds the data written in one direction of a pipeConn.
+type pipeBuffer struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	eof      bool
+	closed   bool
+	deadline time.Time
+	// notify is closed and replaced whenever the state changes, to wake up readers.
+	notify chan struct{}
+}
+
+func newPipeBuffer() *pipeBuffer {
+	return &pipeBuffer{notify: make(chan struct{})}
+}
+
+// signal must be called with mu held.
+func (b *pipeBuffer) signal() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+func (b *pipeBuffer) Read(p []byte) (int, error) {
+	for {
+		b.mu.Lock()
+		switch {
+		case b.closed:
+			b.mu.Unlock()
+			return 0, net.ErrClosed
+		case !b.deadline.IsZero() && !time.Now().Before(b.deadline):
+			b.mu.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		case b.buf.Len() > 0:
+			n, _ := b.buf.Read(p)
+			b.mu.Unlock()
+			return n, nil
+		case b.eof:
+			b.mu.Unlock()
+			return 0, io.EOF
+		}
+		notify, deadline := b.notify, b.deadline
+		b.mu.Unlock()
+
+		if deadline.IsZero() {
+			<-notify
+			continue
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (b *pipeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.eof || b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	b.buf.Write(p)
+	b.signal()
+	return len(p), nil
+}
+
+func (b *pipeBuffer) closeWrite() {
+	b.mu.Lock()
+	b.eof = true
+	b.signal()
+	b.mu.Unlock()
+}
+
+func (b *pipeBuffer) closeRead() {
+	b.mu.Lock()
+	b.closed = true
+	b.buf.Reset()
+	b.signal()
+	b.mu.Unlock()
+}
+
+func (b *pipeBuffer) setReadDeadline(t time.Time) error {
+	b.mu.Lock()
+	b.deadline = t
+	b.signal()
+	b.mu.Unlock()
+	return nil
+}
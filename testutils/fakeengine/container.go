@@ -0,0 +1,451 @@
+package fakeengine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/container/streamutil"
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+const zeroTime = "0001-01-01T00:00:00Z"
+
+// logEntry is a single line of output from a container process.
+type logEntry struct {
+	stream int
+	data   []byte
+	time   time.Time
+}
+
+// container is the state of an emulated container.
+type container struct {
+	e *Engine
+
+	id         string
+	name       string
+	created    time.Time
+	imageID    string
+	path       string
+	args       []string
+	config     containerapi.Config
+	hostConfig containerapi.HostConfig
+
+	mu      sync.Mutex
+	state   containerapi.ContainerState
+	current *run
+	removed bool
+	// runs is the number of times the container was started, exits the number of times it exited.
+	runs  int
+	exits int
+	logs  []logEntry
+	execs []string
+	// changes are the changes to the container filesystem, see RecordChange.
+	changes map[string]containerapi.ChangeKind
+	// notify is closed and replaced whenever anything about the container changes.
+	notify chan struct{}
+}
+
+// run is a single execution of the container process.
+type run struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+	// execs tracks the exec processes running during this run.
+	execs  sync.WaitGroup
+	stdinR *io.PipeReader
+	stdinW *io.PipeWriter
+}
+
+func newContainer(e *Engine, id, name string) *container {
+	return &container{
+		e:       e,
+		id:      id,
+		name:    name,
+		created: now(),
+		state:   containerapi.ContainerState{Status: "created", StartedAt: zeroTime, FinishedAt: zeroTime},
+		notify:  make(chan struct{}),
+	}
+}
+
+// broadcast wakes up everything waiting on a change to the container.
+// c.mu must be held.
+func (c *container) broadcast() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// waitFor blocks until cond returns true or the context is cancelled.
+// cond is called with c.mu held.
+func (c *container) waitFor(ctx context.Context, cond func() bool) bool {
+	for {
+		c.mu.Lock()
+		ok := cond()
+		ch := c.notify
+		c.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (c *container) attributes() map[string]string {
+	attrs := map[string]string{"name": c.name, "image": c.config.Image}
+	for k, v := range c.config.Labels {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+func (c *container) start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return errdefs.NotFound("No such container: " + c.id)
+	}
+	if c.current != nil {
+		return errdefs.NotModified("container already started")
+	}
+
+	ctx, cancel := context.WithCancelCause(withContainer(context.Background(), c))
+	r := &run{cancel: cancel, done: make(chan struct{})}
+
+	stdio := Stdio{
+		Stdout: &logWriter{c: c, r: r, stream: streamutil.Stdout},
+		Stderr: &logWriter{c: c, r: r, stream: streamutil.Stderr},
+	}
+	if c.config.Tty {
+		// A TTY only has one output stream
+		stdio.Stderr = stdio.Stdout
+	}
+	if c.config.OpenStdin {
+		r.stdinR, r.stdinW = io.Pipe()
+		stdio.Stdin = r.stdinR
+	}
+
+	c.current = r
+	c.runs++
+	c.state = containerapi.ContainerState{
+		Status:     "running",
+		Running:    true,
+		Pid:        c.e.newPid(),
+		StartedAt:  now().Format(time.RFC3339Nano),
+		FinishedAt: zeroTime,
+	}
+	c.broadcast()
+	c.e.events.add("container", "start", c.id, c.attributes())
+
+	args := append([]string{c.path}, c.args...)
+	cmd := c.e.lookupCommand(args)
+	go func() {
+		code := cmd(ctx, args, stdio)
+		c.mu.Lock()
+		c.finish(r, code)
+		c.mu.Unlock()
+	}()
+	return nil
+}
+
+// finish records the exit of the run.
+// It is a no-op if the run already finished, e.g. it was killed with SIGKILL before the command returned.
+// c.mu must be held.
+func (c *container) finish(r *run, code int) {
+	if c.current != r {
+		return
+	}
+
+	r.cancel(nil)
+	if r.stdinR != nil {
+		r.stdinR.CloseWithError(io.ErrClosedPipe)
+	}
+	close(r.done)
+
+	c.current = nil
+	c.exits++
+	c.state.Status = "exited"
+	c.state.Running = false
+	c.state.Paused = false
+	c.state.Pid = 0
+	c.state.ExitCode = code
+	c.state.FinishedAt = now().Format(time.RFC3339Nano)
+	c.broadcast()
+
+	attrs := c.attributes()
+	attrs["exitCode"] = strconv.Itoa(code)
+	c.e.events.add("container", "die", c.id, attrs)
+
+	if c.hostConfig.AutoRemove {
+		go c.e.removeContainer(c)
+	}
+}
+
+// kill sends a signal to the container process.
+// SIGKILL stops the process immediately, even if the command does not return.
+func (c *container) kill(sig int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.current
+	if r == nil {
+		return errdefs.Conflictf("Container %s is not running", c.id)
+	}
+
+	attrs := c.attributes()
+	attrs["signal"] = strconv.Itoa(sig)
+	c.e.events.add("container", "kill", c.id, attrs)
+
+	r.cancel(signalError{sig})
+	if r.stdinR != nil {
+		r.stdinR.CloseWithError(io.ErrClosedPipe)
+	}
+	if sig == sigKill {
+		c.finish(r, 128+sigKill)
+	}
+	return nil
+}
+
+// stop sends the stop signal to the container process and kills it if it does not exit within the timeout.
+// A sig of 0 uses the stop signal of the container.
+func (c *container) stop(ctx context.Context, sig int, timeout *time.Duration) error {
+	c.mu.Lock()
+	r := c.current
+	if sig == 0 {
+		sig = sigTerm
+		if s, ok := parseSignal(c.config.StopSignal); ok {
+			sig = s
+		}
+	}
+	if timeout == nil {
+		d := 10 * time.Second
+		if c.config.StopTimeout != nil {
+			d = time.Duration(*c.config.StopTimeout) * time.Second
+		}
+		timeout = &d
+	}
+	c.mu.Unlock()
+
+	if r == nil {
+		return errdefs.NotModified("container already stopped")
+	}
+
+	if err := c.kill(sig); err != nil {
+		return err
+	}
+
+	if *timeout >= 0 {
+		timer := time.NewTimer(*timeout)
+		defer timer.Stop()
+		select {
+		case <-r.done:
+		case <-timer.C:
+			c.kill(sigKill)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.execs.Wait()
+
+	c.e.events.add("container", "stop", c.id, c.attributes())
+	return nil
+}
+
+// setPaused pauses or unpauses the container.
+// The emulated process keeps running, only the state changes.
+func (c *container) setPaused(paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		return errdefs.Conflictf("Container %s is not running", c.id)
+	}
+	if c.state.Paused == paused {
+		if paused {
+			return errdefs.Conflictf("Container %s is already paused", c.id)
+		}
+		return errdefs.Conflictf("Container %s is not paused", c.id)
+	}
+
+	c.state.Paused = paused
+	action := "unpause"
+	c.state.Status = "running"
+	if paused {
+		action = "pause"
+		c.state.Status = "paused"
+	}
+	c.broadcast()
+	c.e.events.add("container", action, c.id, c.attributes())
+	return nil
+}
+
+// resize resizes the TTY of the container.
+// The emulated process has no TTY to resize, only an event is emitted.
+func (c *container) resize(width, height int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		return errdefs.Conflictf("Container %s is not running", c.id)
+	}
+
+	attrs := c.attributes()
+	attrs["width"] = strconv.Itoa(width)
+	attrs["height"] = strconv.Itoa(height)
+	c.e.events.add("container", "resize", c.id, attrs)
+	return nil
+}
+
+// minMemoryLimit is the minimum memory limit allowed by the daemon.
+const minMemoryLimit = 6 << 20
+
+// update applies the non-zero resources, and the restart policy if it has a name, to the container.
+// Like the daemon, a warning is returned for the deprecated kernel memory limit.
+func (c *container) update(r containerapi.Resources, policy containerapi.RestartPolicy) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return nil, errdefs.NotFound("No such container: " + c.id)
+	}
+	if r.Memory != 0 && r.Memory < minMemoryLimit {
+		return nil, errdefs.Invalid("Minimum memory limit allowed is 6MB")
+	}
+	if policy.Name != "" {
+		if err := validateRestartPolicy(policy); err != nil {
+			return nil, err
+		}
+		if c.hostConfig.AutoRemove && policy.Name != "no" {
+			return nil, errdefs.Invalid("Restart policy cannot be updated because AutoRemove is enabled for the container")
+		}
+	}
+
+	var warnings []string
+	if r.KernelMemory != 0 {
+		warnings = append(warnings, "Specifying a kernel memory limit is deprecated and will be removed in a future release.")
+	}
+
+	hc := &c.hostConfig.Resources
+	setInt := func(dst *int64, v int64) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	setInt(&hc.CPUShares, r.CPUShares)
+	setInt(&hc.Memory, r.Memory)
+	setInt(&hc.NanoCPUs, r.NanoCPUs)
+	setInt(&hc.CPUPeriod, r.CPUPeriod)
+	setInt(&hc.CPUQuota, r.CPUQuota)
+	setInt(&hc.CPURealtimePeriod, r.CPURealtimePeriod)
+	setInt(&hc.CPURealtimeRuntime, r.CPURealtimeRuntime)
+	setInt(&hc.KernelMemory, r.KernelMemory)
+	setInt(&hc.KernelMemoryTCP, r.KernelMemoryTCP)
+	setInt(&hc.MemoryReservation, r.MemoryReservation)
+	setInt(&hc.MemorySwap, r.MemorySwap)
+	if r.BlkioWeight != 0 {
+		hc.BlkioWeight = r.BlkioWeight
+	}
+	if r.CpusetCpus != "" {
+		hc.CpusetCpus = r.CpusetCpus
+	}
+	if r.CpusetMems != "" {
+		hc.CpusetMems = r.CpusetMems
+	}
+	if r.PidsLimit != nil {
+		limit := *r.PidsLimit
+		hc.PidsLimit = &limit
+	}
+	if policy.Name != "" {
+		c.hostConfig.RestartPolicy = policy
+	}
+	c.broadcast()
+
+	c.e.events.add("container", "update", c.id, c.attributes())
+	return warnings, nil
+}
+
+func validateRestartPolicy(p containerapi.RestartPolicy) error {
+	switch p.Name {
+	case "no", "always", "unless-stopped":
+		if p.MaximumRetryCount != 0 {
+			return errdefs.Invalidf("invalid restart policy: maximum retry count can only be used with 'on-failure'")
+		}
+	case "on-failure":
+		if p.MaximumRetryCount < 0 {
+			return errdefs.Invalid("invalid restart policy: maximum retry count cannot be negative")
+		}
+	default:
+		return errdefs.Invalidf("invalid restart policy: unknown policy '%s'; use one of 'no', 'always', 'on-failure', or 'unless-stopped'", p.Name)
+	}
+	return nil
+}
+
+// logWriter is the stdout or stderr of a container process.
+// Output is split into lines, which is how the daemon stores logs.
+type logWriter struct {
+	c      *container
+	r      *run
+	stream int
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+
+	if w.c.current != w.r {
+		return 0, io.ErrClosedPipe
+	}
+
+	t := now()
+	for data := p; len(data) > 0; {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		w.c.logs = append(w.c.logs, logEntry{stream: w.stream, data: append([]byte(nil), data[:n]...), time: t})
+		data = data[n:]
+	}
+	w.c.broadcast()
+	return len(p), nil
+}
+
+func (c *container) inspect() containerapi.ContainerInspect {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.state
+	config := c.config
+	hostConfig := c.hostConfig
+	return containerapi.ContainerInspect{
+		ID:         c.id,
+		Created:    c.created.Format(time.RFC3339Nano),
+		Path:       c.path,
+		Args:       c.args,
+		State:      &state,
+		Image:      c.imageID,
+		Name:       "/" + c.name,
+		Driver:     "overlay2",
+		Platform:   c.e.cfg.OSType,
+		ExecIDs:    append([]string(nil), c.execs...),
+		Config:     &config,
+		HostConfig: &hostConfig,
+		GraphDriver: containerapi.GraphDriverData{
+			Name: "overlay2",
+			Data: map[string]string{},
+		},
+		NetworkSettings: &containerapi.NetworkSettings{},
+	}
+}
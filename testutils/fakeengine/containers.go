@@ -0,0 +1,602 @@
+package fakeengine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+var validContainerName = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// containerSpec is the body of a container create request.
+type containerSpec struct {
+	containerapi.Config
+	HostConfig       containerapi.HostConfig
+	NetworkingConfig containerapi.NetworkingConfig
+}
+
+type containerCreateResponse struct {
+	ID       string `json:"Id"`
+	Warnings []string
+}
+
+func (e *Engine) handleContainerCreate(w http.ResponseWriter, req *http.Request) {
+	var spec containerSpec
+	if err := decodeBody(req, &spec); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c, err := e.createContainer(req.URL.Query().Get("name"), spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, containerCreateResponse{ID: c.id, Warnings: []string{}})
+}
+
+func (e *Engine) createContainer(name string, spec containerSpec) (*container, error) {
+	if spec.Image == "" {
+		return nil, errdefs.Invalid("config cannot be empty in order to create a container")
+	}
+	if name != "" && !validContainerName.MatchString(name) {
+		return nil, errdefs.Invalidf("Invalid container name (%s), only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	name = strings.TrimPrefix(name, "/")
+
+	img, err := e.getImage(spec.Image)
+	if err != nil {
+		return nil, errdefs.NotFound("No such image: " + spec.Image)
+	}
+
+	cmd := spec.Cmd
+	if len(cmd) == 0 && len(spec.Entrypoint) == 0 {
+		cmd = img.Config.Cmd
+	}
+	entrypoint := spec.Entrypoint
+	if len(entrypoint) == 0 {
+		entrypoint = img.Config.Entrypoint
+	}
+	argv := append(append([]string(nil), entrypoint...), cmd...)
+	if len(argv) == 0 {
+		return nil, errdefs.Invalid("No command specified")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := newID()
+	if name == "" {
+		name = "fake_" + id[:12]
+	}
+	if existing, ok := e.names[name]; ok {
+		return nil, errdefs.Conflictf("Conflict. The container name \"/%s\" is already in use by container \"%s\". You have to remove (or rename) that container to be able to reuse that name.", name, existing.id)
+	}
+
+	c := newContainer(e, id, name)
+	c.imageID = img.ID
+	c.path = argv[0]
+	c.args = argv[1:]
+	c.config = spec.Config
+	c.config.Cmd = cmd
+	c.config.Entrypoint = entrypoint
+	if len(c.config.Env) == 0 {
+		c.config.Env = img.Config.Env
+	}
+	if c.config.WorkingDir == "" {
+		c.config.WorkingDir = img.Config.WorkingDir
+	}
+	c.hostConfig = spec.HostConfig
+
+	e.containers[id] = c
+	e.names[name] = c
+	e.events.add("container", "create", c.id, c.attributes())
+	return c, nil
+}
+
+// getContainer finds a container by its full ID, name, or a unique ID prefix.
+func (e *Engine) getContainer(ref string) (*container, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, ok := e.containers[ref]; ok {
+		return c, nil
+	}
+	if c, ok := e.names[strings.TrimPrefix(ref, "/")]; ok {
+		return c, nil
+	}
+
+	var found *container
+	if ref != "" {
+		for id, c := range e.containers {
+			if !strings.HasPrefix(id, ref) {
+				continue
+			}
+			if found != nil {
+				return nil, errdefs.Invalidf("multiple IDs found with provided prefix: %s", ref)
+			}
+			found = c
+		}
+	}
+	if found == nil {
+		return nil, errdefs.NotFound("No such container: " + ref)
+	}
+	return found, nil
+}
+
+func (e *Engine) removeContainer(c *container) {
+	e.mu.Lock()
+	if e.containers[c.id] == c {
+		delete(e.containers, c.id)
+		delete(e.names, c.name)
+	}
+	for _, id := range c.execs {
+		delete(e.execs, id)
+	}
+	e.mu.Unlock()
+
+	c.mu.Lock()
+	if !c.removed {
+		c.removed = true
+		c.broadcast()
+		e.events.add("container", "destroy", c.id, c.attributes())
+	}
+	c.mu.Unlock()
+}
+
+func (e *Engine) handleContainerInspect(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.inspect())
+}
+
+func (e *Engine) handleContainerStart(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := c.start(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseStopOptions parses the query parameters of the stop and restart endpoints.
+// A sig of 0 means the stop signal of the container should be used.
+func parseStopOptions(req *http.Request) (sig int, timeout *time.Duration, _ error) {
+	q := req.URL.Query()
+	for _, k := range []string{"t", "timeout"} {
+		if v := q.Get(k); v != "" {
+			secs, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, nil, errdefs.Invalidf("invalid value for %s: %v", k, err)
+			}
+			d := time.Duration(secs) * time.Second
+			timeout = &d
+		}
+	}
+	if s := q.Get("signal"); s != "" {
+		var ok bool
+		sig, ok = parseSignal(s)
+		if !ok {
+			return 0, nil, errdefs.Invalidf("Invalid signal: %s", s)
+		}
+	}
+	return sig, timeout, nil
+}
+
+func (e *Engine) handleContainerStop(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sig, timeout, err := parseStopOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := c.stop(req.Context(), sig, timeout); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerRestart(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sig, timeout, err := parseStopOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := c.stop(req.Context(), sig, timeout); err != nil && !errdefs.IsNotModified(err) {
+		writeError(w, err)
+		return
+	}
+	if err := c.start(); err != nil {
+		writeError(w, err)
+		return
+	}
+	c.e.events.add("container", "restart", c.id, c.attributes())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerPause(w http.ResponseWriter, req *http.Request) {
+	e.handleContainerSetPaused(w, req, true)
+}
+
+func (e *Engine) handleContainerUnpause(w http.ResponseWriter, req *http.Request) {
+	e.handleContainerSetPaused(w, req, false)
+}
+
+func (e *Engine) handleContainerSetPaused(w http.ResponseWriter, req *http.Request, paused bool) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := c.setPaused(paused); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerRename(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := e.renameContainer(c, req.URL.Query().Get("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) renameContainer(c *container, name string) error {
+	if !validContainerName.MatchString(name) {
+		return errdefs.Invalidf("Invalid container name (%s), only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	name = strings.TrimPrefix(name, "/")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.name == name {
+		return errdefs.Invalid("Renaming a container with the same name as its current name")
+	}
+	if existing, ok := e.names[name]; ok {
+		return errdefs.Conflictf("Conflict. The container name \"/%s\" is already in use by container \"%s\". You have to remove (or rename) that container to be able to reuse that name.", name, existing.id)
+	}
+
+	oldName := c.name
+	delete(e.names, oldName)
+	e.names[name] = c
+	c.name = name
+	c.broadcast()
+
+	attrs := c.attributes()
+	attrs["oldName"] = "/" + oldName
+	e.events.add("container", "rename", c.id, attrs)
+	return nil
+}
+
+func (e *Engine) handleContainerResize(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	q := req.URL.Query()
+	width, err := strconv.Atoi(q.Get("w"))
+	if err != nil {
+		writeError(w, errdefs.Invalidf("invalid value for w: %v", err))
+		return
+	}
+	height, err := strconv.Atoi(q.Get("h"))
+	if err != nil {
+		writeError(w, errdefs.Invalidf("invalid value for h: %v", err))
+		return
+	}
+
+	if err := c.resize(width, height); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// containerUpdate is the body of a container update request.
+type containerUpdate struct {
+	containerapi.Resources
+	RestartPolicy containerapi.RestartPolicy
+}
+
+type containerUpdateResponse struct {
+	Warnings []string
+}
+
+func (e *Engine) handleContainerUpdate(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var u containerUpdate
+	if err := decodeBody(req, &u); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	warnings, err := c.update(u.Resources, u.RestartPolicy)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, containerUpdateResponse{Warnings: warnings})
+}
+
+func (e *Engine) handleContainerKill(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sig := sigKill
+	if s := req.URL.Query().Get("signal"); s != "" {
+		var ok bool
+		sig, ok = parseSignal(s)
+		if !ok {
+			writeError(w, errdefs.Invalidf("Invalid signal: %s", s))
+			return
+		}
+	}
+
+	if err := c.kill(sig); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Engine) handleContainerRemove(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	force, _ := strconv.ParseBool(req.URL.Query().Get("force"))
+
+	c.mu.Lock()
+	running := c.current != nil
+	c.mu.Unlock()
+
+	if running {
+		if !force {
+			writeError(w, errdefs.Conflictf("You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.id))
+			return
+		}
+		c.kill(sigKill)
+	}
+
+	e.removeContainer(c)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type containerPruneResponse struct {
+	ContainersDeleted []string
+	SpaceReclaimed    int64
+}
+
+// handleContainerPrune removes the stopped containers which match the "until", "label" and "label!" filters.
+func (e *Engine) handleContainerPrune(w http.ResponseWriter, req *http.Request) {
+	f, err := parseFilters(req.URL.Query().Get("filters"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := f.validate("label", "label!", "until"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var until time.Time
+	if f.has("until") {
+		v := f["until"][0]
+		if d, err := time.ParseDuration(v); err == nil {
+			until = now().Add(-d)
+		} else if until, err = parseTimestamp(v); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	e.mu.Lock()
+	containers := make([]*container, 0, len(e.containers))
+	for _, c := range e.containers {
+		containers = append(containers, c)
+	}
+	e.mu.Unlock()
+
+	var resp containerPruneResponse
+	for _, c := range containers {
+		c.mu.Lock()
+		prune := c.current == nil && !c.removed &&
+			(until.IsZero() || c.created.Before(until)) &&
+			f.matchLabels(c.config.Labels) && f.matchNotLabels(c.config.Labels)
+		size := c.sizeRw()
+		c.mu.Unlock()
+		if !prune {
+			continue
+		}
+
+		e.removeContainer(c)
+		resp.ContainersDeleted = append(resp.ContainersDeleted, c.id)
+		resp.SpaceReclaimed += size
+	}
+	sort.Strings(resp.ContainersDeleted)
+
+	e.events.add("container", "prune", "", map[string]string{"reclaimed": strconv.FormatInt(resp.SpaceReclaimed, 10)})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type waitResponse struct {
+	StatusCode int
+	Error      *waitError `json:",omitempty"`
+}
+
+type waitError struct {
+	Message string
+}
+
+func (e *Engine) handleContainerWait(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c.mu.Lock()
+	exits := c.exits
+	c.mu.Unlock()
+
+	var cond func() bool
+	switch req.URL.Query().Get("condition") {
+	case "", "not-running":
+		cond = func() bool { return c.current == nil || c.removed }
+	case "next-exit":
+		cond = func() bool { return c.exits > exits || c.removed }
+	case "removed":
+		cond = func() bool { return c.removed }
+	default:
+		writeError(w, errdefs.Invalidf("invalid condition: %q", req.URL.Query().Get("condition")))
+		return
+	}
+
+	// Send the headers right away, like the daemon, so clients know the wait is in place.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	if !c.waitFor(req.Context(), cond) {
+		return
+	}
+
+	c.mu.Lock()
+	code := c.state.ExitCode
+	c.mu.Unlock()
+	json.NewEncoder(w).Encode(waitResponse{StatusCode: code})
+}
+
+func (e *Engine) handleContainerList(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	all, _ := strconv.ParseBool(q.Get("all"))
+	limit := -1
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			writeError(w, errdefs.Invalidf("invalid limit: %v", err))
+			return
+		}
+	}
+
+	f, err := parseFilters(q.Get("filters"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := f.validate("ancestor", "exited", "id", "label", "name", "status"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e.mu.Lock()
+	containers := make([]*container, 0, len(e.containers))
+	for _, c := range e.containers {
+		containers = append(containers, c)
+	}
+	e.mu.Unlock()
+
+	// Newest first, like the daemon.
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].created.After(containers[j].created)
+	})
+
+	list := []containerapi.Container{}
+	for _, c := range containers {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+
+		info := c.inspect()
+		if !all && !info.State.Running && !f.has("status") && !f.has("exited") {
+			continue
+		}
+		if !f.match("id", func(v string) bool { return strings.HasPrefix(c.id, v) }) ||
+			!f.match("name", func(v string) bool { return strings.Contains(c.name, strings.TrimPrefix(v, "/")) }) ||
+			!f.match("status", func(v string) bool { return v == info.State.Status }) ||
+			!f.match("exited", func(v string) bool { return info.State.Status == "exited" && v == strconv.Itoa(info.State.ExitCode) }) ||
+			!f.match("ancestor", func(v string) bool { return e.imageMatches(c.imageID, v) }) ||
+			!f.matchLabels(c.config.Labels) {
+			continue
+		}
+
+		list = append(list, containerapi.Container{
+			ID:         c.id,
+			Names:      []string{info.Name},
+			Created:    int(c.created.Unix()),
+			Path:       c.path,
+			Args:       c.args,
+			State:      info.State.Status,
+			Image:      c.config.Image,
+			ImageID:    c.imageID,
+			Command:    strings.Join(append([]string{c.path}, c.args...), " "),
+			Labels:     c.config.Labels,
+			HostConfig: &containerapi.HostConfig{NetworkMode: c.hostConfig.NetworkMode},
+			Mounts:     []containerapi.MountPoint{},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,380 @@
+// Package fakeengine provides an in-process fake of the Docker Engine API for unit tests.
+//
+// The fake keeps its state in memory and emulates enough of the API to exercise the
+// container, image, and system services of this module without a daemon:
+// container create/start/stop/restart/kill/pause/unpause/rename/resize/update/wait/inspect/
+// list/remove/prune, attach (also over a WebSocket), logs, stats, top, changes, export,
+// exec, image list/pull/remove, events, ping, and version.
+//
+// Containers do not run real processes. Instead the container command is looked up
+// in a table of emulated commands (see `Command` and `WithCommand`). Commands which
+// are not found block until the container is stopped or killed, like a long running service.
+//
+// Use `Engine.Doer` to get an in-memory transport.Doer, or `Engine.Serve` to serve the API
+// on any net.Listener (e.g. a unix socket).
+package fakeengine
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+)
+
+const (
+	// DefaultAPIVersion is the default maximum API version served by the engine.
+	DefaultAPIVersion = "1.41"
+	// DefaultMinAPIVersion is the default minimum API version served by the engine.
+	DefaultMinAPIVersion = "1.12"
+)
+
+// Config holds the options for an Engine.
+type Config struct {
+	// APIVersion is the maximum API version supported by the engine.
+	APIVersion string
+	// MinAPIVersion is the minimum API version supported by the engine.
+	MinAPIVersion string
+	// OSType is reported by /_ping and /version.
+	OSType string
+	// Commands are the emulated commands that containers and execs can run, keyed by name.
+	// These are added to the default commands, see `Command`.
+	Commands map[string]Command
+	// Pull is called to resolve the image for a pull request.
+	// The default creates a new image for any reference.
+	Pull func(ref string) (Image, error)
+}
+
+// Option is used as functional arguments to `New`.
+type Option func(*Config)
+
+// WithAPIVersion sets the range of API versions supported by the engine.
+func WithAPIVersion(min, max string) Option {
+	return func(cfg *Config) {
+		cfg.MinAPIVersion = min
+		cfg.APIVersion = max
+	}
+}
+
+// WithCommand adds an emulated command which containers and execs can run.
+func WithCommand(name string, cmd Command) Option {
+	return func(cfg *Config) {
+		if cfg.Commands == nil {
+			cfg.Commands = make(map[string]Command)
+		}
+		cfg.Commands[name] = cmd
+	}
+}
+
+// WithPull sets the function used to resolve images for pull requests.
+// Return an error wrapped with `errdefs.NotFound` to emulate an image which does not exist in the registry.
+func WithPull(f func(ref string) (Image, error)) Option {
+	return func(cfg *Config) {
+		cfg.Pull = f
+	}
+}
+
+// Engine is an in-memory fake of the Docker Engine API.
+// It implements http.Handler.
+type Engine struct {
+	cfg     Config
+	mux     *http.ServeMux
+	handler http.Handler
+
+	mu         sync.Mutex
+	containers map[string]*container
+	names      map[string]*container
+	images     map[string]*Image
+	execs      map[string]*exec
+
+	events *eventLog
+	pid    atomic.Int64
+
+	listener *pipeListener
+	srv      *http.Server
+	servers  []*http.Server
+	closed   bool
+}
+
+// New creates a new Engine.
+// Call `Close` when done with it.
+func New(opts ...Option) *Engine {
+	cfg := Config{
+		APIVersion:    DefaultAPIVersion,
+		MinAPIVersion: DefaultMinAPIVersion,
+		OSType:        "linux",
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if cfg.Pull == nil {
+		cfg.Pull = defaultPull
+	}
+
+	e := &Engine{
+		mux:        http.NewServeMux(),
+		containers: make(map[string]*container),
+		names:      make(map[string]*container),
+		images:     make(map[string]*Image),
+		execs:      make(map[string]*exec),
+		events:     newEventLog(),
+	}
+
+	commands := defaultCommands()
+	commands["sh"] = e.shell
+	for k, v := range cfg.Commands {
+		commands[k] = v
+	}
+	cfg.Commands = commands
+	e.cfg = cfg
+
+	e.registerRoutes()
+	e.handler = e.withVersion(e.mux)
+	return e
+}
+
+func (e *Engine) registerRoutes() {
+	e.mux.HandleFunc("GET /_ping", e.handlePing)
+	e.mux.HandleFunc("HEAD /_ping", e.handlePing)
+	e.mux.HandleFunc("GET /version", e.handleVersion)
+	e.mux.HandleFunc("GET /events", e.handleEvents)
+
+	e.mux.HandleFunc("POST /containers/create", e.handleContainerCreate)
+	e.mux.HandleFunc("GET /containers/json", e.handleContainerList)
+	e.mux.HandleFunc("POST /containers/prune", e.handleContainerPrune)
+	e.mux.HandleFunc("GET /containers/{id}/json", e.handleContainerInspect)
+	e.mux.HandleFunc("POST /containers/{id}/start", e.handleContainerStart)
+	e.mux.HandleFunc("POST /containers/{id}/stop", e.handleContainerStop)
+	e.mux.HandleFunc("POST /containers/{id}/kill", e.handleContainerKill)
+	e.mux.HandleFunc("POST /containers/{id}/restart", e.handleContainerRestart)
+	e.mux.HandleFunc("POST /containers/{id}/pause", e.handleContainerPause)
+	e.mux.HandleFunc("POST /containers/{id}/unpause", e.handleContainerUnpause)
+	e.mux.HandleFunc("POST /containers/{id}/rename", e.handleContainerRename)
+	e.mux.HandleFunc("POST /containers/{id}/resize", e.handleContainerResize)
+	e.mux.HandleFunc("POST /containers/{id}/update", e.handleContainerUpdate)
+	e.mux.HandleFunc("POST /containers/{id}/wait", e.handleContainerWait)
+	e.mux.HandleFunc("POST /containers/{id}/attach", e.handleContainerAttach)
+	e.mux.HandleFunc("GET /containers/{id}/attach/ws", e.handleContainerAttachWebSocket)
+	e.mux.HandleFunc("GET /containers/{id}/logs", e.handleContainerLogs)
+	e.mux.HandleFunc("GET /containers/{id}/stats", e.handleContainerStats)
+	e.mux.HandleFunc("GET /containers/{id}/top", e.handleContainerTop)
+	e.mux.HandleFunc("GET /containers/{id}/changes", e.handleContainerChanges)
+	e.mux.HandleFunc("GET /containers/{id}/export", e.handleContainerExport)
+	e.mux.HandleFunc("DELETE /containers/{id}", e.handleContainerRemove)
+
+	e.mux.HandleFunc("POST /containers/{id}/exec", e.handleExecCreate)
+	e.mux.HandleFunc("POST /exec/{id}/start", e.handleExecStart)
+	e.mux.HandleFunc("POST /exec/{id}/resize", e.handleExecResize)
+	e.mux.HandleFunc("GET /exec/{id}/json", e.handleExecInspect)
+
+	e.mux.HandleFunc("GET /images/json", e.handleImageList)
+	e.mux.HandleFunc("POST /images/create", e.handleImagePull)
+	e.mux.HandleFunc("GET /images/{name...}", e.handleImageInspect)
+	e.mux.HandleFunc("DELETE /images/{name...}", e.handleImageRemove)
+}
+
+var versionPrefix = regexp.MustCompile(`^/v([0-9.]+)(/|$)`)
+
+// withVersion strips the API version prefix from request paths and validates it against the supported versions.
+func (e *Engine) withVersion(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m := versionPrefix.FindStringSubmatch(req.URL.Path); m != nil {
+			v := m[1]
+			if version.LessThan(e.cfg.APIVersion, v) {
+				writeError(w, errdefs.Invalidf("client version %s is too new. Maximum supported API version is %s", v, e.cfg.APIVersion))
+				return
+			}
+			if version.LessThan(v, e.cfg.MinAPIVersion) {
+				writeError(w, errdefs.Invalidf("client version %s is too old. Minimum supported API version is %s, please upgrade your client to a newer version", v, e.cfg.MinAPIVersion))
+				return
+			}
+
+			req = req.Clone(req.Context())
+			req.URL.Path = req.URL.Path[len(m[0])-len(m[2]):]
+			req.URL.RawPath = ""
+			if req.URL.Path == "" {
+				req.URL.Path = "/"
+			}
+		}
+		w.Header().Set("Api-Version", e.cfg.APIVersion)
+		w.Header().Set("Server", "Docker/fake ("+e.cfg.OSType+")")
+		h.ServeHTTP(w, req)
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	e.handler.ServeHTTP(w, req)
+}
+
+// Serve serves the API on the provided listener.
+// It blocks until the listener is closed or the engine is closed.
+func (e *Engine) Serve(l net.Listener) error {
+	srv := &http.Server{Handler: e}
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return net.ErrClosed
+	}
+	e.servers = append(e.servers, srv)
+	e.mu.Unlock()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Doer returns a transport.Doer which is connected to the engine in memory.
+// Each call returns a new Doer, all of which are connected to the same engine.
+func (e *Engine) Doer() transport.Doer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.listener == nil {
+		e.listener = newPipeListener()
+		e.srv = &http.Server{Handler: e}
+		go e.srv.Serve(e.listener)
+	}
+	return transport.FromDialer(e.listener.Dial)
+}
+
+// Close stops all running containers and shuts down any servers started by the engine.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	servers := e.servers
+	if e.srv != nil {
+		servers = append(servers, e.srv)
+	}
+	containers := make([]*container, 0, len(e.containers))
+	for _, c := range e.containers {
+		containers = append(containers, c)
+	}
+	e.mu.Unlock()
+
+	for _, c := range containers {
+		c.kill(sigKill)
+	}
+	e.events.close()
+
+	for _, srv := range servers {
+		srv.Close()
+	}
+	if e.listener != nil {
+		e.listener.Close()
+	}
+	return nil
+}
+
+func (e *Engine) handlePing(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("OSType", e.cfg.OSType)
+	w.Header().Set("Docker-Experimental", "false")
+	w.Header().Set("Builder-Version", "1")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if req.Method == http.MethodHead {
+		w.Header().Set("Content-Length", "0")
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+type versionResponse struct {
+	Version       string
+	APIVersion    string `json:"ApiVersion"`
+	MinAPIVersion string `json:"MinAPIVersion,omitempty"`
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+	KernelVersion string `json:",omitempty"`
+	Experimental  bool   `json:",omitempty"`
+	BuildTime     string `json:",omitempty"`
+}
+
+func (e *Engine) handleVersion(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, versionResponse{
+		Version:       "fake",
+		APIVersion:    e.cfg.APIVersion,
+		MinAPIVersion: e.cfg.MinAPIVersion,
+		GitCommit:     "fake",
+		GoVersion:     runtime.Version(),
+		Os:            e.cfg.OSType,
+		Arch:          runtime.GOARCH,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// writeError writes the error in the same format as the daemon, with the status code derived from the errdefs class.
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), errorResponse{Message: err.Error()})
+}
+
+func statusCode(err error) int {
+	switch {
+	case errdefs.IsNotFound(err):
+		return http.StatusNotFound
+	case errdefs.IsInvalid(err):
+		return http.StatusBadRequest
+	case errdefs.IsConflict(err):
+		return http.StatusConflict
+	case errdefs.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	case errdefs.IsForbidden(err):
+		return http.StatusForbidden
+	case errdefs.IsUnavailable(err):
+		return http.StatusServiceUnavailable
+	case errdefs.IsNotModified(err):
+		return http.StatusNotModified
+	case errdefs.IsNotImplemented(err):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func decodeBody(req *http.Request, v interface{}) error {
+	if req.Body == nil || req.ContentLength == 0 {
+		return nil
+	}
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return errdefs.Invalidf("error decoding request body: %v", err)
+	}
+	return nil
+}
+
+// newPid returns a fake process ID for a container or exec process.
+func (e *Engine) newPid() int {
+	return 1000 + int(e.pid.Add(1))
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
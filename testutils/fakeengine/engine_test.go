@@ -0,0 +1,880 @@
+package fakeengine
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	dockercontainer "github.com/cpuguy83/go-docker/container"
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+	dockerfilters "github.com/cpuguy83/go-docker/filters"
+	"github.com/cpuguy83/go-docker/image"
+	"github.com/cpuguy83/go-docker/system"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/cpuguy83/go-docker/version"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func newTestEngine(t *testing.T, opts ...Option) (*Engine, context.Context) {
+	t.Helper()
+
+	e := New(opts...)
+	t.Cleanup(func() { e.Close() })
+	e.AddImage(Image{RepoTags: []string{"busybox:latest"}, Config: ImageConfig{Cmd: []string{"sh"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return e, version.WithAPIVersion(ctx, DefaultAPIVersion)
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	_, err := s.Create(ctx, "notexist:latest", dockercontainer.WithCreateCmd("true"))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	c, err := s.Create(ctx, "busybox:latest",
+		dockercontainer.WithCreateName("test"),
+		dockercontainer.WithCreateCmd("/bin/sh", "-c", "echo hello; >&2 echo world; exit 3"),
+	)
+	assert.NilError(t, err)
+
+	_, err = s.Create(ctx, "busybox:latest", dockercontainer.WithCreateName("test"))
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	stdout, err := c.StdoutPipe(ctx)
+	assert.NilError(t, err)
+	defer stdout.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	data, err := io.ReadAll(stdout)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	es, err := c.Wait(ctx)
+	assert.NilError(t, err)
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 3))
+
+	inspect, err := s.Inspect(ctx, "test")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.ID, c.ID()))
+	assert.Check(t, cmp.Equal(inspect.Name, "/test"))
+	assert.Check(t, cmp.Equal(inspect.State.Status, "exited"))
+	assert.Check(t, cmp.Equal(inspect.State.ExitCode, 3))
+	assert.Check(t, cmp.Equal(inspect.Path, "/bin/sh"))
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	assert.NilError(t, logsTo(ctx, c, &stdoutBuf, &stderrBuf))
+	assert.Check(t, cmp.Equal(stdoutBuf.String(), "hello\n"))
+	assert.Check(t, cmp.Equal(stderrBuf.String(), "world\n"))
+
+	list, err := s.List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, list != nil)
+	assert.Check(t, cmp.Len(list, 0))
+
+	list, err = s.List(ctx, dockercontainer.WithListFilters(dockerfilters.NewArgs(dockerfilters.Reference("busybox"))))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	assert.Check(t, list == nil)
+
+	list, err = s.List(ctx, func(cfg *dockercontainer.ListConfig) { cfg.All = true })
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(list, 1))
+	assert.Check(t, cmp.Equal(list[0].ID, c.ID()))
+	assert.Check(t, cmp.Equal(list[0].State, "exited"))
+
+	assert.NilError(t, s.Remove(ctx, c.ID()))
+	_, err = c.Inspect(ctx)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
+
+// logsTo reads all the logs of a container.
+func logsTo(ctx context.Context, c *dockercontainer.Container, stdout, stderr io.Writer) error {
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+	if err := c.Logs(ctx, func(cfg *dockercontainer.LogReadConfig) {
+		cfg.Stdout = outW
+		cfg.Stderr = errW
+	}); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stderr, errR)
+		done <- err
+	}()
+	if _, err := io.Copy(stdout, outR); err != nil {
+		return err
+	}
+	return <-done
+}
+
+func TestContainerStopKill(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("top"))
+	assert.NilError(t, err)
+
+	assert.Check(t, errdefs.IsConflict(c.Kill(ctx)))
+
+	assert.NilError(t, c.Start(ctx))
+	assert.NilError(t, c.Stop(ctx))
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, !inspect.State.Running)
+	assert.Check(t, cmp.Equal(inspect.State.ExitCode, 143))
+
+	assert.NilError(t, c.Start(ctx))
+	es, err := c.Wait(ctx, dockercontainer.WithWaitCondition(dockercontainer.WaitConditionNextExit))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Kill(ctx, dockercontainer.WithKillSignal("SIGINT")))
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 130))
+
+	c, err = s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("/bin/sh", "-c", "trap 'exit 0' SIGTERM; while true; do sleep 0.1; done"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Start(ctx))
+
+	err = s.Remove(ctx, c.ID())
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Stop(ctx))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.State.ExitCode, 0))
+
+	assert.NilError(t, c.Start(ctx))
+	assert.NilError(t, s.Remove(ctx, c.ID(), dockercontainer.WithRemoveForce))
+}
+
+func TestContainerPauseRestartRename(t *testing.T) {
+	e, ctx := newTestEngine(t, WithAPIVersion(DefaultMinAPIVersion, "1.42"))
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateName("test"), dockercontainer.WithCreateCmd("top"))
+	assert.NilError(t, err)
+
+	err = c.Pause(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+	assert.NilError(t, s.Pause(ctx, "test"))
+	err = c.Pause(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.State.Status, "paused"))
+	assert.Check(t, inspect.State.Paused)
+
+	assert.NilError(t, c.Unpause(ctx))
+	err = s.Unpause(ctx, "test")
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	pid := inspect.State.Pid
+
+	assert.NilError(t, c.Restart(version.WithAPIVersion(ctx, "1.42"), dockercontainer.WithRestartSignal("SIGKILL"), dockercontainer.WithRestartTimeout(time.Second)))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.State.Status, "running"))
+	assert.Check(t, inspect.State.Pid != pid)
+
+	err = c.Restart(ctx, dockercontainer.WithRestartSignal("SIGKILL"))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+
+	_, err = s.Create(ctx, "busybox:latest", dockercontainer.WithCreateName("other"), dockercontainer.WithCreateCmd("true"))
+	assert.NilError(t, err)
+	err = c.Rename(ctx, "other")
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, s.Rename(ctx, "test", "renamed"))
+	inspect, err = s.Inspect(ctx, "renamed")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.ID, c.ID()))
+	_, err = s.Inspect(ctx, "test")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	assert.NilError(t, c.Stop(ctx))
+	assert.NilError(t, s.Restart(ctx, "renamed"))
+	inspect, err = c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.State.Running)
+}
+
+func TestContainerResizeUpdate(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateName("test"), dockercontainer.WithCreateCmd("top"), dockercontainer.WithCreateTTY)
+	assert.NilError(t, err)
+
+	err = c.Resize(ctx, dockercontainer.ResizeConfig{Width: 80, Height: 24})
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+	assert.NilError(t, c.Resize(ctx, dockercontainer.ResizeConfig{Width: 80, Height: 24}))
+	assert.NilError(t, s.Resize(ctx, "test", dockercontainer.ResizeConfig{Width: 120, Height: 40}))
+
+	warnings, err := c.Update(ctx,
+		dockercontainer.WithUpdateResources(containerapi.Resources{Memory: 64 << 20, NanoCPUs: 1e9}),
+		dockercontainer.WithUpdateRestartPolicy(containerapi.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}),
+	)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(warnings, 0))
+
+	// Zero values are left unchanged
+	warnings, err = s.Update(ctx, "test", func(cfg *dockercontainer.UpdateConfig) {
+		cfg.CPUShares = 512
+		cfg.KernelMemory = 32 << 20
+	})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(warnings, 1))
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(inspect.State.Status, "running"))
+	assert.Check(t, cmp.Equal(inspect.HostConfig.Memory, int64(64<<20)))
+	assert.Check(t, cmp.Equal(inspect.HostConfig.NanoCPUs, int64(1e9)))
+	assert.Check(t, cmp.Equal(inspect.HostConfig.CPUShares, int64(512)))
+	assert.Check(t, cmp.DeepEqual(inspect.HostConfig.RestartPolicy, containerapi.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}))
+
+	_, err = c.Update(ctx, dockercontainer.WithUpdateResources(containerapi.Resources{Memory: 1 << 20}))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	_, err = c.Update(ctx, dockercontainer.WithUpdateRestartPolicy(containerapi.RestartPolicy{Name: "sometimes"}))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+
+	_, err = s.Update(ctx, "notexist", dockercontainer.WithUpdateResources(containerapi.Resources{Memory: 64 << 20}))
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	_, err = c.Update(version.WithAPIVersion(ctx, "1.21"), dockercontainer.WithUpdateResources(containerapi.Resources{Memory: 64 << 20}))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+}
+
+func TestContainerTop(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateName("test"), dockercontainer.WithCreateCmd("top"))
+	assert.NilError(t, err)
+
+	_, err = c.Top(ctx)
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+	ep, err := c.Exec(ctx, dockercontainer.WithExecCmd("sleep", "60"))
+	assert.NilError(t, err)
+	assert.NilError(t, ep.Start(ctx))
+
+	inspect, err := c.Inspect(ctx)
+	assert.NilError(t, err)
+	execInspect, err := ep.Inspect(ctx)
+	assert.NilError(t, err)
+
+	top, err := c.Top(ctx)
+	assert.NilError(t, err)
+	pids, err := top.PIDs()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(pids, []int{inspect.State.Pid, execInspect.Pid}))
+	cmds, ok := top.Commands()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(cmds, []string{"top", "sleep 60"}))
+
+	top, err = s.Top(ctx, "test", "aux")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(top.Titles[0], "USER"))
+	users, ok := top.Users()
+	assert.Check(t, ok)
+	assert.Check(t, cmp.DeepEqual(users, []string{"root", "root"}))
+
+	_, err = c.Top(ctx, "-o", "pid")
+	assert.Check(t, errdefs.IsSystem(err), err)
+
+	// The process list is larger than the default response limit.
+	padding := strings.Repeat("x", 128)
+	for i := 0; i < 200; i++ {
+		ep, err := c.Exec(ctx, dockercontainer.WithExecCmd("sleep", "60", padding))
+		assert.NilError(t, err)
+		assert.NilError(t, ep.Start(ctx))
+	}
+	top, err = c.Top(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(top.Processes, 202))
+}
+
+func TestContainerChanges(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("sh", "-c", "mkdir /data /data/sub && touch /data/sub/a /tmp/b && rm /etc/passwd && rm -rf /tmp"))
+	assert.NilError(t, err)
+
+	changes, err := c.Changes(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(changes, 0))
+
+	assert.NilError(t, c.Start(ctx))
+	es, err := c.Wait(ctx)
+	assert.NilError(t, err)
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 0))
+
+	changes, err = s.Changes(ctx, c.ID())
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(changes, []containerapi.Change{
+		{Path: "/data", Kind: containerapi.ChangeAdd},
+		{Path: "/data/sub", Kind: containerapi.ChangeAdd},
+		{Path: "/data/sub/a", Kind: containerapi.ChangeAdd},
+		{Path: "/etc", Kind: containerapi.ChangeModify},
+		{Path: "/etc/passwd", Kind: containerapi.ChangeDelete},
+		{Path: "/tmp", Kind: containerapi.ChangeDelete},
+	}))
+
+	_, err = s.Changes(ctx, "notexist")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
+
+func TestContainerExport(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("sh", "-c", "mkdir /data && touch /data/a && rm /etc/hosts"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Start(ctx))
+	_, err = c.Wait(ctx)
+	assert.NilError(t, err)
+
+	rdr, err := c.Export(ctx)
+	assert.NilError(t, err)
+	defer rdr.Close()
+
+	var names []string
+	tr := tar.NewReader(rdr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Name == "etc/hostname" {
+			data, err := io.ReadAll(tr)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(string(data), c.ID()[:12]+"\n"))
+		}
+	}
+	assert.Check(t, cmp.DeepEqual(names, []string{"data/", "data/a", "etc/", "etc/hostname"}))
+
+	_, err = s.Export(ctx, "notexist")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
+
+func TestContainerPrune(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	create := func(cmd string, labels map[string]string) *dockercontainer.Container {
+		c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("sh", "-c", cmd), func(cfg *dockercontainer.CreateConfig) {
+			cfg.Spec.Labels = labels
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, c.Start(ctx))
+		return c
+	}
+	c1 := create("touch /a /b", map[string]string{"prune": "1"})
+	c2 := create("touch /a", map[string]string{"prune": "2"})
+	running := create("top", map[string]string{"prune": "1"})
+	for _, c := range []*dockercontainer.Container{c1, c2} {
+		_, err := c.Wait(ctx)
+		assert.NilError(t, err)
+	}
+
+	report, err := s.Prune(ctx, dockercontainer.WithPruneFilters(dockerfilters.NewArgs(dockerfilters.Until(time.Now().Add(-time.Hour)))))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(report.ContainersDeleted, 0))
+	assert.Check(t, cmp.Equal(report.SpaceReclaimed, int64(0)))
+
+	report, err = s.Prune(ctx, dockercontainer.WithPruneFilters(dockerfilters.NewArgs(dockerfilters.Label("prune=1"))))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(report.ContainersDeleted, []string{c1.ID()}))
+	assert.Check(t, cmp.Equal(report.SpaceReclaimed, int64(2*changeSize)))
+
+	_, err = c1.Inspect(ctx)
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	_, err = s.Prune(ctx, dockercontainer.WithPruneFilters(dockerfilters.NewArgs(dockerfilters.Status("exited"))))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	_, err = s.Prune(version.WithAPIVersion(ctx, "1.24"))
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+
+	assert.NilError(t, running.Stop(ctx))
+	report, err = s.Prune(ctx, dockercontainer.WithPruneFilters(dockerfilters.NewArgs(dockerfilters.NotLabel("prune=1"))))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(report.ContainersDeleted, []string{c2.ID()}))
+
+	report, err = s.Prune(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(report.ContainersDeleted, []string{running.ID()}))
+
+	list, err := s.List(ctx, func(cfg *dockercontainer.ListConfig) { cfg.All = true })
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list, 0))
+
+	// The list of removed containers is larger than the default response limit.
+	var ids []string
+	for i := 0; i < 300; i++ {
+		c, err := s.Create(ctx, "busybox:latest")
+		assert.NilError(t, err)
+		ids = append(ids, c.ID())
+	}
+	sort.Strings(ids)
+	report, err = s.Prune(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(report.ContainersDeleted, ids))
+}
+
+func TestContainerStats(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("top"))
+	assert.NilError(t, err)
+
+	stats, err := c.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stats.ID, c.ID()))
+	assert.Check(t, cmp.Equal(stats.PidsStats.Current, uint64(0)))
+
+	assert.NilError(t, c.Start(ctx))
+
+	stats, err = c.Stats(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(stats.CPUPercent(), 20.0))
+	assert.Check(t, cmp.Equal(stats.MemoryStats.UsageNoCache(), uint64(48<<20)))
+
+	stats, err = s.Stats(ctx, c.ID(), dockercontainer.WithStatsOneShot)
+	assert.NilError(t, err)
+	assert.Check(t, stats.PreRead.IsZero())
+	assert.Check(t, cmp.Equal(stats.PreCPUStats.SystemUsage, uint64(0)))
+
+	var samples []containerapi.Stats
+	for stats, err := range c.StatsStream(ctx) {
+		assert.NilError(t, err)
+		samples = append(samples, stats)
+		if len(samples) == 3 {
+			break
+		}
+	}
+	assert.Assert(t, cmp.Len(samples, 3))
+	rates := samples[2].IORatesSince(samples[1])
+	assert.Check(t, rates.NetworkRx > 0 && rates.NetworkTx > 0 && rates.BlockRead > 0 && rates.BlockWrite > 0, "%+v", rates)
+
+	for _, err := range c.StatsStream(ctx, dockercontainer.WithStatsOneShot) {
+		assert.Check(t, errdefs.IsInvalid(err), err)
+	}
+
+	// The stream ends when the container stops.
+	samples = nil
+	for stats, err := range c.StatsStream(ctx) {
+		assert.NilError(t, err)
+		samples = append(samples, stats)
+		if len(samples) == 1 {
+			assert.NilError(t, c.Stop(ctx))
+		}
+	}
+	assert.Check(t, cmp.Equal(samples[len(samples)-1].PidsStats.Current, uint64(0)))
+}
+
+func TestExec(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("top"))
+	assert.NilError(t, err)
+
+	_, err = c.Exec(ctx, dockercontainer.WithExecCmd("true"))
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, c.Start(ctx))
+
+	r, w := io.Pipe()
+	defer r.Close()
+	ep, err := c.Exec(ctx, dockercontainer.WithExecCmd("cat"), func(cfg *dockercontainer.ExecConfig) {
+		cfg.Stdin = io.NopCloser(strings.NewReader("hello\n"))
+		cfg.Stdout = w
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ep.Start(ctx))
+
+	data, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	inspect, err := ep.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, !inspect.Running)
+	assert.Assert(t, inspect.ExitCode != nil)
+	assert.Check(t, cmp.Equal(*inspect.ExitCode, 0))
+
+	// Detached exec processes are killed with the container
+	ep, err = c.Exec(ctx, dockercontainer.WithExecCmd("sleep", "60"))
+	assert.NilError(t, err)
+	assert.NilError(t, ep.Start(ctx))
+
+	inspect, err = ep.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, inspect.Running)
+
+	assert.NilError(t, c.Stop(ctx))
+	inspect, err = ep.Inspect(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, !inspect.Running)
+	assert.Assert(t, inspect.ExitCode != nil)
+	assert.Check(t, cmp.Equal(*inspect.ExitCode, 137))
+}
+
+func TestAttachStdin(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest",
+		dockercontainer.WithCreateCmd("cat"),
+		dockercontainer.WithCreateAttachStdin,
+		dockercontainer.WithCreateStdinOnce,
+		dockercontainer.WithCreateTTY,
+	)
+	assert.NilError(t, err)
+
+	stdin, err := c.StdinPipe(ctx)
+	assert.NilError(t, err)
+	stdout, err := c.StdoutPipe(ctx)
+	assert.NilError(t, err)
+	defer stdout.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	_, err = stdin.Write([]byte("hello\n"))
+	assert.NilError(t, err)
+	assert.NilError(t, stdin.(interface{ CloseWrite() error }).CloseWrite())
+
+	data, err := io.ReadAll(stdout)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	es, err := c.Wait(ctx)
+	assert.NilError(t, err)
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 0))
+}
+
+func TestAttachWebSocket(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest",
+		dockercontainer.WithCreateCmd("/bin/sh", "-c", "cat; >&2 echo done"),
+		dockercontainer.WithCreateAttachStdin,
+		dockercontainer.WithCreateStdinOnce,
+	)
+	assert.NilError(t, err)
+
+	_, err = s.Attach(ctx, c.ID(), dockercontainer.WithAttachWebSocket, dockercontainer.WithAttachStdout, dockercontainer.WithAttachStderr)
+	assert.Check(t, errdefs.IsInvalid(err), err)
+
+	stdin, err := c.StdinPipe(ctx, dockercontainer.WithAttachWebSocket)
+	assert.NilError(t, err)
+	stdout, err := c.StdoutPipe(ctx, dockercontainer.WithAttachWebSocket)
+	assert.NilError(t, err)
+	defer stdout.Close()
+	stderr, err := c.StderrPipe(ctx, dockercontainer.WithAttachWebSocket)
+	assert.NilError(t, err)
+	defer stderr.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	// Output is not multiplexed over a WebSocket even without a TTY.
+	_, err = stdin.Write([]byte("hello\n"))
+	assert.NilError(t, err)
+	assert.NilError(t, stdin.Close())
+
+	data, err := io.ReadAll(stdout)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	data, err = io.ReadAll(stderr)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "done\n"))
+
+	es, err := c.Wait(ctx)
+	assert.NilError(t, err)
+	code, err := es.ExitCode()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(code, 0))
+}
+
+func TestAttachWebSocketCloseStdin(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := dockercontainer.NewService(e.Doer())
+
+	c, err := s.Create(ctx, "busybox:latest",
+		dockercontainer.WithCreateCmd("cat"),
+		dockercontainer.WithCreateAttachStdin,
+		dockercontainer.WithCreateTTY,
+	)
+	assert.NilError(t, err)
+
+	attach, err := s.Attach(ctx, c.ID(), dockercontainer.WithAttachWebSocket, dockercontainer.WithAttachStdin, dockercontainer.WithAttachStdout)
+	assert.NilError(t, err)
+	defer attach.Close()
+
+	assert.NilError(t, c.Start(ctx))
+
+	_, err = attach.Stdin().Write([]byte("hello\n"))
+	assert.NilError(t, err)
+	data := make([]byte, len("hello\n"))
+	_, err = io.ReadFull(attach.Stdout(), data)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello\n"))
+
+	// Closing stdin ends the WebSocket, but the output still ends cleanly.
+	assert.NilError(t, attach.Stdin().Close())
+	_, err = io.ReadAll(attach.Stdout())
+	assert.NilError(t, err)
+}
+
+func TestAttachDetachKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []dockercontainer.AttachOption
+	}{
+		{name: "hijack"},
+		{name: "websocket", opts: []dockercontainer.AttachOption{dockercontainer.WithAttachWebSocket}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e, ctx := newTestEngine(t)
+			s := dockercontainer.NewService(e.Doer())
+
+			c, err := s.Create(ctx, "busybox:latest",
+				dockercontainer.WithCreateCmd("cat"),
+				dockercontainer.WithCreateAttachStdin,
+				dockercontainer.WithCreateTTY,
+			)
+			assert.NilError(t, err)
+
+			opts := append([]dockercontainer.AttachOption{dockercontainer.WithAttachDetachKeys("ctrl-foo")}, tc.opts...)
+			_, err = s.Attach(ctx, c.ID(), append(opts, dockercontainer.WithAttachStdin)...)
+			assert.Check(t, cmp.ErrorContains(err, "400"))
+
+			opts = append([]dockercontainer.AttachOption{
+				dockercontainer.WithAttachStdin,
+				dockercontainer.WithAttachStdout,
+				dockercontainer.WithAttachDetachKeys("ctrl-x,x"),
+			}, tc.opts...)
+			attach, err := s.Attach(ctx, c.ID(), opts...)
+			assert.NilError(t, err)
+			defer attach.Close()
+
+			assert.NilError(t, c.Start(ctx))
+
+			// A partial sequence is passed through.
+			const input = "hello \x18y\n"
+			_, err = attach.Stdin().Write([]byte(input))
+			assert.NilError(t, err)
+			data := make([]byte, len(input))
+			_, err = io.ReadFull(attach.Stdout(), data)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(string(data), input))
+
+			_, err = attach.Stdin().Write([]byte("\x18x"))
+			assert.NilError(t, err)
+			data, err = io.ReadAll(attach.Stdout())
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Len(data, 0))
+
+			inspect, err := c.Inspect(ctx)
+			assert.NilError(t, err)
+			assert.Check(t, inspect.State.Running)
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	e, ctx := newTestEngine(t, WithPull(func(ref string) (Image, error) {
+		if strings.HasPrefix(ref, "notexist") {
+			return Image{}, errdefs.NotFound("repository does not exist")
+		}
+		return defaultPull(ref)
+	}))
+	s := image.NewService(e.Doer())
+
+	var messages []image.PullProgressMessage
+	err := s.Pull(ctx, image.Remote{Host: "docker.io", Locator: "library/alpine", Tag: "3"}, image.WithPullProgressMessage(func(_ context.Context, msg image.PullProgressMessage) error {
+		messages = append(messages, msg)
+		return nil
+	}))
+	assert.NilError(t, err)
+	assert.Assert(t, len(messages) > 0)
+	assert.Check(t, cmp.Equal(messages[len(messages)-1].Status, "Status: Downloaded newer image for alpine:3"))
+
+	err = s.Pull(ctx, image.Remote{Locator: "notexist"})
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	list, err := s.List(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list, 2))
+
+	list, err = s.List(ctx, image.WithListFilters(dockerfilters.NewArgs(dockerfilters.Reference("alpine"))))
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Len(list, 1))
+	assert.Check(t, cmp.DeepEqual(list[0].RepoTags, []string{"alpine:3"}))
+
+	list, err = s.List(ctx, func(cfg *image.ListConfig) {
+		cfg.Filter.Reference = []string{"alpine"}
+	})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list, 1))
+
+	empty, err := s.List(ctx, image.WithListFilters(dockerfilters.NewArgs(dockerfilters.Reference("notexist"))))
+	assert.NilError(t, err)
+	assert.Check(t, empty != nil)
+	assert.Check(t, cmp.Len(empty, 0))
+
+	empty, err = s.List(ctx, image.WithListFilters(dockerfilters.NewArgs(dockerfilters.Status("running"))))
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	assert.Check(t, empty == nil)
+
+	e.AddImage(Image{ID: list[0].ID, RepoTags: []string{"alpine:latest"}})
+	rm, err := s.Remove(ctx, "alpine:latest")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(rm.Untagged, []string{"alpine:latest"}))
+
+	cs := dockercontainer.NewService(e.Doer())
+	_, err = cs.Create(ctx, "alpine:3")
+	assert.NilError(t, err)
+
+	_, err = s.Remove(ctx, "alpine:3")
+	assert.Check(t, errdefs.IsConflict(err), err)
+
+	rm, err = s.Remove(ctx, "alpine:3", image.WithRemoveForce)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(rm.Deleted, list[0].ID))
+
+	_, err = s.Remove(ctx, "alpine:3")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+}
+
+func TestEvents(t *testing.T) {
+	e, ctx := newTestEngine(t)
+	s := system.NewService(e.Doer())
+	cs := dockercontainer.NewService(e.Doer())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	next, err := s.Events(ctx, system.WithAddEventFilter("type", "container"), system.WithAddEventFilter("event", "start"), system.WithAddEventFilter("event", "die"))
+	assert.NilError(t, err)
+
+	c, err := cs.Create(ctx, "busybox:latest", dockercontainer.WithCreateCmd("false"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Start(ctx))
+
+	ev, err := next()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(ev.Action, "start"))
+	assert.Check(t, cmp.Equal(ev.Actor.ID, c.ID()))
+
+	ev, err = next()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(ev.Action, "die"))
+	assert.Check(t, cmp.Equal(ev.Actor.Attributes["exitCode"], "1"))
+}
+
+func TestServeUnix(t *testing.T) {
+	e, ctx := newTestEngine(t, WithAPIVersion("1.24", "1.40"))
+
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	go e.Serve(l)
+
+	tr, err := transport.UnixSocketTransport(sock)
+	assert.NilError(t, err)
+	s := system.NewService(tr)
+
+	p, err := s.Ping(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(p.APIVersion, "1.40"))
+	assert.Check(t, cmp.Equal(p.OSType, "linux"))
+
+	// The test context requests a newer version than the engine supports
+	_, err = dockercontainer.NewService(tr).List(ctx)
+	assert.Check(t, errdefs.IsInvalid(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "is too new"))
+
+	list, err := dockercontainer.NewService(tr).List(version.WithAPIVersion(ctx, "1.40"))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(list, 0))
+}
+
+func TestShell(t *testing.T) {
+	type testCase struct {
+		script string
+		signal int
+		stdout string
+		stderr string
+		code   int
+	}
+
+	cases := []testCase{
+		{script: "echo 'hello there'", stdout: "hello there\n"},
+		{script: ">&2 echo 'bad things'", stderr: "bad things\n"},
+		{script: "echo hello; >&2 echo world; exit 2", stdout: "hello\n", stderr: "world\n", code: 2},
+		{script: "false && echo no", code: 1},
+		{script: "true && echo \"yes\"", stdout: "yes\n"},
+		{script: "trap 'exit 0' SIGTERM; while true; do sleep 0.1; done", signal: sigTerm, code: 0},
+		{script: "trap 'exit 1' EXIT; while true; do sleep 0.1; done", signal: sigTerm, code: 1},
+		{script: "while true; do usleep 100000; done", signal: sigTerm, code: 143},
+	}
+
+	e := New()
+	defer e.Close()
+
+	for _, tc := range cases {
+		t.Run(tc.script, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if tc.signal != 0 {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					cancel(signalError{tc.signal})
+				}()
+			}
+
+			var stdout, stderr bytes.Buffer
+			code := e.shell(ctx, []string{"sh", "-c", tc.script}, Stdio{Stdout: &stdout, Stderr: &stderr})
+			assert.Check(t, cmp.Equal(code, tc.code))
+			assert.Check(t, cmp.Equal(stdout.String(), tc.stdout))
+			assert.Check(t, cmp.Equal(stderr.String(), tc.stderr))
+		})
+	}
+}
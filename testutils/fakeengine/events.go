@@ -0,0 +1,197 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// Event is an event in the format sent by the daemon.
+type Event struct {
+	Status string `json:"status,omitempty"`
+	ID     string `json:"id,omitempty"`
+	From   string `json:"from,omitempty"`
+
+	Type   string
+	Action string
+	Actor  EventActor
+	Scope  string `json:"scope"`
+
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// EventActor describes the object an event is about.
+type EventActor struct {
+	ID         string
+	Attributes map[string]string
+}
+
+// eventLog stores all the events emitted by the engine.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+	notify chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{notify: make(chan struct{})}
+}
+
+func (l *eventLog) add(typ, action, id string, attrs map[string]string) {
+	t := now()
+	ev := Event{
+		Type:     typ,
+		Action:   action,
+		Actor:    EventActor{ID: id, Attributes: attrs},
+		Scope:    "local",
+		Time:     t.Unix(),
+		TimeNano: t.UnixNano(),
+	}
+	if typ == "container" || typ == "image" {
+		// Deprecated fields which are still sent by the daemon
+		ev.Status = action
+		ev.ID = id
+		ev.From = attrs["image"]
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.events = append(l.events, ev)
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+func (l *eventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.notify)
+	}
+}
+
+// Events returns a copy of all events emitted by the engine so far.
+func (e *Engine) Events() []Event {
+	e.events.mu.Lock()
+	defer e.events.mu.Unlock()
+	return append([]Event(nil), e.events.events...)
+}
+
+// parseTimestamp parses timestamps in the format used by the API, which is seconds since the epoch with optional fractional nanoseconds.
+// RFC3339 timestamps are also accepted.
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, errdefs.Invalidf("invalid timestamp %q", s)
+	}
+	secs, frac := math.Modf(f)
+	return time.Unix(int64(secs), int64(frac*1e9)), nil
+}
+
+func (e *Engine) handleEvents(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	var since, until time.Time
+	for k, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := q.Get(k); v != "" {
+			var err error
+			*t, err = parseTimestamp(v)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	}
+
+	f, err := parseFilters(q.Get("filters"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := f.validate("container", "event", "image", "label", "type"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	match := func(ev *Event) bool {
+		t := time.Unix(0, ev.TimeNano)
+		if !since.IsZero() && t.Before(since) {
+			return false
+		}
+		return f.match("type", func(v string) bool { return v == ev.Type }) &&
+			f.match("event", func(v string) bool { return v == ev.Action }) &&
+			f.match("container", func(v string) bool {
+				return ev.Type == "container" && (v == ev.Actor.ID || v == ev.Actor.Attributes["name"])
+			}) &&
+			f.match("image", func(v string) bool { return v == ev.Actor.Attributes["image"] }) &&
+			f.matchLabels(ev.Actor.Attributes)
+	}
+
+	// Without a start time, only new events are sent.
+	var idx int
+	if since.IsZero() {
+		e.events.mu.Lock()
+		idx = len(e.events.events)
+		e.events.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	enc := json.NewEncoder(&flushWriter{w})
+
+	var timer <-chan time.Time
+	if !until.IsZero() {
+		t := time.NewTimer(time.Until(until))
+		defer t.Stop()
+		timer = t.C
+	}
+
+	for {
+		e.events.mu.Lock()
+		events := e.events.events[idx:]
+		idx = len(e.events.events)
+		closed := e.events.closed
+		ch := e.events.notify
+		e.events.mu.Unlock()
+
+		for i := range events {
+			ev := &events[i]
+			if !until.IsZero() && time.Unix(0, ev.TimeNano).After(until) {
+				return
+			}
+			if !match(ev) {
+				continue
+			}
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		}
+
+		if closed {
+			return
+		}
+
+		select {
+		case <-ch:
+		case <-timer:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
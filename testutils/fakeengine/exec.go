@@ -0,0 +1,267 @@
+package fakeengine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// execConfig is the body of an exec create request.
+type execConfig struct {
+	User         string
+	Privileged   bool
+	Tty          bool
+	AttachStdin  bool
+	AttachStderr bool
+	AttachStdout bool
+	Detach       bool
+	DetachKeys   string
+	Env          []string
+	WorkingDir   string
+	Cmd          []string
+}
+
+// exec is an emulated process started in a running container.
+type exec struct {
+	id     string
+	c      *container
+	config execConfig
+
+	mu       sync.Mutex
+	started  bool
+	running  bool
+	pid      int
+	exitCode *int
+}
+
+type execCreateResponse struct {
+	ID string `json:"Id"`
+}
+
+func (e *Engine) handleExecCreate(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var cfg execConfig
+	if err := decodeBody(req, &cfg); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(cfg.Cmd) == 0 {
+		writeError(w, errdefs.Invalid("No exec command specified"))
+		return
+	}
+
+	c.mu.Lock()
+	if c.current == nil {
+		c.mu.Unlock()
+		writeError(w, errdefs.Conflictf("Container %s is not running", c.id))
+		return
+	}
+	ex := &exec{id: newID(), c: c, config: cfg}
+	c.execs = append(c.execs, ex.id)
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	e.execs[ex.id] = ex
+	e.mu.Unlock()
+
+	attrs := c.attributes()
+	attrs["execID"] = ex.id
+	e.events.add("container", "exec_create: "+strings.Join(cfg.Cmd, " "), c.id, attrs)
+
+	writeJSON(w, http.StatusCreated, execCreateResponse{ID: ex.id})
+}
+
+func (e *Engine) getExec(id string) (*exec, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ex, ok := e.execs[id]
+	if !ok {
+		return nil, errdefs.NotFound("No such exec instance: " + id)
+	}
+	return ex, nil
+}
+
+type execStartConfig struct {
+	Detach bool
+	Tty    bool
+}
+
+func (e *Engine) handleExecStart(w http.ResponseWriter, req *http.Request) {
+	ex, err := e.getExec(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var cfg execStartConfig
+	if err := decodeBody(req, &cfg); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ex.mu.Lock()
+	if ex.started {
+		ex.mu.Unlock()
+		writeError(w, errdefs.Conflictf("Error: Exec command %s has already run", ex.id))
+		return
+	}
+	ex.started = true
+	ex.mu.Unlock()
+
+	c := ex.c
+	c.mu.Lock()
+	r := c.current
+	if r != nil {
+		r.execs.Add(1)
+	}
+	c.mu.Unlock()
+	if r == nil {
+		writeError(w, errdefs.Conflictf("Container %s is not running", c.id))
+		return
+	}
+
+	ex.mu.Lock()
+	ex.running = true
+	ex.pid = e.newPid()
+	ex.mu.Unlock()
+
+	args := ex.config.Cmd
+	cmd := e.lookupCommand(args)
+
+	attrs := c.attributes()
+	attrs["execID"] = ex.id
+	e.events.add("container", "exec_start: "+strings.Join(args, " "), c.id, attrs)
+
+	if cfg.Detach {
+		go ex.run(r, cmd, Stdio{Stdout: io.Discard, Stderr: io.Discard})
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ct := mediaTypeMultiplexed
+	if ex.config.Tty {
+		ct = mediaTypeRawStream
+	}
+	conn, buf, err := hijack(w, req, ct)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	stdout, stderr := newStdioWriters(conn, ex.config.Tty)
+	stdio := Stdio{Stdout: io.Discard, Stderr: io.Discard}
+	if ex.config.AttachStdout {
+		stdio.Stdout = stdout
+	}
+	if ex.config.AttachStderr {
+		stdio.Stderr = stderr
+	}
+	if ex.config.AttachStdin {
+		stdio.Stdin = buf
+	}
+
+	ex.run(r, cmd, stdio)
+}
+
+// run runs the exec process and records its exit code.
+// Like the daemon, exec processes are killed when the container process exits.
+func (ex *exec) run(r *run, cmd Command, stdio Stdio) {
+	defer r.execs.Done()
+
+	ctx, cancel := context.WithCancelCause(withContainer(context.Background(), ex.c))
+	defer cancel(nil)
+	go func() {
+		select {
+		case <-r.done:
+			cancel(signalError{sigKill})
+		case <-ctx.Done():
+		}
+	}()
+
+	code := cmd(ctx, ex.config.Cmd, stdio)
+	if Signal(ctx) == sigKill {
+		code = 128 + sigKill
+	}
+
+	ex.mu.Lock()
+	ex.running = false
+	ex.pid = 0
+	ex.exitCode = &code
+	ex.mu.Unlock()
+
+	attrs := ex.c.attributes()
+	attrs["execID"] = ex.id
+	attrs["exitCode"] = strconv.Itoa(code)
+	ex.c.e.events.add("container", "exec_die", ex.c.id, attrs)
+}
+
+func (e *Engine) handleExecResize(w http.ResponseWriter, req *http.Request) {
+	if _, err := e.getExec(req.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type execProcessConfig struct {
+	Tty        bool     `json:"tty"`
+	Entrypoint string   `json:"entrypoint"`
+	Cmd        []string `json:"arguments"`
+	Privileged *bool    `json:"privileged,omitempty"`
+	User       string   `json:"user,omitempty"`
+}
+
+type execInspect struct {
+	ID            string
+	Running       bool
+	ExitCode      *int
+	ProcessConfig execProcessConfig
+	OpenStdin     bool
+	OpenStderr    bool
+	OpenStdout    bool
+	CanRemove     bool
+	ContainerID   string
+	DetachKeys    []byte
+	Pid           int
+}
+
+func (e *Engine) handleExecInspect(w http.ResponseWriter, req *http.Request) {
+	ex, err := e.getExec(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	privileged := ex.config.Privileged
+	writeJSON(w, http.StatusOK, execInspect{
+		ID:       ex.id,
+		Running:  ex.running,
+		ExitCode: ex.exitCode,
+		ProcessConfig: execProcessConfig{
+			Tty:        ex.config.Tty,
+			Entrypoint: ex.config.Cmd[0],
+			Cmd:        ex.config.Cmd[1:],
+			Privileged: &privileged,
+			User:       ex.config.User,
+		},
+		OpenStdin:   ex.config.AttachStdin,
+		OpenStderr:  ex.config.AttachStderr,
+		OpenStdout:  ex.config.AttachStdout,
+		ContainerID: ex.c.id,
+		Pid:         ex.pid,
+	})
+}
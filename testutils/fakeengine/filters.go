@@ -0,0 +1,104 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// filters are the parsed "filters" query parameter of list endpoints.
+type filters map[string][]string
+
+// parseFilters parses filters in either the map form (`{"key":{"value":true}}`) or the legacy array form (`{"key":["value"]}`).
+func parseFilters(s string) (filters, error) {
+	if s == "" {
+		return filters{}, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, errdefs.Invalidf("invalid filter: %v", err)
+	}
+
+	f := filters{}
+	for k, v := range raw {
+		var values []string
+		if err := json.Unmarshal(v, &values); err != nil {
+			var m map[string]bool
+			if err := json.Unmarshal(v, &m); err != nil {
+				return nil, errdefs.Invalidf("invalid filter value for %q: %v", k, err)
+			}
+			for value, ok := range m {
+				if ok {
+					values = append(values, value)
+				}
+			}
+			sort.Strings(values)
+		}
+		if len(values) > 0 {
+			f[k] = values
+		}
+	}
+	return f, nil
+}
+
+// validate returns an error if any of the filters is not one of the accepted keys.
+func (f filters) validate(accepted ...string) error {
+	for k := range f {
+		var ok bool
+		for _, a := range accepted {
+			if k == a {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return errdefs.Invalidf("invalid filter '%s'", k)
+		}
+	}
+	return nil
+}
+
+func (f filters) has(key string) bool {
+	return len(f[key]) > 0
+}
+
+// match returns true if there are no values for the key or any of the values match.
+func (f filters) match(key string, fn func(string) bool) bool {
+	values := f[key]
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels returns true if all the label filters match the labels.
+// Label filters are either a key, which must be set, or "key=value".
+func (f filters) matchLabels(labels map[string]string) bool {
+	for _, l := range f["label"] {
+		k, v, hasValue := strings.Cut(l, "=")
+		actual, ok := labels[k]
+		if !ok || (hasValue && actual != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchNotLabels returns true if none of the "label!" filters match the labels.
+func (f filters) matchNotLabels(labels map[string]string) bool {
+	for _, l := range f["label!"] {
+		k, v, hasValue := strings.Cut(l, "=")
+		if actual, ok := labels[k]; ok && (!hasValue || actual == v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,507 @@
+package fakeengine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/image/imageapi"
+)
+
+// Image is an image stored in the engine.
+type Image struct {
+	// ID is the image ID, e.g. "sha256:<hex>".
+	// If empty, an ID is generated when the image is added.
+	ID string
+	// RepoTags are the references of the image, e.g. "busybox:latest".
+	RepoTags    []string
+	RepoDigests []string
+	Created     time.Time
+	Size        int64
+	Labels      map[string]string
+	Config      ImageConfig
+}
+
+// ImageConfig holds the parts of an image config which are used as defaults for containers.
+type ImageConfig struct {
+	Cmd        []string
+	Entrypoint []string
+	Env        []string
+	WorkingDir string
+}
+
+func digestOf(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// normalizeRef converts a reference to the short form used in RepoTags, e.g. "docker.io/library/busybox" to "busybox:latest".
+func normalizeRef(ref string) string {
+	for _, prefix := range []string{"docker.io/", "index.docker.io/"} {
+		ref = strings.TrimPrefix(ref, prefix)
+	}
+	ref = strings.TrimPrefix(ref, "library/")
+
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if strings.LastIndex(ref, ":") <= strings.LastIndex(ref, "/") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+// repoName returns the repository part of a normalized reference.
+func repoName(ref string) string {
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		return ref[:idx]
+	}
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		return ref[:idx]
+	}
+	return ref
+}
+
+func defaultPull(ref string) (Image, error) {
+	img := Image{
+		ID:          digestOf("image:" + ref),
+		RepoDigests: []string{repoName(ref) + "@" + digestOf("manifest:"+ref)},
+		Created:     now(),
+		Size:        1024 * 1024,
+		Config:      ImageConfig{Cmd: []string{"sh"}},
+	}
+	if !strings.Contains(ref, "@") {
+		img.RepoTags = []string{ref}
+	}
+	return img, nil
+}
+
+// AddImage adds an image to the engine as if it was pulled or built.
+// References of the image are removed from any other image that has them.
+// If an image with the same ID already exists, only the references are added to it.
+// It returns the ID of the image.
+func (e *Engine) AddImage(img Image) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.addImage(img)
+}
+
+// addImage adds the image. e.mu must be held.
+func (e *Engine) addImage(img Image) string {
+	if img.ID == "" {
+		img.ID = digestOf(newID())
+	}
+	if img.Created.IsZero() {
+		img.Created = now()
+	}
+	tags := make([]string, 0, len(img.RepoTags))
+	for _, ref := range img.RepoTags {
+		tags = append(tags, normalizeRef(ref))
+	}
+	img.RepoTags = tags
+
+	for _, other := range e.images {
+		if other.ID == img.ID {
+			continue
+		}
+		other.RepoTags = removeAll(other.RepoTags, img.RepoTags...)
+	}
+
+	if existing, ok := e.images[img.ID]; ok {
+		existing.RepoTags = appendUnique(existing.RepoTags, img.RepoTags...)
+		existing.RepoDigests = appendUnique(existing.RepoDigests, img.RepoDigests...)
+		return img.ID
+	}
+	e.images[img.ID] = &img
+	return img.ID
+}
+
+func appendUnique(s []string, values ...string) []string {
+	out := append([]string(nil), s...)
+	for _, v := range values {
+		var found bool
+		for _, existing := range out {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func removeAll(s []string, values ...string) []string {
+	var out []string
+	for _, existing := range s {
+		var found bool
+		for _, v := range values {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// lookupImage finds an image by ID, ID prefix, reference, or digest reference.
+// The returned bool is true if the image was found by reference rather than by ID.
+// e.mu must be held.
+func (e *Engine) lookupImage(ref string) (*Image, bool, error) {
+	if img, ok := e.images[ref]; ok {
+		return img, false, nil
+	}
+	if img, ok := e.images["sha256:"+ref]; ok {
+		return img, false, nil
+	}
+
+	normalized := normalizeRef(ref)
+	for _, img := range e.images {
+		for _, tag := range img.RepoTags {
+			if tag == normalized {
+				return img, true, nil
+			}
+		}
+		for _, d := range img.RepoDigests {
+			if d == normalized {
+				return img, true, nil
+			}
+		}
+	}
+
+	if len(ref) >= 4 {
+		prefix := strings.TrimPrefix(ref, "sha256:")
+		for id, img := range e.images {
+			if strings.HasPrefix(strings.TrimPrefix(id, "sha256:"), prefix) {
+				return img, false, nil
+			}
+		}
+	}
+	return nil, false, errdefs.NotFound("No such image: " + ref)
+}
+
+// getImage returns a copy of the image for the provided reference.
+func (e *Engine) getImage(ref string) (Image, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	img, _, err := e.lookupImage(ref)
+	if err != nil {
+		return Image{}, err
+	}
+	return *img, nil
+}
+
+// imageMatches returns true if the image with the provided ID is referenced by ref.
+func (e *Engine) imageMatches(id, ref string) bool {
+	img, err := e.getImage(ref)
+	return err == nil && img.ID == id
+}
+
+func (e *Engine) handleImageList(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	f, err := parseFilters(q.Get("filters"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := f.validate("before", "dangling", "label", "reference", "since"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var before, since *Image
+	for k, p := range map[string]**Image{"before": &before, "since": &since} {
+		if v := f[k]; len(v) > 0 {
+			img, err := e.getImage(v[0])
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			*p = &img
+		}
+	}
+
+	e.mu.Lock()
+	images := make([]Image, 0, len(e.images))
+	for _, img := range e.images {
+		images = append(images, *img)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Created.After(images[j].Created)
+	})
+
+	list := []imageapi.Image{}
+	for _, img := range images {
+		if before != nil && !img.Created.Before(before.Created) {
+			continue
+		}
+		if since != nil && !img.Created.After(since.Created) {
+			continue
+		}
+		if !f.match("dangling", func(v string) bool {
+			dangling, _ := strconv.ParseBool(v)
+			return dangling == (len(img.RepoTags) == 0)
+		}) || !f.matchLabels(img.Labels) {
+			continue
+		}
+
+		tags := img.RepoTags
+		if f.has("reference") {
+			tags = nil
+			for _, tag := range img.RepoTags {
+				if f.match("reference", func(v string) bool {
+					ok, _ := path.Match(v, tag)
+					if !ok {
+						ok, _ = path.Match(v, repoName(tag))
+					}
+					return ok
+				}) {
+					tags = append(tags, tag)
+				}
+			}
+			if len(tags) == 0 {
+				continue
+			}
+		}
+
+		list = append(list, imageapi.Image{
+			ID:          img.ID,
+			RepoTags:    append([]string{}, tags...),
+			RepoDigests: append([]string{}, img.RepoDigests...),
+			Created:     img.Created.Unix(),
+			Size:        img.Size,
+			SharedSize:  -1,
+			VirtualSize: img.Size,
+			Labels:      img.Labels,
+			Containers:  -1,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+type imageInspect struct {
+	ID            string `json:"Id"`
+	RepoTags      []string
+	RepoDigests   []string
+	Created       string
+	Size          int64
+	VirtualSize   int64
+	Os            string
+	Architecture  string
+	Config        ImageConfig
+	DockerVersion string
+}
+
+func (e *Engine) handleImageInspect(w http.ResponseWriter, req *http.Request) {
+	name, ok := strings.CutSuffix(req.PathValue("name"), "/json")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	img, err := e.getImage(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, imageInspect{
+		ID:           img.ID,
+		RepoTags:     append([]string{}, img.RepoTags...),
+		RepoDigests:  append([]string{}, img.RepoDigests...),
+		Created:      img.Created.Format(time.RFC3339Nano),
+		Size:         img.Size,
+		VirtualSize:  img.Size,
+		Os:           e.cfg.OSType,
+		Architecture: runtime.GOARCH,
+		Config:       img.Config,
+	})
+}
+
+type pullMessage struct {
+	Status         string          `json:"status,omitempty"`
+	Progress       string          `json:"progress,omitempty"`
+	ProgressDetail *progressDetail `json:"progressDetail,omitempty"`
+	ID             string          `json:"id,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ErrorDetail    *errorResponse  `json:"errorDetail,omitempty"`
+}
+
+type progressDetail struct {
+	Current int64 `json:"current"`
+	Total   int64 `json:"total"`
+}
+
+func (e *Engine) handleImagePull(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	ref := q.Get("fromImage")
+	if ref == "" {
+		writeError(w, errdefs.Invalid("fromImage is required"))
+		return
+	}
+	if tag := q.Get("tag"); tag != "" {
+		if strings.Contains(tag, ":") {
+			ref += "@" + tag
+		} else {
+			ref += ":" + tag
+		}
+	}
+	ref = normalizeRef(ref)
+
+	_, err := e.getImage(ref)
+	upToDate := err == nil
+
+	var img Image
+	if !upToDate {
+		img, err = e.cfg.Pull(ref)
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				writeError(w, errdefs.NotFoundf("pull access denied for %s, repository does not exist or may require 'docker login': %v", repoName(ref), err))
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(&flushWriter{w})
+
+	tag := strings.TrimPrefix(strings.TrimPrefix(ref, repoName(ref)), ":")
+	enc.Encode(pullMessage{Status: "Pulling from " + repoName(ref), ID: tag})
+
+	if err != nil {
+		// Once the stream has started, errors are sent as a message.
+		enc.Encode(pullMessage{Error: err.Error(), ErrorDetail: &errorResponse{Message: err.Error()}})
+		return
+	}
+
+	if upToDate {
+		img, _ = e.getImage(ref)
+	} else {
+		layer := strings.TrimPrefix(digestOf("layer:"+ref), "sha256:")[:12]
+		enc.Encode(pullMessage{Status: "Pulling fs layer", ID: layer})
+		for _, current := range []int64{img.Size / 2, img.Size} {
+			enc.Encode(pullMessage{
+				Status:         "Downloading",
+				ProgressDetail: &progressDetail{Current: current, Total: img.Size},
+				Progress:       fmt.Sprintf("%d/%d", current, img.Size),
+				ID:             layer,
+			})
+		}
+		enc.Encode(pullMessage{Status: "Download complete", ID: layer})
+		enc.Encode(pullMessage{Status: "Pull complete", ID: layer})
+
+		e.mu.Lock()
+		img.ID = e.addImage(img)
+		e.mu.Unlock()
+		e.events.add("image", "pull", ref, map[string]string{"name": ref})
+	}
+
+	digest := img.ID
+	if len(img.RepoDigests) > 0 {
+		_, digest, _ = strings.Cut(img.RepoDigests[0], "@")
+	}
+	enc.Encode(pullMessage{Status: "Digest: " + digest})
+
+	if upToDate {
+		enc.Encode(pullMessage{Status: "Status: Image is up to date for " + ref})
+	} else {
+		enc.Encode(pullMessage{Status: "Status: Downloaded newer image for " + ref})
+	}
+}
+
+type imageDeleteResponse struct {
+	Untagged string `json:",omitempty"`
+	Deleted  string `json:",omitempty"`
+}
+
+func (e *Engine) handleImageRemove(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	force, _ := strconv.ParseBool(req.URL.Query().Get("force"))
+
+	resp, err := e.removeImage(name, force)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (e *Engine) removeImage(name string, force bool) ([]imageDeleteResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	img, byRef, err := e.lookupImage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the daemon, removing the last tag deletes the image even if it still has digest references.
+	untagOnly := len(img.RepoTags) > 1
+	if strings.Contains(name, "@") {
+		untagOnly = len(img.RepoTags)+len(img.RepoDigests) > 1
+	}
+	if byRef && untagOnly {
+		ref := normalizeRef(name)
+		img.RepoTags = removeAll(img.RepoTags, ref)
+		img.RepoDigests = removeAll(img.RepoDigests, ref)
+		e.events.add("image", "untag", img.ID, map[string]string{"name": ref})
+		return []imageDeleteResponse{{Untagged: ref}}, nil
+	}
+
+	for _, c := range e.containers {
+		if c.imageID != img.ID {
+			continue
+		}
+		c.mu.Lock()
+		running := c.current != nil
+		c.mu.Unlock()
+		if running {
+			return nil, errdefs.Conflictf("conflict: unable to delete %s (cannot be forced) - image is being used by running container %s", shortID(img.ID), shortID(c.id))
+		}
+		if !force {
+			return nil, errdefs.Conflictf("conflict: unable to delete %s (must be forced) - image is being used by stopped container %s", shortID(img.ID), shortID(c.id))
+		}
+	}
+	if !byRef && !force && len(img.RepoTags) > 1 {
+		return nil, errdefs.Conflictf("conflict: unable to delete %s (must be forced) - image is referenced in multiple repositories", shortID(img.ID))
+	}
+
+	var resp []imageDeleteResponse
+	for _, ref := range append(append([]string(nil), img.RepoTags...), img.RepoDigests...) {
+		resp = append(resp, imageDeleteResponse{Untagged: ref})
+		e.events.add("image", "untag", img.ID, map[string]string{"name": ref})
+	}
+	resp = append(resp, imageDeleteResponse{Deleted: img.ID})
+	delete(e.images, img.ID)
+	e.events.add("image", "delete", img.ID, map[string]string{"name": img.ID})
+	return resp, nil
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
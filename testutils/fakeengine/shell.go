@@ -0,0 +1,249 @@
+package fakeengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// shell emulates `sh -c <script>` for the small subset of shell used in tests:
+//
+//   - statements separated by newlines, `;`, or `&&`
+//   - single and double quoted arguments (without expansion)
+//   - `>&2` and `2>&1` redirects
+//   - `exit [n]`
+//   - `trap '<script>' <signal>...`, including EXIT
+//   - `while <cmd>; do ...; done`
+//
+// Everything else is run as a command, see `Command`.
+func (e *Engine) shell(ctx context.Context, args []string, stdio Stdio) int {
+	if len(args) < 3 || args[1] != "-c" {
+		// Interactive shell, there is nothing to do but wait.
+		return blockingCommand(ctx, args, stdio)
+	}
+
+	stmts, err := parseScript(args[2])
+	if err != nil {
+		io.WriteString(stdio.Stderr, "sh: "+err.Error()+"\n")
+		return 2
+	}
+
+	s := &shellState{e: e, stdio: stdio, traps: make(map[string]string)}
+	code, exited := s.run(ctx, stmts)
+	if !exited && ctx.Err() != nil {
+		code = exitCode(ctx, code)
+		if action, ok := s.traps[strconv.Itoa(Signal(ctx))]; ok {
+			code, exited = s.runTrap(action)
+		}
+	}
+	if !exited {
+		if action, ok := s.traps["0"]; ok {
+			if trapCode, trapExited := s.runTrap(action); trapExited {
+				code = trapCode
+			}
+		}
+	}
+	return code
+}
+
+type shellState struct {
+	e     *Engine
+	stdio Stdio
+	// traps maps signal numbers (0 for EXIT) to the script to run.
+	traps map[string]string
+}
+
+// runTrap runs a trap action, which cannot be interrupted.
+func (s *shellState) runTrap(action string) (int, bool) {
+	stmts, err := parseScript(action)
+	if err != nil {
+		return 2, true
+	}
+	delete(s.traps, "0")
+	return s.run(context.Background(), stmts)
+}
+
+// statement is a list of words, and the operator which preceded it.
+type statement struct {
+	op    string
+	words []string
+}
+
+// run runs the statements and returns the status of the last one.
+// The returned bool is true if the script called `exit`.
+func (s *shellState) run(ctx context.Context, stmts []statement) (int, bool) {
+	var status int
+	for i := 0; i < len(stmts); i++ {
+		if ctx.Err() != nil {
+			return status, false
+		}
+
+		stmt := stmts[i]
+		if stmt.op == "&&" && status != 0 {
+			continue
+		}
+
+		switch stmt.words[0] {
+		case "do", "then":
+		case "exit":
+			code := status
+			if len(stmt.words) > 1 {
+				code, _ = strconv.Atoi(stmt.words[1])
+			}
+			return code, true
+		case "trap":
+			if len(stmt.words) < 3 {
+				status = 1
+				continue
+			}
+			for _, name := range stmt.words[2:] {
+				if name == "EXIT" || name == "0" {
+					s.traps["0"] = stmt.words[1]
+					continue
+				}
+				if sig, ok := parseSignal(name); ok {
+					s.traps[strconv.Itoa(sig)] = stmt.words[1]
+				}
+			}
+			status = 0
+		case "while":
+			end := findDone(stmts, i)
+			if end < 0 {
+				io.WriteString(s.stdio.Stderr, "sh: syntax error: missing done\n")
+				return 2, true
+			}
+			body := stmts[i+1 : end]
+			cond := []statement{{words: stmt.words[1:]}}
+			status = 0
+			for {
+				if c, exited := s.run(ctx, cond); exited {
+					return c, true
+				} else if c != 0 || ctx.Err() != nil {
+					break
+				}
+				c, exited := s.run(ctx, body)
+				if exited {
+					return c, true
+				}
+				status = c
+			}
+			i = end
+		default:
+			status = s.exec(ctx, stmt.words)
+		}
+	}
+	return status, false
+}
+
+// findDone returns the index of the "done" matching the "while" at stmts[start].
+func findDone(stmts []statement, start int) int {
+	depth := 0
+	for i := start; i < len(stmts); i++ {
+		switch stmts[i].words[0] {
+		case "while":
+			depth++
+		case "done":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// exec runs a single command with redirects applied.
+func (s *shellState) exec(ctx context.Context, words []string) int {
+	stdio := s.stdio
+	args := make([]string, 0, len(words))
+	for _, w := range words {
+		switch w {
+		case ">&2", "1>&2":
+			stdio.Stdout = s.stdio.Stderr
+		case "2>&1":
+			stdio.Stderr = s.stdio.Stdout
+		default:
+			args = append(args, w)
+		}
+	}
+	if len(args) == 0 {
+		return 0
+	}
+	return s.e.lookupCommand(args)(ctx, args, stdio)
+}
+
+// parseScript splits a script into statements.
+// "do" is treated as a statement of its own so loop bodies are easy to find.
+func parseScript(script string) ([]statement, error) {
+	var (
+		stmts   []statement
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		op      string
+		quote   rune
+		escaped bool
+	)
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endStmt := func(next string) {
+		endWord()
+		for len(words) > 0 && (words[0] == "do" || words[0] == "then") {
+			stmts = append(stmts, statement{op: op, words: []string{words[0]}})
+			words = words[1:]
+			op = ""
+		}
+		if len(words) > 0 {
+			stmts = append(stmts, statement{op: op, words: words})
+		}
+		words = nil
+		op = next
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			inWord = true
+			escaped = true
+		case r == '\'' || r == '"':
+			inWord = true
+			quote = r
+		case r == ';' || r == '\n':
+			endStmt("")
+		case r == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			endStmt("&&")
+			i++
+		case r == ' ' || r == '\t':
+			endWord()
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("syntax error: unterminated quoted string")
+	}
+	endStmt("")
+	return stmts, nil
+}
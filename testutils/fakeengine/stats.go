@@ -0,0 +1,146 @@
+package fakeengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// statsInterval is how often stats are sampled when streaming. The daemon samples once per second.
+const statsInterval = 50 * time.Millisecond
+
+// Emulated resource usage of a running container, in the cgroup v2 shape.
+// Each sample adds 10ms of CPU time out of 100ms of system time on 2 CPUs, which is 20%, and some I/O.
+const (
+	statsOnlineCPUs     = 2
+	statsCPUPerSample   = uint64(10 * time.Millisecond)
+	statsSysPerSample   = uint64(100 * time.Millisecond)
+	statsMemoryUsage    = 64 << 20
+	statsInactiveFile   = 16 << 20
+	statsMemoryLimit    = 1 << 30
+	statsRxPerSample    = 1000
+	statsTxPerSample    = 500
+	statsReadPerSample  = 4096
+	statsWritePerSample = 2048
+)
+
+// stats returns the n-th emulated sample of the resource usage of the container.
+// A container which is not running has no usage.
+func (c *container) stats(n uint64, running bool) containerapi.Stats {
+	s := containerapi.Stats{
+		Name: "/" + c.name,
+		ID:   c.id,
+	}
+	if !running || n == 0 {
+		return s
+	}
+
+	s.PidsStats = containerapi.PidsStats{Current: 1}
+	s.CPUStats = containerapi.CPUStats{
+		CPUUsage: containerapi.CPUUsage{
+			TotalUsage:        n * statsCPUPerSample,
+			UsageInKernelmode: n * statsCPUPerSample / 2,
+			UsageInUsermode:   n * statsCPUPerSample / 2,
+		},
+		SystemUsage: n * statsSysPerSample,
+		OnlineCPUs:  statsOnlineCPUs,
+	}
+	s.MemoryStats = containerapi.MemoryStats{
+		Usage: statsMemoryUsage,
+		Stats: map[string]uint64{"anon": statsMemoryUsage - statsInactiveFile, "file": statsInactiveFile, "inactive_file": statsInactiveFile},
+		Limit: statsMemoryLimit,
+	}
+	s.BlkioStats.IoServiceBytesRecursive = []containerapi.BlkioStatEntry{
+		{Major: 8, Op: "read", Value: n * statsReadPerSample},
+		{Major: 8, Op: "write", Value: n * statsWritePerSample},
+	}
+	s.Networks = map[string]containerapi.NetworkStats{
+		"eth0": {RxBytes: n * statsRxPerSample, RxPackets: n, TxBytes: n * statsTxPerSample, TxPackets: n},
+	}
+	return s
+}
+
+func (c *container) isRunning() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Running
+}
+
+func (e *Engine) handleContainerStats(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	q := req.URL.Query()
+	stream := true
+	if v := q.Get("stream"); v != "" {
+		stream, err = strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, errdefs.Invalidf("invalid value for stream: %v", err))
+			return
+		}
+	}
+	oneShot, _ := strconv.ParseBool(q.Get("one-shot"))
+	if oneShot && stream {
+		writeError(w, errdefs.Invalid("cannot have stream=true and one-shot=true"))
+		return
+	}
+
+	// Like the daemon, each sample has the previous one as its "pre" sample.
+	var (
+		n    uint64
+		prev containerapi.Stats
+	)
+	sample := func() containerapi.Stats {
+		running := c.isRunning()
+		if running {
+			n++
+		}
+		s := c.stats(n, running)
+		s.Read = now()
+		if running {
+			s.PreRead = prev.Read
+			s.PreCPUStats = prev.CPUStats
+		}
+		prev = s
+		return s
+	}
+
+	if !stream {
+		if !oneShot {
+			// Without one-shot the daemon waits for a second sample so that PreCPUStats is set.
+			sample()
+		}
+		writeJSON(w, http.StatusOK, sample())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(&flushWriter{w})
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		s := sample()
+		if err := enc.Encode(s); err != nil {
+			return
+		}
+		if s.PidsStats.Current == 0 {
+			// The container is not running
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
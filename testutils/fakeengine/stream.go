@@ -0,0 +1,91 @@
+package fakeengine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/container/streamutil"
+)
+
+const (
+	mediaTypeRawStream   = "application/vnd.docker.raw-stream"
+	mediaTypeMultiplexed = "application/vnd.docker.multiplexed-stream"
+)
+
+// writeFrame writes data to w in the multiplexed stream format, which is read by streamutil.StdCopy.
+func writeFrame(w io.Writer, stream int, data []byte) error {
+	hdr := make([]byte, 8, 8+len(data))
+	hdr[0] = byte(stream)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(data)))
+	_, err := w.Write(append(hdr, data...))
+	return err
+}
+
+// streamWriter writes one stdio stream to a connection, multiplexed or not.
+// Multiple streamWriters may share a connection, so writes are serialized with mu.
+type streamWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	stream int
+	mux    bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.mux {
+		return s.w.Write(p)
+	}
+	if err := writeFrame(s.w, s.stream, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// newStdioWriters returns writers for stdout and stderr which share w.
+// When tty is set, there is only one stream, so both write raw data to w.
+func newStdioWriters(w io.Writer, tty bool) (stdout, stderr io.Writer) {
+	mu := &sync.Mutex{}
+	stdout = &streamWriter{mu: mu, w: w, stream: streamutil.Stdout, mux: !tty}
+	stderr = &streamWriter{mu: mu, w: w, stream: streamutil.Stderr, mux: !tty}
+	return stdout, stderr
+}
+
+// hijack takes over the connection of the request and writes the response headers for an upgraded stdio stream.
+// If the client did not ask to upgrade the connection, the stream is sent as the body of a 200 response like the daemon does.
+func hijack(w http.ResponseWriter, req *http.Request, contentType string) (net.Conn, *bufio.Reader, error) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.Header.Get("Upgrade") != "" {
+		fmt.Fprintf(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: %s\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n", contentType)
+	} else {
+		fmt.Fprintf(rw, "HTTP/1.1 200 OK\r\nContent-Type: %s\r\n\r\n", contentType)
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw.Reader, nil
+}
+
+// flushWriter flushes the response after every write so streamed data is sent immediately.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f, ok := f.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
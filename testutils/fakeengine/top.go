@@ -0,0 +1,120 @@
+package fakeengine
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/container/containerapi"
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+var (
+	psEFTitles       = []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"}
+	psAuxTitles      = []string{"USER", "PID", "%CPU", "%MEM", "VSZ", "RSS", "TTY", "STAT", "START", "TIME", "COMMAND"}
+	windowsTopTitles = []string{"Name", "PID", "CPU", "Private Working Set"}
+)
+
+// process is an emulated process listed by top.
+type process struct {
+	pid     int
+	user    string
+	tty     bool
+	started time.Time
+	args    []string
+}
+
+// processes returns the container process followed by the running exec processes.
+func (e *Engine) processes(c *container) ([]process, error) {
+	c.mu.Lock()
+	if c.current == nil {
+		c.mu.Unlock()
+		return nil, errdefs.Conflictf("Container %s is not running", c.id)
+	}
+	started, _ := time.Parse(time.RFC3339Nano, c.state.StartedAt)
+	procs := []process{{
+		pid:     c.state.Pid,
+		user:    c.config.User,
+		tty:     c.config.Tty,
+		started: started,
+		args:    append([]string{c.path}, c.args...),
+	}}
+	execIDs := append([]string(nil), c.execs...)
+	c.mu.Unlock()
+
+	for _, id := range execIDs {
+		ex, err := e.getExec(id)
+		if err != nil {
+			continue
+		}
+		ex.mu.Lock()
+		if ex.running {
+			procs = append(procs, process{pid: ex.pid, user: ex.config.User, tty: ex.config.Tty, started: started, args: ex.config.Cmd})
+		}
+		ex.mu.Unlock()
+	}
+	return procs, nil
+}
+
+// handleContainerTop emulates `ps -ef` (the default) and `ps aux` output; other ps arguments are an error.
+func (e *Engine) handleContainerTop(w http.ResponseWriter, req *http.Request) {
+	c, err := e.getContainer(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	psArgs := req.URL.Query().Get("ps_args")
+	windows := e.cfg.OSType == "windows"
+	if windows && psArgs != "" {
+		writeError(w, errdefs.Invalid("Windows does not support arguments to top"))
+		return
+	}
+
+	var top containerapi.Top
+	aux := strings.TrimPrefix(psArgs, "-") == "aux"
+	switch {
+	case windows:
+		top.Titles = windowsTopTitles
+	case psArgs == "" || psArgs == "-ef":
+		top.Titles = psEFTitles
+	case aux:
+		top.Titles = psAuxTitles
+	default:
+		writeError(w, errdefs.Systemf("Error running top: ps arguments %q are not emulated", psArgs))
+		return
+	}
+
+	procs, err := e.processes(c)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	top.Processes = make([][]string, 0, len(procs))
+	for _, p := range procs {
+		pid := strconv.Itoa(p.pid)
+		user := p.user
+		if user == "" {
+			user = "root"
+		}
+		tty := "?"
+		if p.tty {
+			tty = "pts/0"
+		}
+		cmd := strings.Join(p.args, " ")
+
+		var row []string
+		switch {
+		case windows:
+			row = []string{p.args[0], pid, "00:00:00.000", "1.2MB"}
+		case aux:
+			row = []string{user, pid, "0.0", "0.0", "1024", "512", tty, "S", p.started.Format("15:04"), "0:00", cmd}
+		default:
+			row = []string{user, pid, "0", "0", p.started.Format("15:04"), tty, "00:00:00", cmd}
+		}
+		top.Processes = append(top.Processes, row)
+	}
+	writeJSON(w, http.StatusOK, top)
+}
@@ -0,0 +1,161 @@
+package fakeengine
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// wsGUID is used to compute the Sec-WebSocket-Accept header, see RFC 6455 section 4.2.2.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxPayload is the largest frame accepted from a client.
+const wsMaxPayload = 1 << 20
+
+// hijackWebSocket completes the WebSocket handshake for the request and takes over the connection.
+func hijackWebSocket(w http.ResponseWriter, req *http.Request) (*wsConn, error) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || key == "" {
+		err := errdefs.Invalid("websocket upgrade required")
+		writeError(w, err)
+		return nil, err
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// wsConn is the server side of a WebSocket connection.
+// Reads return the payload of data frames from the client, and writes are sent as binary frames.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	buf  []byte
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case 0x8: // close
+			c.writeFrame(0x8, payload)
+			return 0, io.EOF
+		case 0x9: // ping
+			c.writeFrame(0xA, payload)
+		case 0x0, 0x1, 0x2: // data
+			c.buf = payload
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(0x2, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close sends a close frame and closes the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(0x8, []byte{0x03, 0xe8}) // 1000, normal closure
+	return c.conn.Close()
+}
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	op := hdr[0] & 0x0f
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, errors.New("websocket: client frames must be masked")
+	}
+
+	length := uint64(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	if length > wsMaxPayload {
+		return 0, nil, errors.New("websocket: frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// writeFrame writes an unmasked frame, as sent by servers.
+// Nothing is written after a close frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	if op == 0x8 {
+		c.closed = true
+	}
+
+	hdr := []byte{0x80 | op}
+	switch l := len(payload); {
+	case l <= 125:
+		hdr = append(hdr, byte(l))
+	case l <= 0xffff:
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(l))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(l))
+	}
+	_, err := c.conn.Write(append(hdr, payload...))
+	return err
+}
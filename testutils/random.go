@@ -3,6 +3,9 @@ package testutils
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"testing"
+
+	"gotest.tools/v3/assert"
 )
 
 // GenerateRandomString generates a random string
@@ -16,3 +19,21 @@ func GenerateRandomString() string {
 
 	return hex.EncodeToString(buf[:n])
 }
+
+// RandomString generates a random string for the test, e.g. for the name of a container.
+// When the test is recorded or replayed (see EnvCassetteMode), the string is saved in the cassette of the test, and the
+// same one is returned on replay.
+func RandomString(t testing.TB) string {
+	t.Helper()
+
+	c := testCassetteFor(t)
+	switch {
+	case c == nil:
+		return GenerateRandomString()
+	case c.rec != nil:
+		return c.rec.Value(GenerateRandomString())
+	}
+	v, err := c.rep.Value()
+	assert.NilError(t, err)
+	return v
+}
@@ -7,16 +7,10 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
-	"regexp"
 
 	"github.com/cpuguy83/go-docker/transport"
 )
 
-var (
-	// regex to match any non-empty identity token
-	jsonIdentityTokenRegex = regexp.MustCompile(`"((?i)identitytoken|password|auth)":\ ?".*"`)
-)
-
 func NewTransport(t LogT, client transport.Doer, noTap bool) *Transport {
 	return &Transport{client, t, noTap}
 }
@@ -140,5 +134,5 @@ func (t *Transport) logResponse(resp *http.Response, err error) (*http.Response,
 }
 
 func filterBuf(buf *bytes.Buffer) *bytes.Buffer {
-	return bytes.NewBuffer(jsonIdentityTokenRegex.ReplaceAll(buf.Bytes(), []byte(`"${1}": "<REDACTED>"`)))
+	return bytes.NewBuffer(transport.RedactJSON(buf.Bytes()))
 }
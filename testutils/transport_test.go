@@ -20,9 +20,8 @@ func TestFilter(t *testing.T) {
 
 	for _, c := range cases {
 		buf := bytes.NewBuffer([]byte(`{"` + c + `": "foo"}`))
-		assert.Check(t, jsonIdentityTokenRegex.Match(buf.Bytes()))
-
 		filtered := filterBuf(buf)
+		assert.Check(t, !bytes.Equal(filtered.Bytes(), buf.Bytes()))
 		assert.Check(t, bytes.Contains(filtered.Bytes(), []byte(`"<REDACTED>"`)))
 		assert.Check(t, !bytes.Contains(filtered.Bytes(), []byte(`"foo"`)))
 	}
@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// defaultUnixSocket is the socket used by a rootful docker daemon.
+const defaultUnixSocket = "/var/run/docker.sock"
+
+// unixSocketPingTimeout is the maximum amount of time to wait for a candidate socket to respond to a ping.
+const unixSocketPingTimeout = 2 * time.Second
+
+// discoveryTimeout bounds the socket discovery done by functions which do not take a context.
+const discoveryTimeout = 10 * time.Second
+
+// UnixSocketCandidate is a unix socket which may be used to connect to a docker compatible daemon.
+type UnixSocketCandidate struct {
+	// Path is the path to the socket.
+	Path string
+	// Description says what kind of daemon is expected to be listening on the socket, e.g. "rootless docker".
+	Description string
+	// Err is the reason the candidate was rejected.
+	// This is nil for the selected candidate, and for candidates returned by DefaultUnixSocketCandidates.
+	Err error
+	// APIVersion is the API version reported by the daemon, if it was reachable.
+	APIVersion string
+}
+
+// UnixSocketDiscovery is the result of `DiscoverUnixSocket`.
+type UnixSocketDiscovery struct {
+	// Path is the path of the selected socket.
+	// This is empty if no daemon was found.
+	Path string
+	// Candidates are the sockets that were checked, in order, including the selected one.
+	// Candidates after the selected one are not checked and so are not included.
+	Candidates []UnixSocketCandidate
+}
+
+// Selected returns the candidate which was selected.
+// The returned bool is false if no candidate was selected.
+func (d *UnixSocketDiscovery) Selected() (UnixSocketCandidate, bool) {
+	for _, c := range d.Candidates {
+		if c.Path == d.Path && c.Err == nil {
+			return c, true
+		}
+	}
+	return UnixSocketCandidate{}, false
+}
+
+// DefaultUnixSocketCandidates returns the sockets which are checked by `DiscoverUnixSocket`, in order:
+//
+//  1. $XDG_RUNTIME_DIR/docker.sock, used by rootless docker
+//  2. ~/.docker/run/docker.sock, used by Docker Desktop
+//  3. /var/run/docker.sock, used by a rootful docker daemon
+//  4. $XDG_RUNTIME_DIR/podman/podman.sock, used by the docker compatible API of rootless podman
+//  5. /run/podman/podman.sock, used by the docker compatible API of rootful podman
+//
+// If XDG_RUNTIME_DIR is not set, /run/user/<uid> is used.
+// Candidates that cannot be determined, such as ones in the home directory if there is no home directory, are skipped.
+func DefaultUnixSocketCandidates() []UnixSocketCandidate {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		if uid := os.Getuid(); uid >= 0 {
+			runtimeDir = filepath.Join("/run/user", strconv.Itoa(uid))
+		}
+	}
+
+	var candidates []UnixSocketCandidate
+	if runtimeDir != "" {
+		candidates = append(candidates, UnixSocketCandidate{Path: filepath.Join(runtimeDir, "docker.sock"), Description: "rootless docker"})
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, UnixSocketCandidate{Path: filepath.Join(home, ".docker", "run", "docker.sock"), Description: "docker desktop"})
+	}
+	candidates = append(candidates, UnixSocketCandidate{Path: defaultUnixSocket, Description: "docker"})
+	if runtimeDir != "" {
+		candidates = append(candidates, UnixSocketCandidate{Path: filepath.Join(runtimeDir, "podman", "podman.sock"), Description: "rootless podman"})
+	}
+	candidates = append(candidates, UnixSocketCandidate{Path: "/run/podman/podman.sock", Description: "podman"})
+	return candidates
+}
+
+// DiscoverUnixSocket finds the unix socket of a running docker compatible daemon.
+//
+// Each of `DefaultUnixSocketCandidates` is checked in order, and the first one that responds to a ping is selected.
+// The connection options are used to create the transport used to ping each candidate.
+//
+// If no daemon responds, the returned error is an errdefs.NotFound error, and the result holds the reason each
+// candidate was rejected.
+func DiscoverUnixSocket(ctx context.Context, opts ...ConnectionOption) (*UnixSocketDiscovery, error) {
+	return discoverUnixSocket(ctx, DefaultUnixSocketCandidates(), opts...)
+}
+
+func discoverUnixSocket(ctx context.Context, candidates []UnixSocketCandidate, opts ...ConnectionOption) (*UnixSocketDiscovery, error) {
+	result := &UnixSocketDiscovery{}
+	for _, c := range candidates {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		c.APIVersion, c.Err = pingUnixSocket(ctx, c.Path, opts...)
+		result.Candidates = append(result.Candidates, c)
+		if c.Err == nil {
+			result.Path = c.Path
+			return result, nil
+		}
+	}
+
+	var reasons []string
+	for _, c := range result.Candidates {
+		reasons = append(reasons, c.Path+": "+c.Err.Error())
+	}
+	return result, errdefs.NotFoundf("no docker daemon found: %s", strings.Join(reasons, "; "))
+}
+
+func pingUnixSocket(ctx context.Context, sock string, opts ...ConnectionOption) (string, error) {
+	fi, err := os.Stat(sock)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errdefs.NotFound("socket does not exist")
+		}
+		return "", err
+	}
+	if fi.Mode().Type() != os.ModeSocket {
+		return "", errdefs.Invalid("not a socket")
+	}
+
+	tr, err := UnixSocketTransport(sock, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer tr.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, unixSocketPingTimeout)
+	defer cancel()
+
+	resp, err := tr.Do(ctx, http.MethodGet, "/_ping")
+	if err != nil {
+		return "", fmt.Errorf("ping failed: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errdefs.Unavailablef("ping failed with status code %d", resp.StatusCode)
+	}
+	return resp.Header.Get("API-Version"), nil
+}
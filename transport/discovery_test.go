@@ -0,0 +1,155 @@
+//go:build unix
+// +build unix
+
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func serveUnixPing(t *testing.T, sock string) net.Listener {
+	t.Helper()
+
+	assert.NilError(t, os.MkdirAll(filepath.Dir(sock), 0o755))
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/_ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("API-Version", "1.41")
+		w.Write([]byte("OK"))
+	}))
+	return l
+}
+
+func TestDiscoverUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	runtimeDir := filepath.Join(dir, "run")
+	home := filepath.Join(dir, "home")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("HOME", home)
+
+	candidates := DefaultUnixSocketCandidates()
+	assert.Assert(t, len(candidates) >= 3)
+	assert.Check(t, cmp.Equal(candidates[0].Path, filepath.Join(runtimeDir, "docker.sock")))
+	assert.Check(t, cmp.Equal(candidates[1].Path, filepath.Join(home, ".docker", "run", "docker.sock")))
+	assert.Check(t, cmp.Equal(candidates[2].Path, "/var/run/docker.sock"))
+
+	// Rootless docker socket path exists but is not a socket
+	assert.NilError(t, os.MkdirAll(runtimeDir, 0o755))
+	assert.NilError(t, os.WriteFile(candidates[0].Path, nil, 0o600))
+	serveUnixPing(t, candidates[1].Path)
+
+	ctx := context.Background()
+	d, err := DiscoverUnixSocket(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(d.Path, candidates[1].Path))
+	assert.Assert(t, cmp.Len(d.Candidates, 2))
+	assert.Check(t, errdefs.IsInvalid(d.Candidates[0].Err), d.Candidates[0].Err)
+
+	selected, ok := d.Selected()
+	assert.Assert(t, ok)
+	assert.Check(t, cmp.Equal(selected.Description, "docker desktop"))
+	assert.Check(t, cmp.Equal(selected.APIVersion, "1.41"))
+
+	// A stale socket which nothing is listening on
+	stale := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", stale)
+	assert.NilError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	d, err = discoverUnixSocket(ctx, []UnixSocketCandidate{
+		{Path: filepath.Join(dir, "missing.sock")},
+		{Path: stale},
+	})
+	assert.Check(t, errdefs.IsNotFound(err), err)
+	assert.Check(t, cmp.Equal(d.Path, ""))
+	assert.Assert(t, cmp.Len(d.Candidates, 2))
+	assert.Check(t, errdefs.IsNotFound(d.Candidates[0].Err), d.Candidates[0].Err)
+	assert.Check(t, cmp.ErrorContains(d.Candidates[1].Err, "ping failed"))
+	_, ok = d.Selected()
+	assert.Check(t, !ok)
+}
+
+func TestFromEnvDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	runtimeDir := filepath.Join(dir, "run")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv(EnvDockerHost, "")
+	t.Setenv(EnvDockerTLSVerify, "")
+	t.Setenv(EnvDockerCertPath, "")
+
+	sock := filepath.Join(runtimeDir, "docker.sock")
+	serveUnixPing(t, sock)
+
+	ctx := context.Background()
+
+	host, err := DiscoverHost(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(host, "unix://"+sock))
+
+	tr, err := FromEnv()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(tr.host, sock))
+
+	tr, err = DefaultUnixTransportContext(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(tr.host, sock))
+
+	// DOCKER_HOST takes precedence
+	t.Setenv(EnvDockerHost, "unix:///other.sock")
+	tr, err = FromEnv()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(tr.host, "/other.sock"))
+	t.Setenv(EnvDockerHost, "")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = FromEnvContext(cancelled)
+	assert.Check(t, cmp.ErrorIs(err, context.Canceled))
+	_, err = DefaultUnixTransportContext(cancelled)
+	assert.Check(t, cmp.ErrorIs(err, context.Canceled))
+}
+
+func TestFromEnvNoDaemon(t *testing.T) {
+	for _, sock := range []string{defaultUnixSocket, "/run/podman/podman.sock"} {
+		if _, err := os.Stat(sock); err == nil {
+			t.Skip("a daemon socket exists at " + sock)
+		}
+	}
+
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dir, "run"))
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv(EnvDockerHost, "")
+	t.Setenv(EnvDockerTLSVerify, "")
+	t.Setenv(EnvDockerCertPath, "")
+
+	_, err := FromEnvContext(context.Background())
+	assert.Check(t, errdefs.IsNotFound(err), err)
+	_, err = DefaultUnixTransportContext(context.Background())
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	// Without a context, the default socket is used.
+	tr, err := FromEnv()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(tr.host, defaultUnixSocket))
+	tr, err = DefaultUnixTransport()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(tr.host, defaultUnixSocket))
+}
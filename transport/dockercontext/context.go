@@ -0,0 +1,264 @@
+// Package dockercontext resolves docker CLI contexts into transports without needing the docker CLI.
+//
+// Contexts are read from the same on-disk store that the docker CLI uses:
+//
+//	~/.docker/config.json                            - holds the "currentContext"
+//	~/.docker/contexts/meta/<sha256(name)>/meta.json - holds the context endpoints
+//	~/.docker/contexts/tls/<sha256(name)>/docker/    - holds ca.pem, cert.pem, and key.pem for the docker endpoint
+package dockercontext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/transport"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// DefaultContextName is the name of the implicit context which uses DOCKER_HOST or the platform default.
+	DefaultContextName = "default"
+
+	// EnvDockerContext is the environment variable used to select a context.
+	EnvDockerContext = "DOCKER_CONTEXT"
+	// EnvDockerConfig is the environment variable used to override the docker config directory.
+	EnvDockerConfig = "DOCKER_CONFIG"
+
+	dockerEndpoint = "docker"
+
+	// discoveryTimeout bounds finding the daemon for the default context, for the functions which do not take a
+	// context.
+	discoveryTimeout = 10 * time.Second
+)
+
+// Endpoint is the docker endpoint of a context.
+type Endpoint struct {
+	Host          string
+	SkipTLSVerify bool
+}
+
+// Context is a docker CLI context.
+type Context struct {
+	Name        string
+	Description string
+	Endpoint    Endpoint
+	// TLSDir is the directory holding the TLS material for the docker endpoint.
+	// This is empty when the context has no TLS material.
+	TLSDir string
+}
+
+type contextMeta struct {
+	Name     string
+	Metadata struct {
+		Description string `json:",omitempty"`
+	}
+	Endpoints map[string]Endpoint
+}
+
+type configFile struct {
+	CurrentContext string            `json:"currentContext,omitempty"`
+	HTTPHeaders    map[string]string `json:"HttpHeaders,omitempty"`
+}
+
+// Store reads contexts from a docker config directory.
+// Create one with `NewStore`.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a Store for the provided docker config directory.
+// If dir is empty, DefaultConfigDir is used.
+func NewStore(dir string) *Store {
+	if dir == "" {
+		dir = DefaultConfigDir()
+	}
+	return &Store{dir: dir}
+}
+
+// DefaultConfigDir returns the docker config directory.
+// This is $DOCKER_CONFIG if set, otherwise ~/.docker.
+func DefaultConfigDir() string {
+	if dir := os.Getenv(EnvDockerConfig); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// Current returns the name of the context that should be used, in the same order of precedence as the docker CLI:
+//
+//  1. $DOCKER_CONTEXT
+//  2. The default context if $DOCKER_HOST is set
+//  3. The "currentContext" from config.json
+//  4. The default context
+func (s *Store) Current() (string, error) {
+	if name := os.Getenv(EnvDockerContext); name != "" {
+		return name, nil
+	}
+	if os.Getenv(transport.EnvDockerHost) != "" {
+		return DefaultContextName, nil
+	}
+
+	cfg, err := s.readConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.CurrentContext == "" {
+		return DefaultContextName, nil
+	}
+	return cfg.CurrentContext, nil
+}
+
+// HTTPHeaders returns the "HttpHeaders" from config.json, which the docker CLI adds to every request to the daemon.
+// This is empty if there is no config.json.
+func (s *Store) HTTPHeaders() (map[string]string, error) {
+	cfg, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.HTTPHeaders, nil
+}
+
+// readConfig reads config.json from the store.
+// A missing config.json is treated as an empty one.
+func (s *Store) readConfig() (configFile, error) {
+	var cfg configFile
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "config.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("error reading docker config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error parsing docker config: %w", err)
+	}
+	return cfg, nil
+}
+
+func contextDir(name string) string {
+	return digest.FromString(name).Encoded()
+}
+
+// Inspect reads the context with the provided name from the store.
+//
+// The default context is not stored on disk and is resolved from DOCKER_HOST or, if it is not set, the daemon found
+// by transport.DiscoverHost.
+// If no daemon is found the platform default is reported, use transport.DiscoverHost to find out why.
+func (s *Store) Inspect(name string) (Context, error) {
+	if name == DefaultContextName {
+		host := os.Getenv(transport.EnvDockerHost)
+		if host == "" {
+			ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+			host, _ = transport.DiscoverHost(ctx)
+			cancel()
+		}
+		return Context{
+			Name:        DefaultContextName,
+			Description: "Current DOCKER_HOST based configuration",
+			Endpoint:    Endpoint{Host: host},
+		}, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "contexts", "meta", contextDir(name), "meta.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Context{}, errdefs.NotFoundf("context %q", name)
+		}
+		return Context{}, fmt.Errorf("error reading context metadata: %w", err)
+	}
+
+	var meta contextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Context{}, fmt.Errorf("error parsing context metadata for %q: %w", name, err)
+	}
+
+	ep, ok := meta.Endpoints[dockerEndpoint]
+	if !ok || ep.Host == "" {
+		return Context{}, errdefs.Invalidf("context %q has no docker endpoint", name)
+	}
+
+	c := Context{
+		Name:        meta.Name,
+		Description: meta.Metadata.Description,
+		Endpoint:    ep,
+	}
+
+	tlsDir := filepath.Join(s.dir, "contexts", "tls", contextDir(name), dockerEndpoint)
+	if fi, err := os.Stat(tlsDir); err == nil && fi.IsDir() {
+		c.TLSDir = tlsDir
+	}
+
+	return c, nil
+}
+
+// Transport creates a transport for the context with the provided name.
+// Any passed in options are applied after the context's TLS configuration.
+// The transport for the default context is created with transport.FromEnv.
+func (s *Store) Transport(name string, opts ...transport.ConnectionOption) (*transport.Transport, error) {
+	if name == DefaultContextName {
+		return transport.FromEnv(opts...)
+	}
+	return s.TransportContext(context.Background(), name, opts...)
+}
+
+// TransportContext is like Transport, but finding the daemon for the default context when DOCKER_HOST is not set is
+// bounded by the context, and an error is returned if none is found, see transport.FromEnvContext.
+func (s *Store) TransportContext(ctx context.Context, name string, opts ...transport.ConnectionOption) (*transport.Transport, error) {
+	if name == DefaultContextName {
+		return transport.FromEnvContext(ctx, opts...)
+	}
+
+	c, err := s.Inspect(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TLSDir != "" || c.Endpoint.SkipTLSVerify {
+		dir := c.TLSDir
+		if dir == "" {
+			// Nothing to load, this just means we have TLS without verification.
+			dir = filepath.Join(s.dir, "contexts", "tls", contextDir(name), dockerEndpoint)
+		}
+		tlsConfig, err := transport.LoadTLSConfig(dir, !c.Endpoint.SkipTLSVerify)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS config for context %q: %w", name, err)
+		}
+		opts = append([]transport.ConnectionOption{transport.WithTLSConfig(tlsConfig)}, opts...)
+	}
+
+	return transport.FromConnectionString(c.Endpoint.Host, opts...)
+}
+
+// FromEnv creates a transport for the current context of the default store.
+// See `Store.Current` for how the current context is determined.
+func FromEnv(opts ...transport.ConnectionOption) (*transport.Transport, error) {
+	s := NewStore("")
+	name, err := s.Current()
+	if err != nil {
+		return nil, err
+	}
+	return s.Transport(name, opts...)
+}
+
+// FromEnvContext is like FromEnv, but uses `Store.TransportContext`, so finding the daemon for the default context is
+// bounded by the context and an error is returned if none is found.
+func FromEnvContext(ctx context.Context, opts ...transport.ConnectionOption) (*transport.Transport, error) {
+	s := NewStore("")
+	name, err := s.Current()
+	if err != nil {
+		return nil, err
+	}
+	return s.TransportContext(ctx, name, opts...)
+}
@@ -0,0 +1,142 @@
+package dockercontext
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"github.com/cpuguy83/go-docker/transport"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func writeContext(t *testing.T, dir, name string, ep Endpoint) {
+	t.Helper()
+
+	var meta contextMeta
+	meta.Name = name
+	meta.Metadata.Description = "test context " + name
+	meta.Endpoints = map[string]Endpoint{dockerEndpoint: ep}
+
+	data, err := json.Marshal(meta)
+	assert.NilError(t, err)
+
+	metaDir := filepath.Join(dir, "contexts", "meta", contextDir(name))
+	assert.NilError(t, os.MkdirAll(metaDir, 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), data, 0o644))
+}
+
+func writeConfig(t *testing.T, dir, current string) {
+	t.Helper()
+	data, err := json.Marshal(configFile{CurrentContext: current})
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.json"), data, 0o644))
+}
+
+func TestCurrent(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	t.Setenv(EnvDockerContext, "")
+	t.Setenv(transport.EnvDockerHost, "")
+
+	name, err := s.Current()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, DefaultContextName))
+
+	writeConfig(t, dir, "fromconfig")
+	name, err = s.Current()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, "fromconfig"))
+
+	t.Setenv(transport.EnvDockerHost, "tcp://127.0.0.1:2375")
+	name, err = s.Current()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, DefaultContextName))
+
+	t.Setenv(EnvDockerContext, "fromenv")
+	name, err = s.Current()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(name, "fromenv"))
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	headers, err := s.HTTPHeaders()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(headers, 0))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"HttpHeaders": {"X-Meta": "foo"}}`), 0o644))
+	headers, err = s.HTTPHeaders()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(headers, map[string]string{"X-Meta": "foo"}))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{`), 0o644))
+	_, err = s.HTTPHeaders()
+	assert.Check(t, err != nil)
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	_, err := s.Inspect("notexist")
+	assert.Check(t, errdefs.IsNotFound(err), err)
+
+	writeContext(t, dir, "remote", Endpoint{Host: "ssh://someone@somehost"})
+	c, err := s.Inspect("remote")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(c.Name, "remote"))
+	assert.Check(t, cmp.Equal(c.Description, "test context remote"))
+	assert.Check(t, cmp.Equal(c.Endpoint.Host, "ssh://someone@somehost"))
+	assert.Check(t, cmp.Equal(c.TLSDir, ""))
+
+	t.Setenv(transport.EnvDockerHost, "tcp://127.0.0.1:2375")
+	c, err = s.Inspect(DefaultContextName)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(c.Endpoint.Host, "tcp://127.0.0.1:2375"))
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello " + req.URL.Path))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	writeContext(t, dir, "tls", Endpoint{Host: "tcp://" + u.Host})
+	writeConfig(t, dir, "tls")
+
+	tlsDir := filepath.Join(dir, "contexts", "tls", contextDir("tls"), dockerEndpoint)
+	assert.NilError(t, os.MkdirAll(tlsDir, 0o700))
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.NilError(t, os.WriteFile(filepath.Join(tlsDir, "ca.pem"), ca, 0o600))
+
+	t.Setenv(EnvDockerConfig, dir)
+	t.Setenv(EnvDockerContext, "")
+	t.Setenv(transport.EnvDockerHost, "")
+
+	tr, err := FromEnv()
+	assert.NilError(t, err)
+
+	resp, err := tr.Do(context.Background(), http.MethodGet, "/foo")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello /foo"))
+}
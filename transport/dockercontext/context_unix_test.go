@@ -0,0 +1,36 @@
+//go:build unix
+
+package dockercontext
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/transport"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestInspectDefaultDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	runtimeDir := filepath.Join(dir, "run")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv(transport.EnvDockerHost, "")
+
+	sock := filepath.Join(runtimeDir, "docker.sock")
+	assert.NilError(t, os.MkdirAll(runtimeDir, 0o755))
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	s := NewStore(dir)
+	c, err := s.Inspect(DefaultContextName)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(c.Endpoint.Host, "unix://"+sock))
+}
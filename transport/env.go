@@ -0,0 +1,149 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Environment variables used by FromEnv.
+// These have the same meaning as they do for the docker CLI.
+const (
+	EnvDockerHost      = "DOCKER_HOST"
+	EnvDockerTLSVerify = "DOCKER_TLS_VERIFY"
+	EnvDockerCertPath  = "DOCKER_CERT_PATH"
+)
+
+// FromEnv creates a Transport from the DOCKER_HOST, DOCKER_TLS_VERIFY, and DOCKER_CERT_PATH environment variables.
+//
+// If DOCKER_HOST is not set, the daemon is found with `DiscoverHost`. If none is found, the default host for the
+// platform is used, so the transport works once the daemon is started. Use FromEnvContext to get the error instead.
+//
+// TLS is enabled when either DOCKER_CERT_PATH or DOCKER_TLS_VERIFY is set.
+// The ca.pem, cert.pem, and key.pem files are loaded from DOCKER_CERT_PATH (defaulting to ~/.docker), if they exist.
+// The server certificate is only verified when DOCKER_TLS_VERIFY is set to a non-empty value.
+//
+// Any passed in options are applied after the environment has been processed, and so can override it.
+func FromEnv(opts ...ConnectionOption) (*Transport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	tr, err := fromEnv(ctx, opts...)
+	if tr == nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// FromEnvContext is like FromEnv, but finding the daemon when DOCKER_HOST is not set is bounded by the context.
+//
+// If no daemon is found, the error from DiscoverHost is returned, which is an errdefs.NotFound error unless the
+// context is done.
+func FromEnvContext(ctx context.Context, opts ...ConnectionOption) (*Transport, error) {
+	tr, err := fromEnv(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// fromEnv creates the transport for FromEnv and FromEnvContext.
+// If no daemon is found, the transport for the default host is returned along with the error from DiscoverHost.
+func fromEnv(ctx context.Context, opts ...ConnectionOption) (*Transport, error) {
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append([]ConnectionOption{WithTLSConfig(tlsConfig)}, opts...)
+	}
+
+	host := os.Getenv(EnvDockerHost)
+	if host == "" {
+		var discoverErr error
+		host, discoverErr = DiscoverHost(ctx, opts...)
+		if discoverErr != nil {
+			if ctx.Err() != nil {
+				return nil, discoverErr
+			}
+			tr, err := FromConnectionString(host, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return tr, discoverErr
+		}
+	}
+	return FromConnectionString(host, opts...)
+}
+
+// WithTLSConfig is a ConnectionOption which sets the TLS configuration to use for the connection.
+func WithTLSConfig(cfg *tls.Config) ConnectionOption {
+	return func(c *ConnectionConfig) error {
+		c.TLSConfig = cfg
+		return nil
+	}
+}
+
+func tlsConfigFromEnv() (*tls.Config, error) {
+	certPath := os.Getenv(EnvDockerCertPath)
+	verify := os.Getenv(EnvDockerTLSVerify) != ""
+
+	if certPath == "" && !verify {
+		return nil, nil
+	}
+
+	if certPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("error determining default cert path: %w", err)
+		}
+		certPath = filepath.Join(home, ".docker")
+	}
+
+	return LoadTLSConfig(certPath, verify)
+}
+
+// LoadTLSConfig creates a TLS configuration from the ca.pem, cert.pem, and key.pem files in the provided directory.
+// This is the same layout that the docker CLI uses for DOCKER_CERT_PATH.
+//
+// Files that do not exist are skipped, in which case the system roots are used to verify the server
+// and/or no client certificate is presented.
+func LoadTLSConfig(dir string, verify bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: !verify,
+	}
+
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading CA certificate: %w", err)
+	}
+	if err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", filepath.Join(dir, "ca.pem"))
+		}
+		cfg.RootCAs = pool
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		return cfg, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+
+	return cfg, nil
+}
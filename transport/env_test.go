@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		t.Setenv(EnvDockerHost, "")
+		t.Setenv(EnvDockerTLSVerify, "")
+		t.Setenv(EnvDockerCertPath, "")
+
+		tr, err := FromEnv()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(tr.scheme, "http"))
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		t.Setenv(EnvDockerHost, "foo://bar")
+		_, err := FromEnv()
+		assert.Check(t, cmp.ErrorContains(err, "protocol not supported"))
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("hello " + req.URL.Path))
+		}))
+		defer srv.Close()
+
+		u, err := url.Parse(srv.URL)
+		assert.NilError(t, err)
+
+		certDir := t.TempDir()
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		assert.NilError(t, os.WriteFile(filepath.Join(certDir, "ca.pem"), ca, 0o600))
+
+		t.Setenv(EnvDockerHost, "tcp://"+u.Host)
+		t.Setenv(EnvDockerTLSVerify, "1")
+		t.Setenv(EnvDockerCertPath, certDir)
+
+		tr, err := FromEnv()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(tr.scheme, "https"))
+
+		resp, err := tr.Do(context.Background(), http.MethodGet, "/foo")
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(data), "hello /foo"))
+	})
+
+	t.Run("tls no verify", func(t *testing.T) {
+		certDir := t.TempDir()
+		t.Setenv(EnvDockerHost, "tcp://127.0.0.1:2376")
+		t.Setenv(EnvDockerTLSVerify, "")
+		t.Setenv(EnvDockerCertPath, certDir)
+
+		tr, err := FromEnv()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(tr.scheme, "https"))
+	})
+
+	t.Run("bad cert path", func(t *testing.T) {
+		certDir := t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(certDir, "cert.pem"), []byte("not a cert"), 0o600))
+
+		t.Setenv(EnvDockerHost, "tcp://127.0.0.1:2376")
+		t.Setenv(EnvDockerTLSVerify, "1")
+		t.Setenv(EnvDockerCertPath, certDir)
+
+		_, err := FromEnv()
+		assert.Check(t, cmp.ErrorContains(err, "error loading client certificate"))
+	})
+}
@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var (
+	// regex to match any non-empty credential field in a JSON body
+	jsonCredentialsRegex = regexp.MustCompile(`"((?i)identitytoken|password|auth)":\ ?".*"`)
+
+	// headers which carry (base64 encoded) credentials
+	credentialHeaders = []string{"X-Registry-Auth", "X-Registry-Config", "Authorization"}
+)
+
+const redacted = "<REDACTED>"
+
+// RedactJSON replaces the value of any credential fields (passwords, identity tokens, auth) in the JSON data.
+func RedactJSON(data []byte) []byte {
+	return jsonCredentialsRegex.ReplaceAll(data, []byte(`"${1}": "`+redacted+`"`))
+}
+
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range credentialHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, redacted)
+		}
+	}
+	return h
+}
+
+// WithLogging creates a Middleware which logs each request using the provided logger.
+//
+// Completed requests are logged at debug level, failed ones at warn level.
+// Request headers and JSON request bodies are included at debug level, with any credentials redacted.
+// Response bodies are not logged since they are often streams.
+func WithLogging(logger *slog.Logger) Middleware {
+	return func(d Doer) Doer {
+		return &logDoer{d: d, logger: logger}
+	}
+}
+
+type logDoer struct {
+	d      Doer
+	logger *slog.Logger
+}
+
+func (l *logDoer) logRequest(ctx context.Context) RequestOpt {
+	return func(req *http.Request) error {
+		if !l.logger.Enabled(ctx, slog.LevelDebug) {
+			return nil
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", req.Method),
+			slog.Any("headers", redactHeaders(req.Header)),
+		}
+		if req.URL != nil {
+			attrs = append(attrs, slog.String("url", req.URL.String()))
+		}
+
+		if req.Body != nil && req.Header.Get("Content-Type") == "application/json" {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(data))
+			attrs = append(attrs, slog.String("body", string(RedactJSON(data))))
+		}
+
+		l.logger.LogAttrs(ctx, slog.LevelDebug, "sending request", attrs...)
+		return nil
+	}
+}
+
+func (l *logDoer) logResult(ctx context.Context, method, uri string, start time.Time, status int, err error) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("uri", uri),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if status != 0 {
+		attrs = append(attrs, slog.Int("status", status))
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.logger.LogAttrs(ctx, level, "request completed", attrs...)
+}
+
+func (l *logDoer) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	start := time.Now()
+	resp, err := l.d.Do(ctx, method, uri, append(opts[:len(opts):len(opts)], l.logRequest(ctx))...)
+
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	l.logResult(ctx, method, uri, start, status, err)
+	return resp, err
+}
+
+func (l *logDoer) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	start := time.Now()
+	conn, err := l.d.DoRaw(ctx, method, uri, append(opts[:len(opts):len(opts)], l.logRequest(ctx))...)
+
+	var status int
+	if err == nil {
+		status = http.StatusSwitchingProtocols
+	}
+	l.logResult(ctx, method, uri, start, status, err)
+	return conn, err
+}
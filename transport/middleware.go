@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// Middleware wraps a Doer to add behavior to it, such as logging or retries.
+// The returned Doer must pass both Do and DoRaw along to the wrapped Doer.
+type Middleware func(Doer) Doer
+
+// Chain wraps the passed in Doer with the provided middlewares.
+//
+// Middlewares are applied in the order they are passed in, so the first middleware is the outermost one
+// and sees the request first.
+func Chain(d Doer, mw ...Middleware) Doer {
+	for i := len(mw) - 1; i >= 0; i-- {
+		d = mw[i](d)
+	}
+	return d
+}
+
+// Hooks are callbacks which are run for every request made through a Doer.
+// See `WithHooks`.
+type Hooks struct {
+	// BeforeRequest is called with the request after all other request options have been applied, but before it is sent.
+	// Returning an error aborts the request.
+	BeforeRequest func(*http.Request) error
+	// AfterResponse is called with the result of Do.
+	AfterResponse func(ctx context.Context, method, uri string, resp *http.Response, err error)
+	// AfterDoRaw is called with the result of DoRaw.
+	AfterDoRaw func(ctx context.Context, method, uri string, conn net.Conn, err error)
+}
+
+// WithHooks creates a Middleware which calls the provided hooks for each request.
+// Any nil hooks are skipped.
+func WithHooks(h Hooks) Middleware {
+	return func(d Doer) Doer {
+		return &hooksDoer{d: d, h: h}
+	}
+}
+
+type hooksDoer struct {
+	d Doer
+	h Hooks
+}
+
+func (h *hooksDoer) opts(opts []RequestOpt) []RequestOpt {
+	if h.h.BeforeRequest == nil {
+		return opts
+	}
+	return append(opts[:len(opts):len(opts)], h.h.BeforeRequest)
+}
+
+func (h *hooksDoer) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	resp, err := h.d.Do(ctx, method, uri, h.opts(opts)...)
+	if h.h.AfterResponse != nil {
+		h.h.AfterResponse(ctx, method, uri, resp, err)
+	}
+	return resp, err
+}
+
+func (h *hooksDoer) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	conn, err := h.d.DoRaw(ctx, method, uri, h.opts(opts)...)
+	if h.h.AfterDoRaw != nil {
+		h.h.AfterDoRaw(ctx, method, uri, conn, err)
+	}
+	return conn, err
+}
@@ -0,0 +1,233 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// funcDoer is a Doer which applies the request options to a request and passes it to the provided functions.
+type funcDoer struct {
+	do    func(*http.Request) (*http.Response, error)
+	doRaw func(*http.Request) (net.Conn, error)
+}
+
+func newTestRequest(ctx context.Context, method, uri string, opts []RequestOpt) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://test"+uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range opts {
+		if err := o(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (f *funcDoer) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	req, err := newTestRequest(ctx, method, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.do(req)
+}
+
+func (f *funcDoer) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	req, err := newTestRequest(ctx, method, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.doRaw(req)
+}
+
+func okResponse(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return WithHooks(Hooks{
+			BeforeRequest: func(req *http.Request) error {
+				order = append(order, name)
+				return nil
+			},
+		})
+	}
+
+	var rawReq *http.Request
+	d := Chain(&funcDoer{
+		do: okResponse,
+		doRaw: func(req *http.Request) (net.Conn, error) {
+			rawReq = req
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		},
+	}, mw("first"), mw("second"))
+
+	resp, err := d.Do(context.Background(), http.MethodGet, "/foo")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, cmp.DeepEqual(order, []string{"first", "second"}))
+
+	order = nil
+	conn, err := d.DoRaw(context.Background(), http.MethodPost, "/bar", WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	conn.Close()
+	assert.Check(t, cmp.Len(order, 2))
+	assert.Check(t, cmp.Equal(rawReq.Header.Get("Upgrade"), "tcp"))
+}
+
+func TestHooks(t *testing.T) {
+	var (
+		gotStatus int
+		rawErr    error
+	)
+	d := Chain(&funcDoer{
+		do: okResponse,
+		doRaw: func(*http.Request) (net.Conn, error) {
+			return nil, errdefs.NotImplemented("no raw")
+		},
+	}, WithHooks(Hooks{
+		BeforeRequest: func(req *http.Request) error {
+			if req.URL.Path == "/forbidden" {
+				return errdefs.Forbidden("nope")
+			}
+			return nil
+		},
+		AfterResponse: func(_ context.Context, _, _ string, resp *http.Response, _ error) {
+			gotStatus = 0
+			if resp != nil {
+				gotStatus = resp.StatusCode
+			}
+		},
+		AfterDoRaw: func(_ context.Context, _, _ string, _ net.Conn, err error) {
+			rawErr = err
+		},
+	}))
+
+	resp, err := d.Do(context.Background(), http.MethodGet, "/foo")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, cmp.Equal(gotStatus, http.StatusOK))
+
+	_, err = d.Do(context.Background(), http.MethodGet, "/forbidden")
+	assert.Check(t, errdefs.IsForbidden(err), err)
+	assert.Check(t, cmp.Equal(gotStatus, 0))
+
+	_, err = d.DoRaw(context.Background(), http.MethodPost, "/foo")
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, errdefs.IsNotImplemented(rawErr), rawErr)
+}
+
+func TestRetry(t *testing.T) {
+	fastRetry := WithRetry(func(cfg *RetryConfig) {
+		cfg.InitialBackoff = time.Millisecond
+		cfg.MaxBackoff = time.Millisecond
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		var calls int
+		d := Chain(&funcDoer{do: func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return nil, &net.OpError{Op: "dial", Net: "unix", Err: io.EOF}
+			}
+			return okResponse(req)
+		}}, fastRetry)
+
+		resp, err := d.Do(context.Background(), http.MethodGet, "/foo")
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Check(t, cmp.Equal(calls, 3))
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		var calls int
+		d := Chain(&funcDoer{do: func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				return nil, errdefs.Unavailable("try again")
+			}
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}}, fastRetry)
+
+		resp, err := d.Do(context.Background(), http.MethodGet, "/foo")
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Check(t, cmp.Equal(resp.StatusCode, http.StatusServiceUnavailable))
+		assert.Check(t, cmp.Equal(calls, 3))
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		var calls int
+		d := Chain(&funcDoer{do: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, &net.OpError{Op: "dial", Net: "unix", Err: io.EOF}
+		}}, fastRetry)
+
+		_, err := d.Do(context.Background(), http.MethodPost, "/foo")
+		assert.Check(t, err != nil)
+		assert.Check(t, cmp.Equal(calls, 1))
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		var calls int
+		d := Chain(&funcDoer{do: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errdefs.NotFound("foo")
+		}}, fastRetry)
+
+		_, err := d.Do(context.Background(), http.MethodGet, "/foo")
+		assert.Check(t, errdefs.IsNotFound(err), err)
+		assert.Check(t, cmp.Equal(calls, 1))
+	})
+}
+
+func TestLogging(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var body []byte
+	d := Chain(&funcDoer{do: func(req *http.Request) (*http.Response, error) {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return okResponse(req)
+	}}, WithLogging(logger))
+
+	withBody := func(req *http.Request) error {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Registry-Auth", "c2VjcmV0")
+		req.Body = io.NopCloser(strings.NewReader(`{"username": "me", "password": "secret"}`))
+		return nil
+	}
+
+	resp, err := d.Do(context.Background(), http.MethodPost, "/auth", withBody)
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	// The request body must still be passed along in full.
+	assert.Check(t, cmp.Equal(string(body), `{"username": "me", "password": "secret"}`))
+
+	out := buf.String()
+	assert.Check(t, cmp.Contains(out, "/auth"))
+	assert.Check(t, cmp.Contains(out, "status=200"))
+	assert.Check(t, cmp.Contains(out, "REDACTED"))
+	assert.Check(t, !strings.Contains(out, "secret"), out)
+	assert.Check(t, !strings.Contains(out, "c2VjcmV0"), out)
+}
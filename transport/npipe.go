@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 func DefaultWindowsTransport() (*Transport, error) {
@@ -23,7 +24,11 @@ func NpipeTransport(path string, opts ...ConnectionOption) (*Transport, error) {
 	t := &http.Transport{
 		DisableCompression: true,
 		DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-			return winDailer(path, nil)
+			var timeout *time.Duration
+			if cfg.DialTimeout > 0 {
+				timeout = &cfg.DialTimeout
+			}
+			return winDailer(path, timeout)
 		},
 	}
 
@@ -31,12 +36,14 @@ func NpipeTransport(path string, opts ...ConnectionOption) (*Transport, error) {
 		return t.DialContext(ctx, "", "")
 	}
 
-	return &Transport{
+	tr := &Transport{
 		host:   url.PathEscape(path),
 		scheme: "http",
 		c: &http.Client{
 			Transport: t,
 		},
 		dial: dail,
-	}, nil
+	}
+	cfg.configure(tr)
+	return tr, nil
 }
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// defaultRawKeepAlive is the keep-alive period used for hijacked connections when ConnectionConfig.KeepAlive is not set.
+const defaultRawKeepAlive = 30 * time.Second
+
+// WithDialTimeout is a ConnectionOption which limits how long to wait for a connection to the daemon to be established.
+func WithDialTimeout(d time.Duration) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.DialTimeout = d
+		return nil
+	}
+}
+
+// WithTLSHandshakeTimeout is a ConnectionOption which limits how long to wait for a TLS handshake to complete.
+func WithTLSHandshakeTimeout(d time.Duration) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.TLSHandshakeTimeout = d
+		return nil
+	}
+}
+
+// WithKeepAlive is a ConnectionOption which sets the interval between TCP keep-alive probes.
+// A negative value disables keep-alives.
+func WithKeepAlive(d time.Duration) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.KeepAlive = d
+		return nil
+	}
+}
+
+// WithIdleConnections is a ConnectionOption which sets how many idle connections are kept open for reuse, and how
+// long they are kept open for.
+// A zero timeout keeps idle connections open until the transport is closed.
+func WithIdleConnections(max int, timeout time.Duration) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.MaxIdleConns = max
+		cfg.IdleConnTimeout = timeout
+		return nil
+	}
+}
+
+// WithResponseHeaderTimeout is a ConnectionOption which limits how long to wait for the daemon to send response
+// headers after a request has been written.
+// See ConnectionConfig.ResponseHeaderTimeout for the endpoints this does not apply to.
+func WithResponseHeaderTimeout(d time.Duration) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.ResponseHeaderTimeout = d
+		return nil
+	}
+}
+
+func (cfg *ConnectionConfig) dialer() *net.Dialer {
+	return &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive}
+}
+
+// configureHTTP applies the connection pool and timeout settings to an http.Transport.
+func (cfg *ConnectionConfig) configureHTTP(tr *http.Transport) {
+	tr.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	tr.IdleConnTimeout = cfg.IdleConnTimeout
+	if cfg.MaxIdleConns != 0 {
+		// All requests go to the same host, so the per-host limit is the one that matters.
+		tr.MaxIdleConns = cfg.MaxIdleConns
+		tr.MaxIdleConnsPerHost = cfg.MaxIdleConns
+	}
+}
+
+// configure applies the settings which are handled by Transport rather than by the underlying http.Transport.
+func (cfg *ConnectionConfig) configure(t *Transport) {
+	t.responseHeaderTimeout = cfg.ResponseHeaderTimeout
+	t.keepAlive = cfg.KeepAlive
+	if tr, ok := t.c.Transport.(*http.Transport); ok {
+		cfg.configureHTTP(tr)
+	}
+}
+
+// longRunningPaths matches the endpoints which may take an arbitrary amount of time before sending response headers,
+// either because they stream or because they wait for something to happen in the daemon.
+var longRunningPaths = regexp.MustCompile(`^/(` +
+	`events|build|session|grpc|commit|images/create|images/load|images/get|` +
+	`containers/[^/]+/(attach|wait|logs|stats|stop|restart|export)|` +
+	`exec/[^/]+/start|` +
+	`images/.+/(push|get)|` +
+	`(containers|images|volumes|networks|build)/prune` +
+	`)$`)
+
+func isLongRunning(uri string) bool {
+	return longRunningPaths.MatchString(apiVersionPrefix.ReplaceAllString(uri, "/"))
+}
+
+// withResponseHeaderTimeout returns a context which is cancelled if the response headers are not received in time.
+// The returned function must be called once the response headers are received, it reports whether the timeout was
+// hit.
+// cancel must be called once the response body is no longer in use.
+func withResponseHeaderTimeout(ctx context.Context, d time.Duration) (_ context.Context, timedOut func() bool, cancel context.CancelFunc) {
+	ctx, cancel = context.WithCancel(ctx)
+	timer := time.AfterFunc(d, cancel)
+	return ctx, func() bool { return !timer.Stop() }, cancel
+}
+
+func responseHeaderTimeoutError(method, uri string, d time.Duration) error {
+	return errdefs.Unavailablef("timeout waiting for response headers for %s %s after %s", method, uri, d)
+}
+
+// cancelBody cancels the request context when the response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// PeerCredentials are the credentials of the process listening on the other end of a unix socket.
+type PeerCredentials struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+// PeerAllowlist holds the credentials a daemon process is allowed to have.
+// An empty list allows any value.
+type PeerAllowlist struct {
+	UIDs []uint32
+	GIDs []uint32
+	PIDs []int32
+}
+
+// Check returns an error if the credentials are not allowed.
+func (a PeerAllowlist) Check(cred PeerCredentials) error {
+	if len(a.UIDs) > 0 && !slices.Contains(a.UIDs, cred.UID) {
+		return fmt.Errorf("uid %d is not allowed", cred.UID)
+	}
+	if len(a.GIDs) > 0 && !slices.Contains(a.GIDs, cred.GID) {
+		return fmt.Errorf("gid %d is not allowed", cred.GID)
+	}
+	if len(a.PIDs) > 0 && !slices.Contains(a.PIDs, cred.PID) {
+		return fmt.Errorf("pid %d is not allowed", cred.PID)
+	}
+	return nil
+}
+
+// WithPeerCredentialsCheck is a ConnectionOption which checks the credentials of the process listening on a unix
+// socket for every new connection, before anything is sent over it.
+// If check returns an error, the connection is closed and the request fails with an errdefs.Forbidden error.
+//
+// This applies to both regular requests and hijacked connections.
+// It is only supported for unix sockets on Linux, where it uses SO_PEERCRED. On other platforms all connections fail
+// with an errdefs.NotImplemented error.
+func WithPeerCredentialsCheck(check func(PeerCredentials) error) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.PeerCredentialsCheck = check
+		return nil
+	}
+}
+
+// WithAllowedPeers is a ConnectionOption which only allows connecting to a daemon whose process credentials are in
+// the allowlist, e.g. `PeerAllowlist{UIDs: []uint32{0}}` to make sure the daemon runs as root.
+// See `WithPeerCredentialsCheck`.
+func WithAllowedPeers(allow PeerAllowlist) ConnectionOption {
+	return WithPeerCredentialsCheck(allow.Check)
+}
+
+// verifyPeer wraps a dial function to check the credentials of the peer of each connection.
+func verifyPeer(dial func(context.Context) (net.Conn, error), check func(PeerCredentials) error) func(context.Context) (net.Conn, error) {
+	return func(ctx context.Context) (net.Conn, error) {
+		conn, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		cred, err := peerCredentials(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := check(cred); err != nil {
+			conn.Close()
+			return nil, errdefs.AsForbidden(fmt.Errorf("daemon peer credentials rejected (uid=%d gid=%d pid=%d): %w", cred.UID, cred.GID, cred.PID, err))
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"golang.org/x/sys/unix"
+)
+
+func peerCredentials(conn net.Conn) (PeerCredentials, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return PeerCredentials{}, errdefs.NotImplementedf("peer credentials are only available for unix sockets, got %T", conn)
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	var (
+		cred   *unix.Ucred
+		errOpt error
+	)
+	err = raw.Control(func(fd uintptr) {
+		cred, errOpt = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = errOpt
+	}
+	if err != nil {
+		return PeerCredentials{}, fmt.Errorf("error getting peer credentials: %w", err)
+	}
+	return PeerCredentials{UID: cred.Uid, GID: cred.Gid, PID: cred.Pid}, nil
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestUnixTransportPeerCredentials(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	defer l.Close()
+
+	go http.Serve(l, http.HandlerFunc(upgradeHandler))
+
+	ctx := context.Background()
+	self := PeerCredentials{UID: uint32(os.Getuid()), GID: uint32(os.Getgid()), PID: int32(os.Getpid())}
+
+	t.Run("allowed", func(t *testing.T) {
+		var got PeerCredentials
+		tr, err := UnixSocketTransport(sock, WithPeerCredentialsCheck(func(cred PeerCredentials) error {
+			got = cred
+			return PeerAllowlist{UIDs: []uint32{self.UID}}.Check(cred)
+		}))
+		assert.NilError(t, err)
+		defer tr.Close()
+
+		resp, err := tr.Do(ctx, http.MethodGet, "/foo")
+		assert.NilError(t, err)
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(data), "hello /foo"))
+		assert.Check(t, cmp.Equal(got, self))
+
+		conn, err := tr.DoRaw(ctx, http.MethodPost, "/containers/foo/attach", WithUpgrade("tcp"))
+		assert.NilError(t, err)
+		conn.Close()
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		tr, err := UnixSocketTransport(sock, WithAllowedPeers(PeerAllowlist{UIDs: []uint32{self.UID + 1}}))
+		assert.NilError(t, err)
+		defer tr.Close()
+
+		_, err = tr.Do(ctx, http.MethodGet, "/foo")
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		assert.Check(t, cmp.ErrorContains(err, "is not allowed"))
+
+		_, err = tr.DoRaw(ctx, http.MethodPost, "/containers/foo/attach", WithUpgrade("tcp"))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+	})
+
+	t.Run("predicate error", func(t *testing.T) {
+		errBadPid := errors.New("unexpected pid")
+		tr, err := UnixSocketTransport(sock, WithPeerCredentialsCheck(func(cred PeerCredentials) error {
+			if cred.PID == self.PID {
+				return errBadPid
+			}
+			return nil
+		}))
+		assert.NilError(t, err)
+		defer tr.Close()
+
+		_, err = tr.Do(ctx, http.MethodGet, "/foo")
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		assert.Check(t, errors.Is(err, errBadPid), err)
+	})
+}
+
+func TestPeerAllowlist(t *testing.T) {
+	cred := PeerCredentials{UID: 0, GID: 10, PID: 100}
+
+	assert.Check(t, PeerAllowlist{}.Check(cred))
+	assert.Check(t, PeerAllowlist{UIDs: []uint32{0}, GIDs: []uint32{5, 10}}.Check(cred))
+	assert.Check(t, cmp.ErrorContains(PeerAllowlist{UIDs: []uint32{1000}}.Check(cred), "uid 0"))
+	assert.Check(t, cmp.ErrorContains(PeerAllowlist{GIDs: []uint32{0}}.Check(cred), "gid 10"))
+	assert.Check(t, cmp.ErrorContains(PeerAllowlist{PIDs: []int32{1}}.Check(cred), "pid 100"))
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package transport
+
+import (
+	"net"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+func peerCredentials(net.Conn) (PeerCredentials, error) {
+	return PeerCredentials{}, errdefs.NotImplemented("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,308 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// EndpointClass classifies Engine API requests by what they are able to do.
+type EndpointClass int
+
+const (
+	// ClassRead is for requests which only read state, i.e. GET and HEAD requests.
+	ClassRead EndpointClass = iota
+	// ClassMutate is for requests which change state in the daemon.
+	ClassMutate
+	// ClassExec is for requests which run processes in containers or give access to their stdio, such as exec and attach.
+	ClassExec
+	// ClassPrivilegedCreate is for container create requests which ask for access to the host, such as privileged
+	// containers, bind mounts of host paths, or sharing the host PID namespace.
+	ClassPrivilegedCreate
+)
+
+func (c EndpointClass) String() string {
+	switch c {
+	case ClassRead:
+		return "read"
+	case ClassMutate:
+		return "mutate"
+	case ClassExec:
+		return "exec"
+	case ClassPrivilegedCreate:
+		return "privileged-create"
+	default:
+		return "unknown"
+	}
+}
+
+// execPaths matches the endpoints which are classified as ClassExec.
+var execPaths = regexp.MustCompile(`^/(containers/[^/]+/(exec|attach|attach/ws|resize)|exec/[^/]+/(start|resize))$`)
+
+// Classify returns the class of a request.
+// body is the request body, which is only used for container create requests.
+// For ClassPrivilegedCreate, the returned string describes what makes the request privileged.
+func Classify(method, uri string, body []byte) (EndpointClass, string) {
+	p := apiVersionPrefix.ReplaceAllString(uri, "/")
+
+	if execPaths.MatchString(p) {
+		return ClassExec, ""
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return ClassRead, ""
+	}
+	if method == http.MethodPost && p == "/containers/create" {
+		if reason := privilegedReason(body); reason != "" {
+			return ClassPrivilegedCreate, reason
+		}
+	}
+	return ClassMutate, ""
+}
+
+// createHostConfig is the subset of a container create request which is inspected for privileged access.
+type createHostConfig struct {
+	HostConfig struct {
+		Privileged        bool
+		Binds             []string
+		PidMode           string
+		IpcMode           string
+		NetworkMode       string
+		UTSMode           string
+		UsernsMode        string
+		CgroupnsMode      string
+		CapAdd            []string
+		SecurityOpt       []string
+		Devices           []json.RawMessage
+		DeviceRequests    []json.RawMessage
+		DeviceCgroupRules []string
+		VolumesFrom       []string
+		Mounts            []struct {
+			Type          string
+			Source        string
+			VolumeOptions *struct {
+				DriverConfig *struct {
+					Options map[string]string
+				}
+			}
+		}
+	}
+}
+
+// unconfinedSecurityOpts are the security options which disable a protection of the container.
+// Both the "key=value" and the legacy "key:value" forms are accepted by the daemon.
+var unconfinedSecurityOpts = map[string]string{
+	"seccomp":     "unconfined",
+	"apparmor":    "unconfined",
+	"label":       "disable",
+	"systempaths": "unconfined",
+}
+
+// privilegedReason returns why a container create request body is privileged, or an empty string if it is not.
+// Bodies which cannot be parsed are treated as privileged, since the daemon may still accept them.
+func privilegedReason(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	var cfg createHostConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return "unable to parse request body"
+	}
+	hc := cfg.HostConfig
+
+	var reasons []string
+	if hc.Privileged {
+		reasons = append(reasons, "privileged")
+	}
+	for _, b := range hc.Binds {
+		if src, _, _ := strings.Cut(b, ":"); strings.HasPrefix(src, "/") {
+			reasons = append(reasons, "host bind mount "+src)
+		}
+	}
+	for _, m := range hc.Mounts {
+		if m.Type == "bind" {
+			reasons = append(reasons, "host bind mount "+m.Source)
+			continue
+		}
+		// The local volume driver can bind mount a host path with the "o=bind" and "device=<path>" options.
+		if m.VolumeOptions == nil || m.VolumeOptions.DriverConfig == nil {
+			continue
+		}
+		opts := m.VolumeOptions.DriverConfig.Options
+		if device := opts["device"]; strings.HasPrefix(device, "/") || slices.ContainsFunc(strings.Split(opts["o"], ","), func(o string) bool {
+			return o == "bind" || o == "rbind"
+		}) {
+			reason := "host bind mount with volume options"
+			if device != "" {
+				reason += " device=" + device
+			}
+			reasons = append(reasons, reason)
+		}
+	}
+	for name, mode := range map[string]string{"PidMode": hc.PidMode, "IpcMode": hc.IpcMode, "NetworkMode": hc.NetworkMode, "UTSMode": hc.UTSMode, "UsernsMode": hc.UsernsMode, "CgroupnsMode": hc.CgroupnsMode} {
+		if mode == "host" {
+			reasons = append(reasons, name+"=host")
+		}
+	}
+	if len(hc.CapAdd) > 0 {
+		reasons = append(reasons, "added capabilities "+strings.Join(hc.CapAdd, ","))
+	}
+	for _, opt := range hc.SecurityOpt {
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok {
+			k, v, _ = strings.Cut(opt, ":")
+		}
+		if want, found := unconfinedSecurityOpts[k]; found && v == want {
+			reasons = append(reasons, "security option "+opt)
+		}
+	}
+	if len(hc.Devices) > 0 {
+		reasons = append(reasons, "host devices")
+	}
+	if len(hc.DeviceRequests) > 0 {
+		reasons = append(reasons, "device requests")
+	}
+	if len(hc.DeviceCgroupRules) > 0 {
+		reasons = append(reasons, "device cgroup rules "+strings.Join(hc.DeviceCgroupRules, ","))
+	}
+	if len(hc.VolumesFrom) > 0 {
+		// The mounts of the other containers, which may be host bind mounts, are not known here.
+		reasons = append(reasons, "volumes from "+strings.Join(hc.VolumesFrom, ","))
+	}
+
+	// Map iteration order is random, keep the reason stable.
+	slices.Sort(reasons)
+	return strings.Join(reasons, ", ")
+}
+
+// PolicyRule allows or denies requests which match a method and path, regardless of their class.
+type PolicyRule struct {
+	// Method is the HTTP method to match, or empty to match any method.
+	Method string
+	// Path is a pattern, as used by path.Match, which is matched against the request path without the API version
+	// prefix, e.g. "/containers/*/json".
+	Path string
+	// Allow is true to allow matching requests, false to deny them.
+	Allow bool
+}
+
+func (r PolicyRule) match(method, p string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	ok, _ := path.Match(r.Path, p)
+	return ok
+}
+
+// Policy configures which requests are allowed by the Doer created by `WithPolicy`.
+type Policy struct {
+	// Allowed is the list of endpoint classes that may be used.
+	Allowed []EndpointClass
+	// Rules are checked in order before the class of the request, and the first matching rule decides whether the
+	// request is allowed.
+	Rules []PolicyRule
+	// DryRun logs requests that would be denied instead of denying them.
+	DryRun bool
+	// Logger is used to log denied requests.
+	// Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// ReadOnlyPolicy returns a policy which only allows requests that read state.
+func ReadOnlyPolicy() Policy {
+	return Policy{Allowed: []EndpointClass{ClassRead}}
+}
+
+// WithPolicy creates a Middleware which enforces the policy on all requests.
+// Denied requests are not sent and fail with an errdefs.Forbidden error.
+func WithPolicy(p Policy) Middleware {
+	if p.Logger == nil {
+		p.Logger = slog.Default()
+	}
+	return func(d Doer) Doer {
+		return &policyDoer{d: d, p: p}
+	}
+}
+
+type policyDoer struct {
+	d Doer
+	p Policy
+}
+
+// check returns a RequestOpt which checks the fully built request against the policy.
+// The path is taken from the request rather than the uri passed to Do, since other request options may change it.
+func (pd *policyDoer) check(ctx context.Context) RequestOpt {
+	return func(req *http.Request) error {
+		p := path.Clean("/" + req.URL.Path)
+
+		// Only container create bodies are inspected, other bodies may be large streams such as build contexts.
+		var body []byte
+		if req.Body != nil && req.Method == http.MethodPost && apiVersionPrefix.ReplaceAllString(p, "/") == "/containers/create" {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(data))
+			body = data
+		}
+
+		reason := pd.p.deny(req.Method, p, body)
+		if reason == "" {
+			return nil
+		}
+
+		if pd.p.DryRun {
+			pd.p.Logger.LogAttrs(ctx, slog.LevelWarn, "request would be denied by policy",
+				slog.String("method", req.Method),
+				slog.String("path", p),
+				slog.String("reason", reason),
+			)
+			return nil
+		}
+		return errdefs.Forbiddenf("%s %s denied by policy: %s", req.Method, p, reason)
+	}
+}
+
+// deny returns why a request is denied, or an empty string if it is allowed.
+// reqPath is the path of the request.
+func (p Policy) deny(method, reqPath string, body []byte) string {
+	stripped := apiVersionPrefix.ReplaceAllString(reqPath, "/")
+	for _, r := range p.Rules {
+		if r.match(method, stripped) {
+			if r.Allow {
+				return ""
+			}
+			return "denied by rule " + strings.TrimSpace(r.Method+" "+r.Path)
+		}
+	}
+
+	class, detail := Classify(method, reqPath, body)
+	for _, c := range p.Allowed {
+		if c == class {
+			return ""
+		}
+	}
+	if detail != "" {
+		return class.String() + " requests are not allowed (" + detail + ")"
+	}
+	return class.String() + " requests are not allowed"
+}
+
+func (pd *policyDoer) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	return pd.d.Do(ctx, method, uri, append(opts[:len(opts):len(opts)], pd.check(ctx))...)
+}
+
+func (pd *policyDoer) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	return pd.d.DoRaw(ctx, method, uri, append(opts[:len(opts):len(opts)], pd.check(ctx))...)
+}
@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		method string
+		uri    string
+		body   string
+		class  EndpointClass
+		reason string
+	}{
+		{method: http.MethodGet, uri: "/v1.41/containers/json", class: ClassRead},
+		{method: http.MethodHead, uri: "/_ping", class: ClassRead},
+		{method: http.MethodGet, uri: "/v1.41/containers/foo/logs", class: ClassRead},
+		{method: http.MethodPost, uri: "/v1.41/containers/foo/stop", class: ClassMutate},
+		{method: http.MethodDelete, uri: "/images/busybox", class: ClassMutate},
+		{method: http.MethodPost, uri: "/v1.41/containers/foo/exec", class: ClassExec},
+		{method: http.MethodPost, uri: "/exec/foo/start", class: ClassExec},
+		{method: http.MethodPost, uri: "/containers/foo/attach", class: ClassExec},
+		{method: http.MethodGet, uri: "/containers/foo/attach/ws", class: ClassExec},
+		{method: http.MethodPost, uri: "/containers/create", body: `{"Image": "busybox", "HostConfig": {"Binds": ["vol:/data"]}}`, class: ClassMutate},
+		{method: http.MethodPost, uri: "/containers/create", class: ClassMutate},
+		{method: http.MethodPost, uri: "/containers/create", body: `{"HostConfig": {"Privileged": true}}`, class: ClassPrivilegedCreate, reason: "privileged"},
+		{method: http.MethodPost, uri: "/v1.41/containers/create", body: `{"HostConfig": {"Binds": ["/etc:/etc:ro"]}}`, class: ClassPrivilegedCreate, reason: "host bind mount /etc"},
+		{method: http.MethodPost, uri: "/containers/create", body: `{"HostConfig": {"Mounts": [{"Type": "bind", "Source": "/var/run/docker.sock"}]}}`, class: ClassPrivilegedCreate, reason: "host bind mount /var/run/docker.sock"},
+		{method: http.MethodPost, uri: "/containers/create", body: `{"HostConfig": {"PidMode": "host", "NetworkMode": "host"}}`, class: ClassPrivilegedCreate, reason: "NetworkMode=host, PidMode=host"},
+		{method: http.MethodPost, uri: "/containers/create", body: `not json`, class: ClassPrivilegedCreate, reason: "unable to parse request body"},
+	}
+
+	for _, tc := range cases {
+		class, reason := Classify(tc.method, tc.uri, []byte(tc.body))
+		assert.Check(t, cmp.Equal(class, tc.class), "%s %s", tc.method, tc.uri)
+		assert.Check(t, cmp.Equal(reason, tc.reason), "%s %s", tc.method, tc.uri)
+	}
+}
+
+func TestPrivilegedReason(t *testing.T) {
+	cases := []struct {
+		hostConfig string
+		reason     string
+	}{
+		{hostConfig: `{"SecurityOpt": ["no-new-privileges"]}`},
+		{hostConfig: `{"SecurityOpt": ["seccomp=unconfined"]}`, reason: "security option seccomp=unconfined"},
+		{hostConfig: `{"SecurityOpt": ["seccomp:unconfined"]}`, reason: "security option seccomp:unconfined"},
+		{hostConfig: `{"SecurityOpt": ["seccomp=/profile.json"]}`},
+		{hostConfig: `{"SecurityOpt": ["apparmor=unconfined"]}`, reason: "security option apparmor=unconfined"},
+		{hostConfig: `{"SecurityOpt": ["label=disable"]}`, reason: "security option label=disable"},
+		{hostConfig: `{"SecurityOpt": ["label=type:svirt_apache_t"]}`},
+		{hostConfig: `{"SecurityOpt": ["systempaths=unconfined"]}`, reason: "security option systempaths=unconfined"},
+		{hostConfig: `{"CgroupnsMode": "private"}`},
+		{hostConfig: `{"CgroupnsMode": "host"}`, reason: "CgroupnsMode=host"},
+		{hostConfig: `{"DeviceRequests": [{"Driver": "nvidia", "Count": -1}]}`, reason: "device requests"},
+		{hostConfig: `{"DeviceCgroupRules": ["c 1:3 mr"]}`, reason: "device cgroup rules c 1:3 mr"},
+		{hostConfig: `{"VolumesFrom": ["other:ro"]}`, reason: "volumes from other:ro"},
+		{hostConfig: `{"Mounts": [{"Type": "volume", "Source": "data", "Target": "/data"}]}`},
+		{hostConfig: `{"Mounts": [{"Type": "volume", "Source": "data", "VolumeOptions": {"DriverConfig": {"Name": "local", "Options": {"type": "tmpfs", "device": "tmpfs"}}}}]}`},
+		{hostConfig: `{"Mounts": [{"Type": "volume", "Source": "data", "VolumeOptions": {"DriverConfig": {"Name": "local", "Options": {"type": "none", "o": "bind", "device": "/etc"}}}}]}`, reason: "host bind mount with volume options device=/etc"},
+		{hostConfig: `{"Mounts": [{"Type": "volume", "Source": "data", "VolumeOptions": {"DriverConfig": {"Options": {"o": "ro,rbind"}}}}]}`, reason: "host bind mount with volume options"},
+		{hostConfig: `{"Mounts": [{"Type": "volume", "Source": "data", "VolumeOptions": {"DriverConfig": {"Options": {"device": "/dev/sda1"}}}}]}`, reason: "host bind mount with volume options device=/dev/sda1"},
+	}
+
+	for _, tc := range cases {
+		class, reason := Classify(http.MethodPost, "/containers/create", []byte(`{"HostConfig": `+tc.hostConfig+`}`))
+		if tc.reason == "" {
+			assert.Check(t, cmp.Equal(class, ClassMutate), tc.hostConfig)
+		} else {
+			assert.Check(t, cmp.Equal(class, ClassPrivilegedCreate), tc.hostConfig)
+		}
+		assert.Check(t, cmp.Equal(reason, tc.reason), tc.hostConfig)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	var (
+		sent    []string
+		gotBody string
+	)
+	backend := &funcDoer{
+		do: func(req *http.Request) (*http.Response, error) {
+			sent = append(sent, req.Method+" "+req.URL.Path)
+			if req.Body != nil {
+				data, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				gotBody = string(data)
+			}
+			return okResponse(req)
+		},
+		doRaw: func(req *http.Request) (net.Conn, error) {
+			sent = append(sent, req.Method+" "+req.URL.Path)
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		},
+	}
+
+	withBody := func(body string) RequestOpt {
+		return func(req *http.Request) error {
+			req.Header.Set("Content-Type", "application/json")
+			req.Body = io.NopCloser(strings.NewReader(body))
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+
+	t.Run("read only", func(t *testing.T) {
+		sent = nil
+		d := Chain(backend, WithPolicy(ReadOnlyPolicy()))
+
+		resp, err := d.Do(ctx, http.MethodGet, "/v1.41/containers/json")
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		_, err = d.Do(ctx, http.MethodPost, "/v1.41/containers/foo/stop")
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		assert.Check(t, cmp.ErrorContains(err, "mutate requests are not allowed"))
+
+		_, err = d.DoRaw(ctx, http.MethodPost, "/v1.41/containers/foo/attach", WithUpgrade("tcp"))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+
+		assert.Check(t, cmp.DeepEqual(sent, []string{"GET /v1.41/containers/json"}))
+	})
+
+	t.Run("no privileged containers", func(t *testing.T) {
+		sent = nil
+		d := Chain(backend, WithPolicy(Policy{
+			Allowed: []EndpointClass{ClassRead, ClassMutate, ClassExec},
+			Rules: []PolicyRule{
+				{Method: http.MethodDelete, Path: "/images/*", Allow: false},
+			},
+		}))
+
+		body := `{"Image": "busybox", "HostConfig": {"Binds": ["data:/data"]}}`
+		resp, err := d.Do(ctx, http.MethodPost, "/v1.41/containers/create", withBody(body))
+		assert.NilError(t, err)
+		resp.Body.Close()
+		// The body must still be sent in full after being inspected.
+		assert.Check(t, cmp.Equal(gotBody, body))
+
+		_, err = d.Do(ctx, http.MethodPost, "/v1.41/containers/create", withBody(`{"Image": "busybox", "HostConfig": {"Binds": ["/:/host"]}}`))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		assert.Check(t, cmp.ErrorContains(err, "host bind mount /"))
+
+		_, err = d.Do(ctx, http.MethodDelete, "/v1.41/images/busybox")
+		assert.Check(t, errdefs.IsForbidden(err), err)
+
+		conn, err := d.DoRaw(ctx, http.MethodPost, "/v1.41/exec/foo/start", WithUpgrade("tcp"))
+		assert.NilError(t, err)
+		conn.Close()
+
+		assert.Check(t, cmp.DeepEqual(sent, []string{"POST /v1.41/containers/create", "POST /v1.41/exec/foo/start"}))
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		sent = nil
+		policy := ReadOnlyPolicy()
+		policy.Rules = []PolicyRule{
+			{Method: http.MethodGet, Path: "/containers/*/export", Allow: false},
+			{Method: http.MethodPost, Path: "/containers/*/kill", Allow: true},
+		}
+		d := Chain(backend, WithPolicy(policy))
+
+		_, err := d.Do(ctx, http.MethodGet, "/v1.41/containers/foo/export")
+		assert.Check(t, errdefs.IsForbidden(err), err)
+
+		resp, err := d.Do(ctx, http.MethodPost, "/v1.41/containers/foo/kill")
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		assert.Check(t, cmp.DeepEqual(sent, []string{"POST /v1.41/containers/foo/kill"}))
+	})
+
+	t.Run("rewritten path", func(t *testing.T) {
+		sent = nil
+		withPath := func(p string) RequestOpt {
+			return func(req *http.Request) error {
+				req.URL.Path = p
+				return nil
+			}
+		}
+
+		d := Chain(backend, WithPolicy(Policy{Allowed: []EndpointClass{ClassRead, ClassMutate}}))
+		_, err := d.Do(ctx, http.MethodPost, "/v1.41/containers/foo/start", withPath("/v1.41/containers/create"), withBody(`{"HostConfig": {"Privileged": true}}`))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		assert.Check(t, cmp.ErrorContains(err, "privileged"))
+
+		d = Chain(backend, WithPolicy(ReadOnlyPolicy()))
+		_, err = d.DoRaw(ctx, http.MethodGet, "/v1.41/containers/json", withPath("/v1.41/containers/x/attach/ws"))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+		_, err = d.Do(ctx, http.MethodGet, "/v1.41/containers/json", withPath("/v1.41/containers/json/../x/attach/ws"))
+		assert.Check(t, errdefs.IsForbidden(err), err)
+
+		assert.Check(t, cmp.Len(sent, 0))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		sent = nil
+		buf := bytes.NewBuffer(nil)
+		policy := ReadOnlyPolicy()
+		policy.DryRun = true
+		policy.Logger = slog.New(slog.NewTextHandler(buf, nil))
+		d := Chain(backend, WithPolicy(policy))
+
+		resp, err := d.Do(ctx, http.MethodPost, "/v1.41/containers/create", withBody(`{"HostConfig": {"Privileged": true}}`))
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		assert.Check(t, cmp.Len(sent, 1))
+		out := buf.String()
+		assert.Check(t, cmp.Contains(out, "request would be denied by policy"))
+		assert.Check(t, cmp.Contains(out, "privileged"))
+	})
+}
@@ -0,0 +1,661 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// PoolMember describes one of the backends of a Pool.
+type PoolMember struct {
+	// Index is the position of the backend in the list passed to NewPool.
+	Index int
+	// Healthy is false if the last health check, or the last request, failed to reach the backend.
+	Healthy bool
+	// Inflight is the number of requests currently being handled by the backend.
+	// Requests count until their response body is closed, and hijacked connections until they are closed.
+	Inflight int
+	// Err is the error from the last failed health check, if the backend is unhealthy.
+	Err error
+}
+
+// PoolStrategy selects the backend a request is routed to.
+type PoolStrategy interface {
+	// Pick returns the position in members of the backend to use.
+	// members only includes healthy backends and is never empty.
+	Pick(ctx context.Context, method, uri string, members []PoolMember) int
+}
+
+// RoundRobin creates a PoolStrategy which cycles through the healthy backends.
+func RoundRobin() PoolStrategy {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	n atomic.Uint64
+}
+
+func (r *roundRobin) Pick(_ context.Context, _, _ string, members []PoolMember) int {
+	return int((r.n.Add(1) - 1) % uint64(len(members)))
+}
+
+// LeastInflight creates a PoolStrategy which picks the healthy backend with the fewest requests in flight.
+func LeastInflight() PoolStrategy {
+	return leastInflight{}
+}
+
+type leastInflight struct{}
+
+func (leastInflight) Pick(_ context.Context, _, _ string, members []PoolMember) int {
+	var idx int
+	for i, m := range members {
+		if m.Inflight < members[idx].Inflight {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// StickyByKey creates a PoolStrategy which sends all requests with the same key to the same backend, as long as it
+// is healthy.
+// key is called for each request, if it returns an empty string the request is routed using fallback.
+// If fallback is nil, RoundRobin is used.
+//
+// Keys are assigned with rendezvous hashing, so when a backend becomes unhealthy only the keys assigned to it move.
+func StickyByKey(key func(ctx context.Context, method, uri string) string, fallback PoolStrategy) PoolStrategy {
+	if fallback == nil {
+		fallback = RoundRobin()
+	}
+	return &stickyByKey{key: key, fallback: fallback}
+}
+
+type stickyByKey struct {
+	key      func(ctx context.Context, method, uri string) string
+	fallback PoolStrategy
+}
+
+func (s *stickyByKey) Pick(ctx context.Context, method, uri string, members []PoolMember) int {
+	k := s.key(ctx, method, uri)
+	if k == "" {
+		return s.fallback.Pick(ctx, method, uri, members)
+	}
+
+	var (
+		idx int
+		max uint64
+	)
+	for i, m := range members {
+		h := fnv.New64a()
+		io.WriteString(h, k+"/"+strconv.Itoa(m.Index))
+		if sum := h.Sum64(); i == 0 || sum > max {
+			idx, max = i, sum
+		}
+	}
+	return idx
+}
+
+// PoolConfig holds the options for a Pool.
+type PoolConfig struct {
+	// Strategy selects the backend for requests which are not pinned to one.
+	// Defaults to RoundRobin.
+	Strategy PoolStrategy
+	// HealthCheckInterval is how often backends are pinged with /_ping.
+	// Defaults to 10s. Set to a negative value to disable health checks.
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout is how long to wait for a ping to complete.
+	// Defaults to 5s.
+	HealthCheckTimeout time.Duration
+}
+
+// PoolOption is used as functional arguments to `NewPool`.
+type PoolOption func(*PoolConfig)
+
+// Pool is a Doer which routes requests to multiple Docker instances.
+//
+// Backends are health checked in the background, and requests are routed to healthy backends using the configured
+// PoolStrategy.
+// Idempotent (GET and HEAD) requests which fail to reach a backend are retried on another one.
+//
+// Objects only exist on the backend that created them, so follow-up requests are pinned to that backend:
+// once a container or exec is created through the pool, all requests for it (including hijacked connections for
+// attach and exec start) go to the same backend, whether it is referenced by ID, by a unique prefix of its ID, or by
+// name, including after a rename.
+// Pins are dropped when the container is removed or pruned, or when the backend reports it does not exist.
+// Use `Pin` to route a sequence of requests to the same backend, e.g. an image pull followed by a container create.
+type Pool struct {
+	backends []*poolBackend
+	cfg      PoolConfig
+
+	mu   sync.Mutex
+	pins map[string]pin
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type poolBackend struct {
+	index    int
+	d        Doer
+	inflight atomic.Int64
+
+	mu      sync.Mutex
+	healthy bool
+	err     error
+}
+
+func (b *poolBackend) setHealth(err error) {
+	b.mu.Lock()
+	b.healthy = err == nil
+	b.err = err
+	b.mu.Unlock()
+}
+
+func (b *poolBackend) member() PoolMember {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return PoolMember{Index: b.index, Healthy: b.healthy, Inflight: int(b.inflight.Load()), Err: b.err}
+}
+
+// pin records the backend an object was created on.
+type pin struct {
+	backend *poolBackend
+	// owner is the key of the container an exec belongs to, so it can be cleaned up with the container.
+	owner string
+}
+
+// NewPool creates a Pool which routes requests to the provided backends.
+//
+// All backends are considered healthy until they are checked.
+// Health checks run in the background until `Close` is called.
+func NewPool(backends []Doer, opts ...PoolOption) (*Pool, error) {
+	if len(backends) == 0 {
+		return nil, errdefs.Invalid("pool requires at least one backend")
+	}
+
+	cfg := PoolConfig{
+		Strategy:            RoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.Strategy == nil {
+		cfg.Strategy = RoundRobin()
+	}
+	if cfg.HealthCheckInterval == 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		cfg:    cfg,
+		pins:   make(map[string]pin),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	for i, d := range backends {
+		p.backends = append(p.backends, &poolBackend{index: i, d: d, healthy: true})
+	}
+
+	if cfg.HealthCheckInterval < 0 {
+		close(p.done)
+		return p, nil
+	}
+	go p.healthLoop(ctx)
+	return p, nil
+}
+
+// Close stops the background health checks.
+// It does not close the backends.
+func (p *Pool) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
+
+// Members returns the current state of all the backends in the pool.
+func (p *Pool) Members() []PoolMember {
+	members := make([]PoolMember, 0, len(p.backends))
+	for _, b := range p.backends {
+		members = append(members, b.member())
+	}
+	return members
+}
+
+func (p *Pool) healthLoop(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckHealth pings all the backends and updates their health.
+// This is done periodically in the background, but can be called to get an up-to-date view, e.g. before `Members`.
+func (p *Pool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range p.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.ping(ctx, b)
+			if ctx.Err() != nil {
+				return
+			}
+			b.setHealth(err)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) ping(ctx context.Context, b *poolBackend) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	resp, err := b.d.Do(ctx, http.MethodGet, "/_ping")
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errdefs.Unavailablef("ping failed with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type poolPinKey struct{}
+
+type poolPin struct {
+	p       *Pool
+	mu      sync.Mutex
+	backend *poolBackend
+}
+
+// Pin returns a context which routes all requests made with it to the same backend.
+// The backend is selected by the first request made with the context.
+func (p *Pool) Pin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, poolPinKey{}, &poolPin{p: p})
+}
+
+var (
+	apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+	nonObjectPaths   = map[string]bool{"create": true, "json": true, "prune": true}
+)
+
+// objectKey returns the key used to pin the container or exec which is referenced by the uri.
+func objectKey(uri string) string {
+	uri = apiVersionPrefix.ReplaceAllString(uri, "/")
+	parts := strings.SplitN(strings.TrimPrefix(uri, "/"), "/", 3)
+	if len(parts) < 2 || parts[1] == "" || nonObjectPaths[parts[1]] {
+		return ""
+	}
+	switch parts[0] {
+	case "containers":
+		return "container:" + parts[1]
+	case "exec":
+		return "exec:" + parts[1]
+	}
+	return ""
+}
+
+func (p *Pool) pinned(key string) *poolBackend {
+	if key == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, pinned, _ := p.lookup(key)
+	return pinned.backend
+}
+
+// lookup returns the pin for key, along with the key it is stored under.
+// Like the daemon, IDs can be referenced by a prefix, as long as it is unique.
+// p.mu must be held.
+func (p *Pool) lookup(key string) (string, pin, bool) {
+	if pinned, ok := p.pins[key]; ok {
+		return key, pinned, true
+	}
+
+	var (
+		found string
+		match pin
+	)
+	for k, v := range p.pins {
+		// Container names are pinned with the container ID as owner, names cannot be referenced by prefix.
+		isID := strings.HasPrefix(k, "exec:") || v.owner == ""
+		if !isID || !strings.HasPrefix(k, key) {
+			continue
+		}
+		if found != "" {
+			return "", pin{}, false
+		}
+		found, match = k, v
+	}
+	return found, match, found != ""
+}
+
+// pick selects the backend for a request.
+// exclude is the set of backends which already failed for this request.
+func (p *Pool) pick(ctx context.Context, method, uri string, exclude map[*poolBackend]bool) (*poolBackend, error) {
+	if b := p.pinned(objectKey(uri)); b != nil {
+		return b, nil
+	}
+
+	members := make([]PoolMember, 0, len(p.backends))
+	for _, b := range p.backends {
+		if m := b.member(); m.Healthy && !exclude[b] {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return nil, errdefs.Unavailable("no healthy backends in pool")
+	}
+	return p.backends[members[p.cfg.Strategy.Pick(ctx, method, uri, members)].Index], nil
+}
+
+// route selects the backend for a request, taking a context pin into account.
+func (p *Pool) route(ctx context.Context, method, uri string, exclude map[*poolBackend]bool) (*poolBackend, error) {
+	cp, _ := ctx.Value(poolPinKey{}).(*poolPin)
+	if cp == nil || cp.p != p {
+		return p.pick(ctx, method, uri, exclude)
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.backend == nil {
+		b, err := p.pick(ctx, method, uri, exclude)
+		if err != nil {
+			return nil, err
+		}
+		cp.backend = b
+	}
+	return cp.backend, nil
+}
+
+// isPinned returns true if requests for the uri must go to a specific backend.
+func (p *Pool) isPinned(ctx context.Context, uri string) bool {
+	if cp, _ := ctx.Value(poolPinKey{}).(*poolPin); cp != nil && cp.p == p {
+		return true
+	}
+	return p.pinned(objectKey(uri)) != nil
+}
+
+// Do implements the Doer.Do interface
+func (p *Pool) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	var (
+		query    string
+		captureQ RequestOpt = func(req *http.Request) error {
+			query = req.URL.RawQuery
+			return nil
+		}
+		exclude = make(map[*poolBackend]bool)
+	)
+	opts = append(opts[:len(opts):len(opts)], captureQ)
+
+	for {
+		b, err := p.route(ctx, method, uri, exclude)
+		if err != nil {
+			return nil, err
+		}
+
+		b.inflight.Add(1)
+		resp, err := b.d.Do(ctx, method, uri, opts...)
+		if err != nil {
+			b.inflight.Add(-1)
+			if ctx.Err() == nil && isConnectionError(err) {
+				b.setHealth(err)
+				if (method == http.MethodGet || method == http.MethodHead) && !p.isPinned(ctx, uri) {
+					exclude[b] = true
+					continue
+				}
+			}
+			return resp, err
+		}
+
+		resp, err = p.track(b, method, uri, query, resp)
+		if err != nil {
+			b.inflight.Add(-1)
+			return nil, err
+		}
+		resp.Body = &poolBody{ReadCloser: resp.Body, release: func() { b.inflight.Add(-1) }}
+		return resp, nil
+	}
+}
+
+// DoRaw implements the Doer.DoRaw interface
+func (p *Pool) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	b, err := p.route(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b.inflight.Add(1)
+	conn, err := b.d.DoRaw(ctx, method, uri, opts...)
+	if err != nil {
+		b.inflight.Add(-1)
+		if ctx.Err() == nil && isConnectionError(err) {
+			b.setHealth(err)
+		}
+		return nil, err
+	}
+	return newPoolConn(conn, func() { b.inflight.Add(-1) }), nil
+}
+
+type createResponse struct {
+	ID string `json:"Id"`
+}
+
+type pruneResponse struct {
+	ContainersDeleted []string
+}
+
+// readBody reads the response body and replaces it so it can still be read by the caller.
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// isNoSuchObject returns true if the error message in the body is the one returned by the daemon for a container or
+// exec which does not exist. Other endpoints use 404 for things like files missing in a container.
+func isNoSuchObject(body []byte) bool {
+	return bytes.Contains(body, []byte("No such container")) || bytes.Contains(body, []byte("No such exec instance"))
+}
+
+// track records the pins for objects created, renamed or removed by a request.
+func (p *Pool) track(b *poolBackend, method, uri, query string, resp *http.Response) (*http.Response, error) {
+	path := apiVersionPrefix.ReplaceAllString(uri, "/")
+
+	if resp.StatusCode == http.StatusNotFound {
+		key := objectKey(path)
+		if p.pinned(key) == nil {
+			return resp, nil
+		}
+		data, err := readBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		if isNoSuchObject(data) {
+			p.unpin(key)
+		}
+		return resp, nil
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp, nil
+	}
+
+	switch {
+	case method == http.MethodDelete && strings.HasPrefix(path, "/containers/"):
+		p.unpin(objectKey(path))
+		return resp, nil
+	case method != http.MethodPost:
+		return resp, nil
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/rename"):
+		if q, err := url.ParseQuery(query); err == nil && q.Get("name") != "" {
+			p.rename(objectKey(path), q.Get("name"))
+		}
+		return resp, nil
+	case path == "/containers/create":
+	case path == "/containers/prune":
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/exec"):
+	default:
+		return resp, nil
+	}
+
+	data, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "/containers/prune" {
+		var pruned pruneResponse
+		if err := json.Unmarshal(data, &pruned); err != nil {
+			return resp, nil
+		}
+		for _, id := range pruned.ContainersDeleted {
+			if key := "container:" + id; p.pinned(key) == b {
+				p.unpin(key)
+			}
+		}
+		return resp, nil
+	}
+
+	var created createResponse
+	if err := json.Unmarshal(data, &created); err != nil || created.ID == "" {
+		return resp, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if path == "/containers/create" {
+		p.pins["container:"+created.ID] = pin{backend: b}
+		if q, err := url.ParseQuery(query); err == nil && q.Get("name") != "" {
+			p.pins["container:"+strings.TrimPrefix(q.Get("name"), "/")] = pin{backend: b, owner: "container:" + created.ID}
+		}
+		return resp, nil
+	}
+
+	owner, pinned, ok := p.lookup(objectKey(path))
+	if !ok {
+		owner = objectKey(path)
+	} else if pinned.owner != "" {
+		owner = pinned.owner
+	}
+	p.pins["exec:"+created.ID] = pin{backend: b, owner: owner}
+	return resp, nil
+}
+
+// rename replaces the name pinned for a container.
+func (p *Pool) rename(key, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, pinned, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	if pinned.owner != "" {
+		key = pinned.owner
+	}
+	for k, v := range p.pins {
+		if v.owner == key && strings.HasPrefix(k, "container:") {
+			delete(p.pins, k)
+		}
+	}
+	p.pins["container:"+strings.TrimPrefix(name, "/")] = pin{backend: pinned.backend, owner: key}
+}
+
+// unpin removes the pin for a removed exec, or for a removed container along with its name and execs.
+func (p *Pool) unpin(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, pinned, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(key, "exec:") {
+		delete(p.pins, key)
+		return
+	}
+	if pinned.owner != "" {
+		key = pinned.owner
+	}
+	delete(p.pins, key)
+	for k, v := range p.pins {
+		if v.owner == key {
+			delete(p.pins, k)
+		}
+	}
+}
+
+// poolBody decrements the inflight count of a backend when a response body is closed.
+type poolBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *poolBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
+
+// poolConn decrements the inflight count of a backend when a hijacked connection is closed.
+type poolConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func newPoolConn(conn net.Conn, release func()) net.Conn {
+	pc := &poolConn{Conn: conn, release: release}
+	if _, ok := conn.(closeWriter); ok {
+		return &poolConnCloseWrite{pc}
+	}
+	return pc
+}
+
+func (c *poolConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+type poolConnCloseWrite struct {
+	*poolConn
+}
+
+func (c *poolConnCloseWrite) CloseWrite() error {
+	return c.Conn.(closeWriter).CloseWrite()
+}
@@ -0,0 +1,411 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// poolTestBackend is a fake backend which records the requests it receives.
+type poolTestBackend struct {
+	name string
+
+	mu       sync.Mutex
+	requests []string
+	down     bool
+	// gone makes the backend report that all containers and execs do not exist.
+	gone bool
+}
+
+func (b *poolTestBackend) setGone(gone bool) {
+	b.mu.Lock()
+	b.gone = gone
+	b.mu.Unlock()
+}
+
+func (b *poolTestBackend) isGone() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gone
+}
+
+func (b *poolTestBackend) setDown(down bool) {
+	b.mu.Lock()
+	b.down = down
+	b.mu.Unlock()
+}
+
+func (b *poolTestBackend) record(req *http.Request) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down {
+		return &net.OpError{Op: "dial", Net: "unix", Err: io.EOF}
+	}
+	if req.URL.Path != "/_ping" {
+		b.requests = append(b.requests, req.Method+" "+req.URL.Path)
+	}
+	return nil
+}
+
+func (b *poolTestBackend) Requests() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.requests...)
+}
+
+func (b *poolTestBackend) doer() Doer {
+	return &funcDoer{
+		do: func(req *http.Request) (*http.Response, error) {
+			if err := b.record(req); err != nil {
+				return nil, err
+			}
+
+			body := "OK"
+			status := http.StatusOK
+			switch {
+			case req.URL.Path == "/v1.41/containers/create":
+				status = http.StatusCreated
+				body = fmt.Sprintf(`{"Id": "%s-container"}`, b.name)
+			case req.URL.Path == "/v1.41/containers/prune":
+				body = fmt.Sprintf(`{"ContainersDeleted": ["%s-container"]}`, b.name)
+			case b.isGone():
+				status = http.StatusNotFound
+				body = fmt.Sprintf(`{"message": "No such container: %s-container"}`, b.name)
+			case strings.HasSuffix(req.URL.Path, "/exec"):
+				status = http.StatusCreated
+				body = fmt.Sprintf(`{"Id": "%s-exec"}`, b.name)
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+		doRaw: func(req *http.Request) (net.Conn, error) {
+			if err := b.record(req); err != nil {
+				return nil, err
+			}
+			c1, c2 := net.Pipe()
+			c2.Close()
+			return c1, nil
+		},
+	}
+}
+
+func newTestPool(t *testing.T, n int, opts ...PoolOption) (*Pool, []*poolTestBackend) {
+	t.Helper()
+
+	var (
+		backends []*poolTestBackend
+		doers    []Doer
+	)
+	for i := 0; i < n; i++ {
+		b := &poolTestBackend{name: fmt.Sprintf("b%d", i)}
+		backends = append(backends, b)
+		doers = append(doers, b.doer())
+	}
+
+	opts = append([]PoolOption{func(cfg *PoolConfig) {
+		cfg.HealthCheckInterval = -1
+	}}, opts...)
+	p, err := NewPool(doers, opts...)
+	assert.NilError(t, err)
+	t.Cleanup(func() { p.Close() })
+	return p, backends
+}
+
+func doGet(t *testing.T, ctx context.Context, d Doer, uri string) {
+	t.Helper()
+	resp, err := d.Do(ctx, http.MethodGet, uri)
+	assert.NilError(t, err)
+	resp.Body.Close()
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	p, backends := newTestPool(t, 3)
+
+	for i := 0; i < 6; i++ {
+		doGet(t, ctx, p, "/info")
+	}
+	for _, b := range backends {
+		assert.Check(t, cmp.Len(b.Requests(), 2), b.name)
+	}
+
+	backends[1].setDown(true)
+	p.CheckHealth(ctx)
+	members := p.Members()
+	assert.Check(t, !members[1].Healthy)
+	assert.Check(t, members[1].Err != nil)
+
+	for i := 0; i < 4; i++ {
+		doGet(t, ctx, p, "/info")
+	}
+	assert.Check(t, cmp.Len(backends[0].Requests(), 4))
+	assert.Check(t, cmp.Len(backends[1].Requests(), 2))
+	assert.Check(t, cmp.Len(backends[2].Requests(), 4))
+
+	backends[0].setDown(true)
+	backends[2].setDown(true)
+	p.CheckHealth(ctx)
+	_, err := p.Do(ctx, http.MethodGet, "/info")
+	assert.Check(t, errdefs.IsUnavailable(err), err)
+
+	backends[1].setDown(false)
+	p.CheckHealth(ctx)
+	doGet(t, ctx, p, "/info")
+	assert.Check(t, cmp.Len(backends[1].Requests(), 3))
+}
+
+func TestPoolFailover(t *testing.T) {
+	ctx := context.Background()
+	p, backends := newTestPool(t, 2)
+
+	// The pool does not know backend 0 is down until a request fails.
+	backends[0].setDown(true)
+	doGet(t, ctx, p, "/info")
+	assert.Check(t, cmp.Len(backends[1].Requests(), 1))
+	assert.Check(t, !p.Members()[0].Healthy)
+
+	// Requests which are not idempotent are not retried.
+	backends[0].setDown(false)
+	p.CheckHealth(ctx)
+	backends[1].setDown(true)
+	_, err := p.Do(ctx, http.MethodPost, "/containers/create")
+	_, err2 := p.Do(ctx, http.MethodPost, "/containers/create")
+	assert.Check(t, err != nil || err2 != nil)
+	assert.Check(t, cmp.Len(backends[0].Requests(), 1))
+}
+
+func TestPoolPinning(t *testing.T) {
+	ctx := context.Background()
+	p, backends := newTestPool(t, 3)
+
+	// Skip over the first backend so pins do not just happen to match the round-robin order.
+	doGet(t, ctx, p, "/info")
+
+	resp, err := p.Do(ctx, http.MethodPost, "/v1.41/containers/create", func(req *http.Request) error {
+		req.URL.RawQuery = "name=foo"
+		return nil
+	})
+	assert.NilError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	// The body must still be readable after the pool decoded it.
+	assert.Check(t, cmp.Equal(string(data), `{"Id": "b1-container"}`))
+
+	resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/b1-container/exec")
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	conn, err := p.DoRaw(ctx, http.MethodPost, "/v1.41/exec/b1-exec/start", WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(p.Members()[1].Inflight, 1))
+	conn.Close()
+	assert.Check(t, cmp.Equal(p.Members()[1].Inflight, 0))
+
+	conn, err = p.DoRaw(ctx, http.MethodPost, "/v1.41/containers/foo/attach", WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	conn.Close()
+
+	doGet(t, ctx, p, "/v1.41/containers/foo/json")
+
+	assert.Check(t, cmp.DeepEqual(backends[1].Requests(), []string{
+		"POST /v1.41/containers/create",
+		"POST /v1.41/containers/b1-container/exec",
+		"POST /v1.41/exec/b1-exec/start",
+		"POST /v1.41/containers/foo/attach",
+		"GET /v1.41/containers/foo/json",
+	}))
+
+	// Removing the container removes all its pins.
+	resp, err = p.Do(ctx, http.MethodDelete, "/v1.41/containers/foo")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	p.mu.Lock()
+	assert.Check(t, cmp.Len(p.pins, 0))
+	p.mu.Unlock()
+
+	// Pinned requests are not moved to another backend when the backend fails.
+	resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/create")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	backends[2].setDown(true)
+	_, err = p.Do(ctx, http.MethodGet, "/v1.41/containers/b2-container/json")
+	assert.Check(t, err != nil)
+	assert.Check(t, cmp.Len(backends[0].Requests(), 1))
+}
+
+func TestPoolPinTracking(t *testing.T) {
+	ctx := context.Background()
+	p, backends := newTestPool(t, 3)
+
+	pins := func() []string {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		var keys []string
+		for k := range p.pins {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	withName := func(name string) RequestOpt {
+		return func(req *http.Request) error {
+			req.URL.RawQuery = "name=" + name
+			return nil
+		}
+	}
+
+	// Skip over the first backend so pins do not just happen to match the round-robin order.
+	doGet(t, ctx, p, "/info")
+
+	resp, err := p.Do(ctx, http.MethodPost, "/v1.41/containers/create", withName("foo"))
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	// Requests count as inflight until the body is closed.
+	resp, err = p.Do(ctx, http.MethodGet, "/v1.41/containers/b1-container/logs")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(p.Members()[1].Inflight, 1))
+	resp.Body.Close()
+	assert.Check(t, cmp.Equal(p.Members()[1].Inflight, 0))
+
+	// IDs can be shortened.
+	doGet(t, ctx, p, "/v1.41/containers/b1-con/json")
+
+	resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/b1-con/exec")
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/foo/rename", withName("bar"))
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, cmp.DeepEqual(pins(), []string{"container:b1-container", "container:bar", "exec:b1-exec"}))
+	doGet(t, ctx, p, "/v1.41/containers/bar/json")
+
+	assert.Check(t, cmp.DeepEqual(backends[1].Requests(), []string{
+		"POST /v1.41/containers/create",
+		"GET /v1.41/containers/b1-container/logs",
+		"GET /v1.41/containers/b1-con/json",
+		"POST /v1.41/containers/b1-con/exec",
+		"POST /v1.41/containers/foo/rename",
+		"GET /v1.41/containers/bar/json",
+	}))
+
+	// A container which is pruned is unpinned, along with its name and execs.
+	for range backends {
+		resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/prune")
+		assert.NilError(t, err)
+		resp.Body.Close()
+	}
+	assert.Check(t, cmp.Len(pins(), 0))
+
+	// A container which is removed from the backend, e.g. by another client, is unpinned.
+	resp, err = p.Do(ctx, http.MethodPost, "/v1.41/containers/create", withName("foo"))
+	assert.NilError(t, err)
+	resp.Body.Close()
+	backends[p.pinned("container:foo").index].setGone(true)
+	resp, err = p.Do(ctx, http.MethodGet, "/v1.41/containers/foo/json")
+	assert.NilError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(string(data), "No such container"))
+	assert.Check(t, cmp.Len(pins(), 0))
+}
+
+func TestPoolPinContext(t *testing.T) {
+	p, backends := newTestPool(t, 2)
+
+	ctx := p.Pin(context.Background())
+	for i := 0; i < 4; i++ {
+		doGet(t, ctx, p, "/info")
+	}
+	assert.Check(t, cmp.Len(backends[0].Requests(), 4))
+	assert.Check(t, cmp.Len(backends[1].Requests(), 0))
+}
+
+func TestPoolStrategies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("least inflight", func(t *testing.T) {
+		p, backends := newTestPool(t, 2, func(cfg *PoolConfig) {
+			cfg.Strategy = LeastInflight()
+		})
+
+		conn, err := p.DoRaw(ctx, http.MethodPost, "/session")
+		assert.NilError(t, err)
+		defer conn.Close()
+
+		for i := 0; i < 3; i++ {
+			doGet(t, ctx, p, "/info")
+		}
+		assert.Check(t, cmp.Len(backends[0].Requests(), 1))
+		assert.Check(t, cmp.Len(backends[1].Requests(), 3))
+	})
+
+	t.Run("sticky", func(t *testing.T) {
+		key := func(_ context.Context, _, uri string) string {
+			if strings.HasPrefix(uri, "/images/") {
+				return uri
+			}
+			return ""
+		}
+		p, backends := newTestPool(t, 4, func(cfg *PoolConfig) {
+			cfg.Strategy = StickyByKey(key, nil)
+		})
+
+		for i := 0; i < 4; i++ {
+			doGet(t, ctx, p, "/images/busybox/json")
+		}
+		var target *poolTestBackend
+		for _, b := range backends {
+			if len(b.Requests()) > 0 {
+				assert.Assert(t, target == nil, "requests for the same key went to multiple backends")
+				target = b
+			}
+		}
+		assert.Assert(t, target != nil)
+		assert.Check(t, cmp.Len(target.Requests(), 4))
+
+		// Only keys of the unhealthy backend move
+		target.setDown(true)
+		p.CheckHealth(ctx)
+		doGet(t, ctx, p, "/images/busybox/json")
+		assert.Check(t, cmp.Len(target.Requests(), 4))
+	})
+}
+
+func TestPoolHealthLoop(t *testing.T) {
+	b := &poolTestBackend{name: "b0"}
+	b.setDown(true)
+	p, err := NewPool([]Doer{b.doer()}, func(cfg *PoolConfig) {
+		cfg.HealthCheckInterval = 10 * time.Millisecond
+	})
+	assert.NilError(t, err)
+	defer p.Close()
+
+	waitHealthy := func(healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for p.Members()[0].Healthy != healthy {
+			assert.Assert(t, time.Now().Before(deadline), "timeout waiting for backend health to be %v", healthy)
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitHealthy(false)
+	b.setDown(false)
+	waitHealthy(true)
+
+	_, err = NewPool(nil)
+	assert.Check(t, errdefs.IsInvalid(err), err)
+}
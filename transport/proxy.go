@@ -0,0 +1,255 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// WithProxy is a ConnectionOption which sends all requests to the daemon through the HTTP(S) proxy at the provided URL.
+// Hijacked connections, such as for attach or exec, are tunneled through the proxy with CONNECT.
+//
+// Credentials in the URL are sent to the proxy using basic authentication.
+// This is only used by TCP transports.
+func WithProxy(proxy *url.URL) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		if proxy != nil && proxy.Scheme != "http" && proxy.Scheme != "https" {
+			return fmt.Errorf("proxy scheme not supported: %s", proxy.Scheme)
+		}
+		cfg.Proxy = http.ProxyURL(proxy)
+		return nil
+	}
+}
+
+// WithProxyFromEnvironment is a ConnectionOption which selects the proxy the same way the docker CLI does, using the
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables (or the lowercase versions of them).
+// HTTPS_PROXY is used when the daemon is connected to with TLS, HTTP_PROXY otherwise.
+//
+// The environment is read when the option is applied.
+// Like net/http, requests to localhost or loopback addresses are never proxied.
+func WithProxyFromEnvironment() ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		env := proxyEnv{
+			httpProxy:  getenvAny("HTTP_PROXY", "http_proxy"),
+			httpsProxy: getenvAny("HTTPS_PROXY", "https_proxy"),
+			noProxy:    getenvAny("NO_PROXY", "no_proxy"),
+		}
+		for _, p := range []string{env.httpProxy, env.httpsProxy} {
+			if p == "" {
+				continue
+			}
+			if _, err := parseProxyURL(p); err != nil {
+				return err
+			}
+		}
+		cfg.Proxy = env.proxyFunc
+		return nil
+	}
+}
+
+func getenvAny(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type proxyEnv struct {
+	httpProxy  string
+	httpsProxy string
+	noProxy    string
+}
+
+func (e proxyEnv) proxyFunc(req *http.Request) (*url.URL, error) {
+	proxy := e.httpProxy
+	if req.URL.Scheme == "https" {
+		proxy = e.httpsProxy
+	}
+	if proxy == "" || !useProxy(req.URL.Host, e.noProxy) {
+		return nil, nil
+	}
+	return parseProxyURL(proxy)
+}
+
+// parseProxyURL parses a proxy URL from the environment, which may not include a scheme.
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		u, err = url.Parse("http://" + s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy address %q: %w", s, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("proxy scheme not supported: %s", u.Scheme)
+	}
+	return u, nil
+}
+
+// useProxy reports whether requests to addr (host:port) should be proxied according to the NO_PROXY value.
+//
+// NO_PROXY is a comma separated list of:
+//   - "*", which disables the proxy for all hosts
+//   - host names, which match the host and all its subdomains ("example.com" matches "foo.example.com")
+//   - host names with a leading ".", which only match subdomains
+//   - IP addresses and CIDR ranges
+//
+// Any entry may include a port, in which case it only matches that port.
+func useProxy(addr, noProxy string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return false
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return false
+			}
+			continue
+		}
+
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(entryHost, "*")
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return false
+			}
+			continue
+		}
+		if strings.HasPrefix(entryHost, ".") {
+			if strings.HasSuffix(host, entryHost) {
+				return false
+			}
+			continue
+		}
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialProxy connects to addr (host:port), tunneling through the proxy selected by cfg.Proxy with CONNECT if there is
+// one.
+// scheme is the scheme used to talk to the daemon, which is used to select the proxy.
+func (cfg *ConnectionConfig) dialProxy(ctx context.Context, scheme, addr string) (net.Conn, error) {
+	d := cfg.dialer()
+	if cfg.Proxy == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	proxy, err := cfg.Proxy(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}})
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to proxy %s: %w", proxyAddr, err)
+	}
+
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error establishing TLS connection to proxy %s: %w", proxyAddr, err)
+		}
+		conn = tlsConn
+	}
+
+	tunnel, err := connectTunnel(ctx, conn, proxy, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// connectTunnel asks the proxy on the other end of conn to open a tunnel to addr.
+func connectTunnel(ctx context.Context, conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxy.User; u != nil {
+		pass, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass)))
+	}
+
+	// Make sure the handshake with the proxy does not outlive the context.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("error writing CONNECT request to proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error reading CONNECT response from proxy: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused to connect to %s: %s", addr, resp.Status)
+	}
+	return newHijackedConn(conn, br), nil
+}
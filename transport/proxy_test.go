@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// testProxy is a stand-in for an HTTP proxy.
+// All requests are sent to backend, no matter which host they are for.
+type testProxy struct {
+	backend string
+
+	mu       sync.Mutex
+	requests []string
+	auth     []string
+}
+
+func (p *testProxy) Requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func (p *testProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req.Method+" "+req.Host)
+	p.auth = append(p.auth, req.Header.Get("Proxy-Authorization"))
+	p.mu.Unlock()
+
+	if req.Method != http.MethodConnect {
+		out := req.Clone(req.Context())
+		out.RequestURI = ""
+		out.URL.Scheme = "http"
+		out.URL.Host = p.backend
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	backend, err := net.Dial("tcp", p.backend)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer backend.Close()
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+	rw.Flush()
+
+	go io.Copy(backend, rw)
+	io.Copy(conn, backend)
+}
+
+// upgradeHandler echoes back data sent on upgraded connections, and the path for other requests.
+func upgradeHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Upgrade") == "" {
+		w.Write([]byte("hello " + req.URL.Path))
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	rw.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	rw.Flush()
+	io.Copy(conn, rw)
+}
+
+func newTestProxy(t *testing.T, backend string) (*testProxy, *url.URL) {
+	t.Helper()
+
+	p := &testProxy{backend: backend}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+	return p, u
+}
+
+func checkProxiedTransport(t *testing.T, tr *Transport) {
+	t.Helper()
+	ctx := context.Background()
+
+	resp, err := tr.Do(ctx, http.MethodGet, "/foo")
+	assert.NilError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello /foo"))
+
+	conn, err := tr.DoRaw(ctx, http.MethodPost, "/containers/foo/attach", WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping\n"))
+	assert.NilError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(line, "ping\n"))
+}
+
+func TestTCPTransportProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(upgradeHandler))
+	defer backend.Close()
+
+	proxy, proxyURL := newTestProxy(t, backend.Listener.Addr().String())
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	tr, err := TCPTransport("docker.example:2375", WithProxy(proxyURL))
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	checkProxiedTransport(t, tr)
+	assert.Check(t, cmp.DeepEqual(proxy.Requests(), []string{
+		"GET docker.example:2375",
+		"CONNECT docker.example:2375",
+	}))
+	for _, auth := range proxy.auth {
+		assert.Check(t, cmp.Equal(auth, "Basic dXNlcjpzZWNyZXQ="))
+	}
+}
+
+func TestTCPTransportProxyTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(upgradeHandler))
+	defer backend.Close()
+
+	proxy, proxyURL := newTestProxy(t, backend.Listener.Addr().String())
+
+	tr, err := TCPTransport("docker.example:2376", WithProxy(proxyURL), WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	checkProxiedTransport(t, tr)
+	assert.Check(t, cmp.DeepEqual(proxy.Requests(), []string{
+		"CONNECT docker.example:2376",
+		"CONNECT docker.example:2376",
+	}))
+}
+
+func TestTCPTransportProxyFromEnvironment(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(upgradeHandler))
+	defer backend.Close()
+
+	proxy, proxyURL := newTestProxy(t, backend.Listener.Addr().String())
+
+	t.Setenv("HTTP_PROXY", proxyURL.Host)
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("NO_PROXY", "other.example")
+
+	tr, err := TCPTransport("docker.example:2375", WithProxyFromEnvironment())
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	checkProxiedTransport(t, tr)
+	assert.Check(t, cmp.Len(proxy.Requests(), 2))
+
+	// Only HTTPS_PROXY is used for TLS connections.
+	tr, err = TCPTransport("docker.example:2376", WithProxyFromEnvironment(), WithTLSConfig(&tls.Config{}))
+	assert.NilError(t, err)
+	proxyFunc := tr.c.Transport.(*http.Transport).Proxy
+	u, err := proxyFunc(&http.Request{URL: &url.URL{Scheme: "https", Host: "docker.example:2376"}})
+	assert.NilError(t, err)
+	assert.Check(t, u == nil, u)
+
+	_, err = TCPTransport("docker.example:2375", WithProxy(&url.URL{Scheme: "socks5", Host: "localhost:1080"}))
+	assert.Check(t, err != nil)
+}
+
+func TestUseProxy(t *testing.T) {
+	cases := []struct {
+		addr    string
+		noProxy string
+		want    bool
+	}{
+		{addr: "docker.example:2375", want: true},
+		{addr: "localhost:2375", want: false},
+		{addr: "127.0.0.1:2375", want: false},
+		{addr: "docker.example:2375", noProxy: "*", want: false},
+		{addr: "docker.example:2375", noProxy: "other.example, docker.example", want: false},
+		{addr: "foo.docker.example:2375", noProxy: "docker.example", want: false},
+		{addr: "docker.example:2375", noProxy: ".docker.example", want: true},
+		{addr: "foo.docker.example:2375", noProxy: ".docker.example", want: false},
+		{addr: "foo.docker.example:2375", noProxy: "*.docker.example", want: false},
+		{addr: "notdocker.example:2375", noProxy: "docker.example", want: true},
+		{addr: "docker.example:2375", noProxy: "docker.example:2376", want: true},
+		{addr: "docker.example:2376", noProxy: "docker.example:2376", want: false},
+		{addr: "10.1.2.3:2375", noProxy: "10.0.0.0/8", want: false},
+		{addr: "192.168.1.2:2375", noProxy: "10.0.0.0/8,192.168.1.1", want: true},
+		{addr: "192.168.1.1:2375", noProxy: "10.0.0.0/8,192.168.1.1", want: false},
+	}
+
+	for _, tc := range cases {
+		assert.Check(t, cmp.Equal(useProxy(tc.addr, tc.noProxy), tc.want), "addr=%s no_proxy=%s", tc.addr, tc.noProxy)
+	}
+}
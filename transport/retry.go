@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// RetryConfig holds the options for the retry middleware.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of times a request is attempted, including the first attempt.
+	// Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is how long to wait before the first retry.
+	// The wait time doubles for each subsequent retry.
+	// Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time to wait between retries.
+	// Defaults to 5s.
+	MaxBackoff time.Duration
+}
+
+// RetryOption is used as functional arguments to `WithRetry`.
+type RetryOption func(*RetryConfig)
+
+// WithRetry creates a Middleware which retries idempotent (GET and HEAD) requests that fail because the connection
+// failed or the daemon is unavailable (errdefs.IsUnavailable or a 503 status code).
+//
+// Retries back off exponentially.
+// DoRaw requests are not retried.
+func WithRetry(opts ...RetryOption) Middleware {
+	cfg := RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	return func(d Doer) Doer {
+		return &retryDoer{d: d, cfg: cfg}
+	}
+}
+
+type retryDoer struct {
+	d   Doer
+	cfg RetryConfig
+}
+
+func (r *retryDoer) Do(ctx context.Context, method, uri string, opts ...RequestOpt) (*http.Response, error) {
+	if method != http.MethodGet && method != http.MethodHead {
+		return r.d.Do(ctx, method, uri, opts...)
+	}
+
+	backoff := r.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := r.d.Do(ctx, method, uri, opts...)
+		if attempt >= r.cfg.MaxAttempts || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+func (r *retryDoer) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (net.Conn, error) {
+	return r.d.DoRaw(ctx, method, uri, opts...)
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err == nil {
+		return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return isConnectionError(err) || errdefs.IsUnavailable(err)
+}
+
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,570 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConnectionConfig is the configuration for the SSHTransport function
+type SSHConnectionConfig struct {
+	// User is the user to authenticate as on the remote host.
+	// Defaults to the user in the connection URL, or the current user if the URL has none.
+	User string
+	// KnownHostsFiles is the list of known_hosts files used to verify the remote host key.
+	// Defaults to ~/.ssh/known_hosts.
+	// The server is asked for a host key of one of the types recorded for the host.
+	// This is ignored if HostKeyCallback is set.
+	KnownHostsFiles []string
+	// HostKeyCallback is used to verify the remote host key.
+	// When set, this takes precedence over KnownHostsFiles.
+	HostKeyCallback ssh.HostKeyCallback
+	// IdentityFiles is the list of private key files to use for public key authentication.
+	// Defaults to whichever of ~/.ssh/id_ed25519, ~/.ssh/id_ecdsa, and ~/.ssh/id_rsa exist.
+	IdentityFiles []string
+	// DisableAgent disables authenticating with the ssh-agent available at $SSH_AUTH_SOCK.
+	// Otherwise the connection to the agent is kept open until the transport is closed.
+	DisableAgent bool
+	// AuthMethods are extra authentication methods to try after the agent and identity files.
+	AuthMethods []ssh.AuthMethod
+	// RemoteCommand is the command to run on the remote host.
+	// The command must proxy its stdio to the docker daemon.
+	// Defaults to `docker system dial-stdio`.
+	RemoteCommand []string
+	// Timeout is the maximum amount of time to wait for the SSH connection to be established.
+	// Defaults to 30 seconds.
+	Timeout time.Duration
+	// Stderr, when set, receives the stderr of the remote command.
+	// This is the only way to get error messages from the remote command.
+	Stderr io.Writer
+}
+
+// SSHConnectionOption is an option for the SSHTransport function
+type SSHConnectionOption func(*SSHConnectionConfig) error
+
+// WithSSHOptions is a ConnectionOption which passes the provided options along to SSHTransport.
+// This is only used when creating a transport for an ssh:// URL.
+func WithSSHOptions(opts ...SSHConnectionOption) ConnectionOption {
+	return func(cfg *ConnectionConfig) error {
+		cfg.SSHOptions = append(cfg.SSHOptions, opts...)
+		return nil
+	}
+}
+
+const defaultSSHTimeout = 30 * time.Second
+
+// SSHTransport creates a Transport which connects to the docker daemon over SSH.
+//
+// The URL must be in the form of ssh://[user@]host[:port][/path/to/docker.sock].
+// For each connection, a new session is opened on a shared SSH client which runs `docker system dial-stdio` on the remote host.
+// If a socket path is included in the URL it is passed along to the remote docker CLI using `--host`.
+//
+// Unlike FromDockerCLI, this does not require the docker CLI on the local host, only on the remote.
+func SSHTransport(u *url.URL, opts ...SSHConnectionOption) (*Transport, error) {
+	if u.Scheme != "ssh" {
+		return nil, fmt.Errorf("invalid ssh url scheme: %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host specified in ssh url: %s", u)
+	}
+
+	cfg := SSHConnectionConfig{
+		User:    u.User.Username(),
+		Timeout: defaultSSHTimeout,
+	}
+	for _, o := range opts {
+		if err := o(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("error determining ssh user: %w", err)
+		}
+		cfg.User = current.Username
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	var agentConn *sshAgent
+	if !cfg.DisableAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			agentConn = &sshAgent{sock: sock}
+		}
+	}
+
+	clientConfig, err := cfg.clientConfig(addr, agentConn)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := cfg.RemoteCommand
+	if len(cmd) == 0 {
+		cmd = []string{"docker"}
+		if u.Path != "" && u.Path != "/" {
+			cmd = append(cmd, "--host", "unix://"+u.Path)
+		}
+		cmd = append(cmd, "system", "dial-stdio")
+	}
+
+	d := &sshDialer{
+		addr:    addr,
+		config:  clientConfig,
+		agent:   agentConn,
+		cmd:     quoteCommand(cmd),
+		timeout: cfg.Timeout,
+		stderr:  cfg.Stderr,
+	}
+
+	tr := &http.Transport{
+		DisableCompression: true,
+		DialContext:        d.DialContext,
+	}
+
+	return &Transport{
+		scheme: "http",
+		c: &http.Client{
+			Transport: tr,
+		},
+		host: ".",
+		dial: func(ctx context.Context) (net.Conn, error) {
+			return tr.DialContext(ctx, "", "")
+		},
+		close: d.Close,
+	}, nil
+}
+
+// clientConfig creates the configuration for connecting to addr.
+// If agentConn is not nil, it is used to authenticate before the identity files.
+func (cfg *SSHConnectionConfig) clientConfig(addr string, agentConn *sshAgent) (*ssh.ClientConfig, error) {
+	hostKeyCallback := cfg.HostKeyCallback
+	var hostKeyAlgos []string
+	if hostKeyCallback == nil {
+		files := cfg.KnownHostsFiles
+		if len(files) == 0 {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("error determining home directory for known_hosts: %w", err)
+			}
+			files = []string{filepath.Join(home, ".ssh", "known_hosts")}
+		}
+
+		var err error
+		hostKeyCallback, err = knownhosts.New(files...)
+		if err != nil {
+			return nil, fmt.Errorf("error loading known_hosts: %w", err)
+		}
+		hostKeyAlgos = knownHostKeyAlgorithms(hostKeyCallback, addr)
+	}
+
+	var auth []ssh.AuthMethod
+	if agentConn != nil {
+		auth = append(auth, ssh.PublicKeysCallback(agentConn.Signers))
+	}
+
+	signers, err := loadIdentityFiles(cfg.IdentityFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	auth = append(auth, cfg.AuthMethods...)
+
+	return &ssh.ClientConfig{
+		User:              cfg.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgos,
+		Timeout:           cfg.Timeout,
+	}, nil
+}
+
+// knownHostKeyAlgorithms returns the host key algorithms for the keys recorded for addr in known_hosts, so the server
+// presents a key which can be verified rather than the first one it supports, like OpenSSH does.
+// Host certificates are preferred when a certificate authority is recorded for addr.
+// nil, which uses the defaults, is returned if addr is not in known_hosts.
+func knownHostKeyAlgorithms(hostKeyCallback ssh.HostKeyCallback, addr string) []string {
+	// The callback reports the keys recorded for addr when the presented key does not match.
+	var keyErr *knownhosts.KeyError
+	err := hostKeyCallback(addr, &net.TCPAddr{IP: net.IPv4zero}, placeholderHostKey{})
+	if !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	var (
+		certAlgos []string
+		keyAlgos  []string
+		files     = make(map[string][]string)
+	)
+	for _, k := range keyErr.Want {
+		if isCertAuthority(files, k) {
+			certAlgos = appendAlgorithms(certAlgos, sshCertAlgorithms[k.Key.Type()]...)
+			continue
+		}
+		algos := []string{k.Key.Type()}
+		if k.Key.Type() == ssh.KeyAlgoRSA {
+			algos = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+		}
+		keyAlgos = appendAlgorithms(keyAlgos, algos...)
+	}
+	return append(certAlgos, keyAlgos...)
+}
+
+// sshCertAlgorithms maps key types to the algorithms for host certificates signed by a key of that type.
+var sshCertAlgorithms = map[string][]string{
+	ssh.KeyAlgoRSA:        {ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01},
+	ssh.KeyAlgoECDSA256:   {ssh.CertAlgoECDSA256v01},
+	ssh.KeyAlgoECDSA384:   {ssh.CertAlgoECDSA384v01},
+	ssh.KeyAlgoECDSA521:   {ssh.CertAlgoECDSA521v01},
+	ssh.KeyAlgoSKECDSA256: {ssh.CertAlgoSKECDSA256v01},
+	ssh.KeyAlgoED25519:    {ssh.CertAlgoED25519v01},
+	ssh.KeyAlgoSKED25519:  {ssh.CertAlgoSKED25519v01},
+}
+
+// isCertAuthority returns true if the known_hosts line for k is marked with @cert-authority.
+// knownhosts does not expose the marker, so the line is read from the file; files caches the lines of each file.
+func isCertAuthority(files map[string][]string, k knownhosts.KnownKey) bool {
+	lines, ok := files[k.Filename]
+	if !ok {
+		data, _ := os.ReadFile(k.Filename)
+		lines = strings.Split(string(data), "\n")
+		files[k.Filename] = lines
+	}
+	if k.Line < 1 || k.Line > len(lines) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(lines[k.Line-1]), "@cert-authority")
+}
+
+func appendAlgorithms(algos []string, add ...string) []string {
+	for _, a := range add {
+		if !slices.Contains(algos, a) {
+			algos = append(algos, a)
+		}
+	}
+	return algos
+}
+
+// placeholderHostKey is a key which never matches a known_hosts entry.
+type placeholderHostKey struct{}
+
+func (placeholderHostKey) Type() string    { return "placeholder" }
+func (placeholderHostKey) Marshal() []byte { return []byte("placeholder") }
+func (placeholderHostKey) Verify([]byte, *ssh.Signature) error {
+	return errors.New("placeholder host key")
+}
+
+// loadIdentityFiles parses the provided private keys.
+// If no files are provided the default identity files are loaded, skipping any that do not exist.
+func loadIdentityFiles(files []string) ([]ssh.Signer, error) {
+	optional := false
+	if len(files) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		optional = true
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			files = append(files, filepath.Join(home, ".ssh", name))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			if optional && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("error reading ssh identity file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			var passErr *ssh.PassphraseMissingError
+			if optional && errors.As(err, &passErr) {
+				// Encrypted default keys are expected to be available through the agent instead.
+				continue
+			}
+			return nil, fmt.Errorf("error parsing ssh identity file %s: %w", f, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// sshAgent is a connection to the ssh-agent at $SSH_AUTH_SOCK.
+// The connection is kept open since the signers returned by the agent sign through it.
+type sshAgent struct {
+	sock string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	client agent.ExtendedAgent
+}
+
+// Signers returns the signers for the keys held by the agent, connecting to the agent if needed.
+func (a *sshAgent) Signers() ([]ssh.Signer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		conn, err := net.Dial("unix", a.sock)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to ssh agent: %w", err)
+		}
+		a.conn = conn
+		a.client = agent.NewClient(conn)
+	}
+
+	signers, err := a.client.Signers()
+	if err != nil {
+		// The connection may be broken, reconnect on the next handshake.
+		a.conn.Close()
+		a.conn = nil
+		return nil, fmt.Errorf("error getting keys from ssh agent: %w", err)
+	}
+	return signers, nil
+}
+
+// Close closes the connection to the agent, if there is one.
+func (a *sshAgent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		return nil
+	}
+	err := a.conn.Close()
+	a.conn = nil
+	return err
+}
+
+// sshDialer creates connections to the docker daemon by running the remote command in a new session
+// on a shared SSH client.
+// The client is (re)established on demand.
+type sshDialer struct {
+	addr    string
+	config  *ssh.ClientConfig
+	agent   *sshAgent
+	cmd     string
+	timeout time.Duration
+	stderr  io.Writer
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+func (d *sshDialer) getClient(ctx context.Context) (*ssh.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	conn, err := new(net.Dialer).DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error establishing ssh connection to %s: %w", d.addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(c, chans, reqs)
+	d.client = client
+
+	go func() {
+		client.Wait()
+		d.reset(client)
+	}()
+
+	return client, nil
+}
+
+// reset clears the cached client if it is still the passed in client.
+func (d *sshDialer) reset(client *ssh.Client) {
+	d.mu.Lock()
+	if d.client == client {
+		d.client = nil
+	}
+	d.mu.Unlock()
+	client.Close()
+}
+
+// Close closes the shared SSH client, if there is one, and the connection to the ssh-agent.
+// Sessions which are still open are closed along with it.
+func (d *sshDialer) Close() error {
+	d.mu.Lock()
+	client := d.client
+	d.client = nil
+	d.mu.Unlock()
+
+	var err error
+	if client != nil {
+		err = client.Close()
+	}
+	if d.agent != nil {
+		if agentErr := d.agent.Close(); err == nil {
+			err = agentErr
+		}
+	}
+	return err
+}
+
+func (d *sshDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := client.NewSession()
+	if err != nil {
+		// The cached connection may have gone away, try again with a fresh one.
+		d.reset(client)
+		client, err = d.getClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sess, err = client.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("error opening ssh session: %w", err)
+		}
+	}
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		sess.Close()
+		return nil, err
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		sess.Close()
+		return nil, err
+	}
+	sess.Stderr = d.stderr
+
+	if err := sess.Start(d.cmd); err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("error starting remote command %q: %w", d.cmd, err)
+	}
+
+	return &sshConn{
+		sess:   sess,
+		stdin:  stdin,
+		stdout: stdout,
+		local:  client.LocalAddr(),
+		remote: client.RemoteAddr(),
+	}, nil
+}
+
+// sshConn is a net.Conn over the stdio of a remote command running in an SSH session.
+type sshConn struct {
+	sess   *ssh.Session
+	stdin  io.WriteCloser
+	stdout io.Reader
+	local  net.Addr
+	remote net.Addr
+
+	closeOnce sync.Once
+}
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes the stdin of the remote command.
+func (c *sshConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		c.sess.Close()
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline is a no-op since deadlines are not supported on SSH sessions.
+// Callers rely on closing the connection to unblock reads and writes instead.
+func (c *sshConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (c *sshConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (c *sshConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// quoteCommand joins the command args into a single string suitable for a POSIX shell on the remote host.
+func quoteCommand(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, a := range args {
+		if a != "" && strings.IndexFunc(a, needsQuote) == -1 {
+			quoted = append(quoted, a)
+			continue
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('\'')
+		buf.WriteString(strings.ReplaceAll(a, "'", `'\''`))
+		buf.WriteByte('\'')
+		quoted = append(quoted, buf.String())
+	}
+	return strings.Join(quoted, " ")
+}
+
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_./:=@,+", r)
+}
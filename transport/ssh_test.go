@@ -0,0 +1,395 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// testSSHServer is a stand-in for sshd + `docker system dial-stdio`.
+// Every exec request is proxied to the backend address.
+type testSSHServer struct {
+	addr     string
+	hostKey  ssh.Signer
+	user     ssh.Signer
+	commands chan string
+}
+
+// newTestSSHServer creates a server with an ed25519 host key, and any extra host keys.
+func newTestSSHServer(t *testing.T, backend string, extraHostKeys ...ssh.Signer) *testSSHServer {
+	t.Helper()
+
+	s := &testSSHServer{
+		hostKey:  newTestSigner(t),
+		user:     newTestSigner(t),
+		commands: make(chan string, 10),
+	}
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(s.user.PublicKey().Marshal()) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(s.hostKey)
+	for _, k := range extraHostKeys {
+		cfg.AddHostKey(k)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { l.Close() })
+	s.addr = l.Addr().String()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveConn(conn, cfg, backend)
+		}
+	}()
+
+	return s
+}
+
+func (s *testSSHServer) serveConn(conn net.Conn, cfg *ssh.ServerConfig, backend string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go s.serveSession(ch, chReqs, backend)
+	}
+}
+
+func (s *testSSHServer) serveSession(ch ssh.Channel, reqs <-chan *ssh.Request, backend string) {
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		n := binary.BigEndian.Uint32(req.Payload)
+		s.commands <- string(req.Payload[4 : 4+n])
+		req.Reply(true, nil)
+
+		go func() {
+			defer ch.Close()
+
+			conn, err := net.Dial("tcp", backend)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			go func() {
+				io.Copy(conn, ch)
+				conn.(*net.TCPConn).CloseWrite()
+			}()
+			io.Copy(ch, conn)
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		}()
+	}
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NilError(t, err)
+	return signer
+}
+
+func writeKnownHosts(t *testing.T, addr string, key ssh.PublicKey) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, key)
+	assert.NilError(t, os.WriteFile(p, []byte(line+"\n"), 0o600))
+	return p
+}
+
+func TestSSHTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello " + req.URL.Path))
+	}))
+	defer srv.Close()
+
+	backend, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	sshSrv := newTestSSHServer(t, backend.Host)
+	knownHosts := writeKnownHosts(t, sshSrv.addr, sshSrv.hostKey.PublicKey())
+
+	tr, err := FromConnectionString("ssh://someuser@"+sshSrv.addr+"/run/user/1000/docker.sock", WithSSHOptions(func(cfg *SSHConnectionConfig) error {
+		cfg.KnownHostsFiles = []string{knownHosts}
+		cfg.DisableAgent = true
+		cfg.AuthMethods = []ssh.AuthMethod{ssh.PublicKeys(sshSrv.user)}
+		return nil
+	}))
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, p := range []string{"/foo", "/bar"} {
+		resp, err := tr.Do(ctx, http.MethodGet, p)
+		assert.NilError(t, err)
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(string(data), "hello "+p))
+	}
+
+	select {
+	case cmd := <-sshSrv.commands:
+		assert.Check(t, cmp.Equal(cmd, "docker --host unix:///run/user/1000/docker.sock system dial-stdio"))
+	default:
+		t.Fatal("expected remote command to be executed")
+	}
+}
+
+func TestSSHTransportDoRaw(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Connection") != "Upgrade" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buf.Flush()
+
+		line, _ := buf.ReadString('\n')
+		conn.Write([]byte("echo: " + line))
+	}))
+	defer srv.Close()
+
+	backend, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	sshSrv := newTestSSHServer(t, backend.Host)
+
+	tr, err := SSHTransport(&url.URL{Scheme: "ssh", Host: sshSrv.addr}, func(cfg *SSHConnectionConfig) error {
+		cfg.HostKeyCallback = ssh.FixedHostKey(sshSrv.hostKey.PublicKey())
+		cfg.DisableAgent = true
+		cfg.AuthMethods = []ssh.AuthMethod{ssh.PublicKeys(sshSrv.user)}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := tr.DoRaw(ctx, http.MethodPost, "/containers/foo/attach", WithUpgrade("tcp"))
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello\n"))
+	assert.NilError(t, err)
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(line, "echo: hello\n"))
+
+	assert.Check(t, cmp.Equal(<-sshSrv.commands, "docker system dial-stdio"))
+}
+
+func TestSSHTransportAgent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	backend, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	// The user key is only available through the agent.
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	keyring := agent.NewKeyring()
+	assert.NilError(t, keyring.Add(agent.AddedKey{PrivateKey: userKey}))
+
+	sshSrv := newTestSSHServer(t, backend.Host)
+	sshSrv.user, err = ssh.NewSignerFromKey(userKey)
+	assert.NilError(t, err)
+	knownHosts := writeKnownHosts(t, sshSrv.addr, sshSrv.hostKey.PublicKey())
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	defer l.Close()
+	agentClosed := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				agent.ServeAgent(keyring, conn)
+				agentClosed <- struct{}{}
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	t.Setenv("HOME", t.TempDir())
+
+	tr, err := SSHTransport(&url.URL{Scheme: "ssh", Host: sshSrv.addr}, func(cfg *SSHConnectionConfig) error {
+		cfg.KnownHostsFiles = []string{knownHosts}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := tr.Do(ctx, http.MethodGet, "/_ping")
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	// The agent connection stays open until the transport is closed.
+	select {
+	case <-agentClosed:
+		t.Fatal("agent connection closed before the transport")
+	default:
+	}
+	assert.NilError(t, tr.Close())
+	select {
+	case <-agentClosed:
+	case <-ctx.Done():
+		t.Fatal("agent connection not closed with the transport")
+	}
+
+	// The agent not being available is an error.
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "missing.sock"))
+	tr, err = SSHTransport(&url.URL{Scheme: "ssh", Host: sshSrv.addr}, func(cfg *SSHConnectionConfig) error {
+		cfg.KnownHostsFiles = []string{knownHosts}
+		return nil
+	})
+	assert.NilError(t, err)
+	defer tr.Close()
+	_, err = tr.Do(ctx, http.MethodGet, "/_ping")
+	assert.Check(t, cmp.ErrorContains(err, "error connecting to ssh agent"))
+}
+
+func TestSSHTransportHostKeyMismatch(t *testing.T) {
+	sshSrv := newTestSSHServer(t, "127.0.0.1:0")
+	knownHosts := writeKnownHosts(t, sshSrv.addr, newTestSigner(t).PublicKey())
+
+	tr, err := SSHTransport(&url.URL{Scheme: "ssh", Host: sshSrv.addr}, func(cfg *SSHConnectionConfig) error {
+		cfg.KnownHostsFiles = []string{knownHosts}
+		cfg.DisableAgent = true
+		cfg.AuthMethods = []ssh.AuthMethod{ssh.PublicKeys(sshSrv.user)}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = tr.Do(ctx, http.MethodGet, "/_ping")
+	var keyErr *knownhosts.KeyError
+	assert.Check(t, errors.As(err, &keyErr), err)
+}
+
+func TestSSHTransportKnownHostKeyAlgorithms(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	backend, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	ecdsaSigner, err := ssh.NewSignerFromKey(ecdsaKey)
+	assert.NilError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+	assert.NilError(t, err)
+
+	// The ecdsa key is preferred by default, but only the ed25519 key is known.
+	sshSrv := newTestSSHServer(t, backend.Host, ecdsaSigner)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	lines := []string{
+		knownhosts.Line([]string{"other.example.com"}, ecdsaSigner.PublicKey()),
+		knownhosts.Line([]string{knownhosts.Normalize(sshSrv.addr)}, sshSrv.hostKey.PublicKey()),
+		"@cert-authority " + knownhosts.Line([]string{"*.example.com"}, rsaSigner.PublicKey()),
+	}
+	assert.NilError(t, os.WriteFile(knownHosts, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	tr, err := SSHTransport(&url.URL{Scheme: "ssh", Host: sshSrv.addr}, func(cfg *SSHConnectionConfig) error {
+		cfg.KnownHostsFiles = []string{knownHosts}
+		cfg.DisableAgent = true
+		cfg.AuthMethods = []ssh.AuthMethod{ssh.PublicKeys(sshSrv.user)}
+		return nil
+	})
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := tr.Do(ctx, http.MethodGet, "/_ping")
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	callback, err := knownhosts.New(knownHosts)
+	assert.NilError(t, err)
+
+	for _, tc := range []struct {
+		addr string
+		want []string
+	}{
+		{addr: sshSrv.addr, want: []string{ssh.KeyAlgoED25519}},
+		{addr: "other.example.com:22", want: []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01, ssh.KeyAlgoECDSA256}},
+		{addr: "host.example.com:22", want: []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}},
+		{addr: "unknown.test:22"},
+	} {
+		assert.Check(t, cmp.DeepEqual(knownHostKeyAlgorithms(callback, tc.addr), tc.want), tc.addr)
+	}
+}
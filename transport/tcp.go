@@ -2,10 +2,13 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 )
 
+// TCPTransport creates a Transport which connects to the daemon over TCP, with TLS if it is set on the connection
+// options.
 func TCPTransport(host string, opts ...ConnectionOption) (*Transport, error) {
 	var cfg ConnectionConfig
 
@@ -16,8 +19,9 @@ func TCPTransport(host string, opts ...ConnectionOption) (*Transport, error) {
 	}
 
 	httpTransport := &http.Transport{
-		DialContext:     new(net.Dialer).DialContext,
+		DialContext:     cfg.dialer().DialContext,
 		TLSClientConfig: cfg.TLSConfig,
+		Proxy:           cfg.Proxy,
 	}
 
 	scheme := "http"
@@ -25,8 +29,31 @@ func TCPTransport(host string, opts ...ConnectionOption) (*Transport, error) {
 		scheme = "https"
 	}
 
+	// dial is used for hijacked connections, which cannot go through the http.Transport.
+	// So this must take care of the proxy and TLS itself.
 	dial := func(ctx context.Context) (net.Conn, error) {
-		return httpTransport.DialContext(ctx, "tcp", host)
+		conn, err := cfg.dialProxy(ctx, scheme, host)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TLSConfig == nil {
+			return conn, nil
+		}
+
+		tlsConfig := cfg.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			h, _, err := net.SplitHostPort(host)
+			if err != nil {
+				h = host
+			}
+			tlsConfig.ServerName = h
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
 	}
 
 	t := &Transport{
@@ -37,6 +64,7 @@ func TCPTransport(host string, opts ...ConnectionOption) (*Transport, error) {
 		},
 		dial: dial,
 	}
+	cfg.configure(t)
 
 	return t, nil
 }
@@ -4,12 +4,17 @@ import (
 	"context"
 	"crypto/tls"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/cpuguy83/go-docker/errdefs"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func TestTCPTransport(t *testing.T) {
@@ -80,3 +85,80 @@ func TestTCPTransportDoRawWithTLS(t *testing.T) {
 	// but it should not panic
 	assert.Assert(t, err != nil, "expected an error but got none")
 }
+
+func TestTCPTransportResponseHeaderTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("slow") != "" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// The body may take longer than the timeout.
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	tr, err := TCPTransport(u.Host, WithResponseHeaderTimeout(50*time.Millisecond))
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	ctx := context.Background()
+	slow := func(req *http.Request) error {
+		req.URL.RawQuery = "slow=1"
+		return nil
+	}
+
+	_, err = tr.Do(ctx, http.MethodGet, "/v1.41/info", slow)
+	assert.Check(t, errdefs.IsUnavailable(err), err)
+
+	for _, uri := range []string{"/v1.41/info", "/v1.41/containers/foo/wait", "/v1.41/containers/prune", "/v1.41/images/prune", "/v1.41/build/prune"} {
+		var opts []RequestOpt
+		if uri != "/v1.41/info" {
+			opts = append(opts, slow)
+		}
+		resp, err := tr.Do(ctx, http.MethodGet, uri, opts...)
+		assert.NilError(t, err, uri)
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NilError(t, err, uri)
+		assert.Check(t, cmp.Equal(string(data), "done"), uri)
+	}
+}
+
+func TestTransportClose(t *testing.T) {
+	var closed atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	assert.NilError(t, err)
+
+	tr, err := TCPTransport(u.Host, WithIdleConnections(1, time.Minute), WithDialTimeout(time.Second), WithKeepAlive(-1))
+	assert.NilError(t, err)
+
+	resp, err := tr.Do(context.Background(), http.MethodGet, "/foo")
+	assert.NilError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	assert.NilError(t, tr.Close())
+
+	deadline := time.Now().Add(10 * time.Second)
+	for closed.Load() == 0 {
+		assert.Assert(t, time.Now().Before(deadline), "timeout waiting for idle connection to be closed")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
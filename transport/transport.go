@@ -16,7 +16,7 @@ import (
 // Doer performs an http request for Client
 // It is the Doer's responsibility to deal with setting the host details on
 // the request
-// It is expected that one Doer connects to one Docker instance.
+// It is expected that one Doer connects to one Docker instance, use `Pool` to spread requests across multiple instances.
 type Doer interface {
 	// Do typically performs a normal http request/response
 	Do(ctx context.Context, method string, uri string, opts ...RequestOpt) (*http.Response, error)
@@ -37,6 +37,10 @@ type Transport struct {
 	host      string
 	scheme    string
 	transform func(*http.Request)
+	close     func() error
+
+	responseHeaderTimeout time.Duration
+	keepAlive             time.Duration
 }
 
 // Do implements the Doer.Do interface
@@ -56,13 +60,41 @@ func (t *Transport) Do(ctx context.Context, method, uri string, opts ...RequestO
 	if t.transform != nil {
 		t.transform(req)
 	}
-	resp, err := t.c.Do(req)
+
+	if t.responseHeaderTimeout <= 0 || isLongRunning(uri) {
+		return t.c.Do(req)
+	}
+
+	ctx, timedOut, cancel := withResponseHeaderTimeout(ctx, t.responseHeaderTimeout)
+	resp, err := t.c.Do(req.WithContext(ctx))
+	if timedOut() {
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, responseHeaderTimeoutError(method, uri, t.responseHeaderTimeout)
+	}
 	if err != nil {
+		cancel()
 		return resp, err
 	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
 	return resp, nil
 }
 
+// Close closes any idle connections to the daemon.
+// Connections which are in use, including hijacked ones, are not closed, except for SSH transports where the shared
+// SSH connection is closed along with all the sessions running over it.
+//
+// The transport may still be used after it is closed, at which point new connections are established.
+func (t *Transport) Close() error {
+	t.c.CloseIdleConnections()
+	if t.close != nil {
+		return t.close()
+	}
+	return nil
+}
+
 // Do implements the Doer.DoRaw interface
 func (t *Transport) DoRaw(ctx context.Context, method, uri string, opts ...RequestOpt) (conn net.Conn, retErr error) {
 	req := &http.Request{Header: http.Header{}}
@@ -88,9 +120,13 @@ func (t *Transport) DoRaw(ctx context.Context, method, uri string, opts ...Reque
 
 	// There can be long periods of inactivity when hijacking a connection.
 	// Set keep-alive to ensure that the connection is not broken due to idle time.
-	if tc, ok := conn.(*net.TCPConn); ok {
+	if tc, ok := conn.(*net.TCPConn); ok && t.keepAlive >= 0 {
+		period := t.keepAlive
+		if period == 0 {
+			period = defaultRawKeepAlive
+		}
 		tc.SetKeepAlive(true)
-		tc.SetKeepAlivePeriod(30 * time.Second)
+		tc.SetKeepAlivePeriod(period)
 	}
 
 	cc := httputil.NewClientConn(conn, nil)
@@ -109,6 +145,9 @@ func (t *Transport) DoRaw(ctx context.Context, method, uri string, opts ...Reque
 			resp.Body.Close()
 			return nil, fmt.Errorf("unable to upgrade to %s, received %d", req.Header.Get("Upgrade"), resp.StatusCode)
 		}
+		if fn, ok := ctx.Value(upgradeResponseKey{}).(func(*http.Response)); ok {
+			fn(resp)
+		}
 	}
 
 	conn, buf := cc.Hijack()
@@ -136,13 +175,45 @@ type ConnectionOption func(*ConnectionConfig) error
 // ConnectionConfig holds the options available for configuring a new transport.
 type ConnectionConfig struct {
 	TLSConfig *tls.Config
+	// SSHOptions are passed along to SSHTransport when connecting to an ssh:// URL.
+	SSHOptions []SSHConnectionOption
+
+	// DialTimeout is the maximum amount of time to wait for a connection to be established.
+	// Zero means there is no timeout other than the one from the request context.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS handshake.
+	// Zero means no timeout.
+	TLSHandshakeTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes.
+	// Zero uses the net.Dialer default for regular connections and 30 seconds for hijacked ones.
+	// A negative value disables keep-alives.
+	KeepAlive time.Duration
+	// MaxIdleConns is the maximum number of idle connections to keep open for reuse.
+	// Zero uses the net/http default.
+	MaxIdleConns int
+	// IdleConnTimeout is how long an idle connection is kept open for reuse.
+	// Zero means idle connections are kept open until the transport is closed.
+	IdleConnTimeout time.Duration
+	// ResponseHeaderTimeout is the maximum amount of time to wait for response headers after the request is sent.
+	// Zero means no timeout.
+	//
+	// This does not apply to DoRaw or to endpoints which can legitimately take a long time to respond:
+	// streaming endpoints (events, logs, stats, attach, exec start, build, pull, push, load, save, export, session, grpc),
+	// endpoints that wait on the container (wait, stop, restart), commit, and prune for containers, images, volumes,
+	// networks and the build cache.
+	ResponseHeaderTimeout time.Duration
+	// Proxy returns the HTTP(S) proxy to use for a request, or nil to connect directly.
+	// This has the same semantics as http.Transport.Proxy, and is only used by TCP transports.
+	// See `WithProxy` and `WithProxyFromEnvironment`.
+	Proxy func(*http.Request) (*url.URL, error)
+	// PeerCredentialsCheck, if set, is called with the credentials of the daemon process for each new connection.
+	// This is only used by unix socket transports, see `WithPeerCredentialsCheck`.
+	PeerCredentialsCheck func(PeerCredentials) error
 }
 
 // FromConnectionURL creates a Transport from a provided URL
 //
-// The URL's scheme must specify the protocol ("unix", "tcp", etc.)
-//
-// TODO: implement ssh schemes.
+// The URL's scheme must specify the protocol ("unix", "tcp", "ssh", etc.)
 func FromConnectionURL(u *url.URL, opts ...ConnectionOption) (*Transport, error) {
 	switch u.Scheme {
 	case "unix":
@@ -151,12 +222,44 @@ func FromConnectionURL(u *url.URL, opts ...ConnectionOption) (*Transport, error)
 		return TCPTransport(u.Host, opts...)
 	case "npipe":
 		return NpipeTransport(u.Path, opts...)
+	case "ssh":
+		var cfg ConnectionConfig
+		for _, o := range opts {
+			if err := o(&cfg); err != nil {
+				return nil, err
+			}
+		}
+		t, err := SSHTransport(u, cfg.SSHOptions...)
+		if err != nil {
+			return nil, err
+		}
+		cfg.configure(t)
+		return t, nil
 	default:
-		// TODO: ssh
 		return nil, fmt.Errorf("protocol not supported: %s", u.Scheme)
 	}
 }
 
+// FromDialer creates a Transport which uses the provided function to connect to the daemon.
+// The connection is expected to speak plain HTTP, e.g. an in-memory connection to a server in the same process.
+func FromDialer(dial func(context.Context) (net.Conn, error)) *Transport {
+	tr := &http.Transport{
+		DisableCompression: true,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}
+
+	return &Transport{
+		scheme: "http",
+		host:   ".",
+		c: &http.Client{
+			Transport: tr,
+		},
+		dial: dial,
+	}
+}
+
 const (
 	headerConnection = "Connection"
 	headerUpgrade    = "Upgrade"
@@ -171,6 +274,15 @@ func WithUpgrade(proto string) RequestOpt {
 	}
 }
 
+type upgradeResponseKey struct{}
+
+// WithUpgradeResponse returns a context which makes DoRaw call fn with the response to the upgrade request, such as
+// to read the response headers. The body of the response must not be used, the stream is returned by DoRaw instead.
+// fn is not called if the daemon does not send a response before switching protocols.
+func WithUpgradeResponse(ctx context.Context, fn func(*http.Response)) context.Context {
+	return context.WithValue(ctx, upgradeResponseKey{}, fn)
+}
+
 // WithAddHeaders is a RequestOpt that adds the specified headers to the request.
 // If the header already exists, it will be appended to.
 func WithAddHeaders(headers map[string][]string) RequestOpt {
@@ -2,7 +2,25 @@
 
 package transport
 
+import "context"
+
+// DefaultHost is the default docker host for the platform, in DOCKER_HOST format.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// DefaultTransport creates a Transport for the default docker host for the platform.
+// See `DefaultUnixTransport` for how the socket is found.
 func DefaultTransport() (*Transport, error) {
-	return UnixSocketTransport("/var/run/docker.sock")
+	return DefaultUnixTransport()
 }
 
+// DiscoverHost returns the host, in DOCKER_HOST format, of the daemon found by `DiscoverUnixSocket`.
+// This is what is used instead of DOCKER_HOST when it is not set.
+//
+// If no daemon is found, DefaultHost is returned along with the error from DiscoverUnixSocket.
+func DiscoverHost(ctx context.Context, opts ...ConnectionOption) (string, error) {
+	d, err := DiscoverUnixSocket(ctx, opts...)
+	if err != nil {
+		return DefaultHost, err
+	}
+	return "unix://" + d.Path, nil
+}
@@ -2,6 +2,17 @@
 
 package transport
 
+import "context"
+
+// DefaultHost is the default docker host for the platform, in DOCKER_HOST format.
+const DefaultHost = "npipe:////./pipe/docker_engine"
+
 func DefaultTransport() (*Transport, error) {
 	return NpipeTransport("//./pipe/docker_engine")
 }
+
+// DiscoverHost returns the host which is used instead of DOCKER_HOST when it is not set.
+// This is always DefaultHost on Windows.
+func DiscoverHost(ctx context.Context, opts ...ConnectionOption) (string, error) {
+	return DefaultHost, nil
+}
@@ -6,8 +6,33 @@ import (
 	"net/http"
 )
 
+// DefaultUnixTransport creates a Transport for the first unix socket found by `DiscoverUnixSocket`.
+// This finds rootless docker, Docker Desktop, and podman daemons as well as a rootful docker daemon.
+//
+// If no daemon can be reached, the transport connects to /var/run/docker.sock.
+// Use DefaultUnixTransportContext to bound discovery with a context and get the error instead.
 func DefaultUnixTransport() (*Transport, error) {
-	return UnixSocketTransport("/var/run/docker.sock")
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	tr, err := DefaultUnixTransportContext(ctx)
+	if err != nil {
+		return UnixSocketTransport(defaultUnixSocket)
+	}
+	return tr, nil
+}
+
+// DefaultUnixTransportContext creates a Transport for the first unix socket found by `DiscoverUnixSocket`, which is
+// bounded by the context.
+// The connection options are used both to check the candidates and for the returned transport.
+//
+// If no daemon can be reached, the error from DiscoverUnixSocket is returned.
+func DefaultUnixTransportContext(ctx context.Context, opts ...ConnectionOption) (*Transport, error) {
+	d, err := DiscoverUnixSocket(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return UnixSocketTransport(d.Path, opts...)
 }
 
 // UnixSocketTransport creates a Transport that works for unix sockets.
@@ -22,10 +47,17 @@ func UnixSocketTransport(sock string, opts ...ConnectionOption) (*Transport, err
 		}
 	}
 
+	dialSock := func(ctx context.Context) (net.Conn, error) {
+		return cfg.dialer().DialContext(ctx, "unix", sock)
+	}
+	if cfg.PeerCredentialsCheck != nil {
+		dialSock = verifyPeer(dialSock, cfg.PeerCredentialsCheck)
+	}
+
 	t := &http.Transport{
 		DisableCompression: true,
 		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-			return new(net.Dialer).DialContext(ctx, "unix", sock)
+			return dialSock(ctx)
 		},
 		TLSClientConfig: cfg.TLSConfig,
 	}
@@ -39,7 +71,7 @@ func UnixSocketTransport(sock string, opts ...ConnectionOption) (*Transport, err
 		return t.DialContext(ctx, "", "")
 	}
 
-	return &Transport{
+	tr := &Transport{
 		host:   sock,
 		scheme: scheme,
 		c: &http.Client{
@@ -47,5 +79,7 @@ func UnixSocketTransport(sock string, opts ...ConnectionOption) (*Transport, err
 		},
 		dial:      dial,
 		transform: go120Dot6HostTransform(sock),
-	}, nil
+	}
+	cfg.configure(tr)
+	return tr, nil
 }
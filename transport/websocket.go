@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// WebSocket opcodes, see RFC 6455 section 5.2.
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsCloseNormal = 1000
+	// wsMaxControlPayload is the maximum payload size of control frames.
+	wsMaxControlPayload = 125
+)
+
+// DoWebSocket performs a WebSocket handshake for the uri using DoRaw and returns the established connection.
+//
+// This is a minimal client for the endpoints of the Engine API which support WebSockets, such as
+// /containers/{id}/attach/ws: it does not support extensions or subprotocols, and it does not validate the
+// Sec-WebSocket-Accept header since DoRaw does not expose the response headers.
+func DoWebSocket(ctx context.Context, d Doer, uri string, opts ...RequestOpt) (*WebSocketConn, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	withHandshake := func(req *http.Request) error {
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		return nil
+	}
+
+	conn, err := d.DoRaw(ctx, http.MethodGet, uri, append(opts[:len(opts):len(opts)], WithUpgrade("websocket"), withHandshake)...)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocketConn{conn: conn, br: bufio.NewReader(conn)}, nil
+}
+
+// WebSocketConn is the client side of a WebSocket connection.
+//
+// Reads return the payload of the data frames sent by the server, regardless of whether they are text or binary
+// frames. Pings from the server are answered while reading, and a close frame from the server is answered and ends
+// the stream with io.EOF.
+// Each write is sent as a single binary frame.
+//
+// WebSocketConn is safe to read from and write to concurrently, but only from one reader at a time.
+type WebSocketConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// remaining is the number of bytes left to read from the payload of the current data frame.
+	remaining int64
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+	readErr   error
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+type wsFrameHeader struct {
+	fin    bool
+	opcode byte
+	masked bool
+	mask   [4]byte
+	length int64
+}
+
+func (c *WebSocketConn) readHeader() (wsFrameHeader, error) {
+	var h wsFrameHeader
+
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&0x80 != 0
+	h.opcode = b[0] & 0x0f
+	h.masked = b[1]&0x80 != 0
+
+	switch n := b[1] & 0x7f; n {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, noEOF(err)
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, noEOF(err)
+		}
+		l := binary.BigEndian.Uint64(b[:8])
+		if l > 1<<63-1 {
+			return h, errors.New("websocket: invalid frame length")
+		}
+		h.length = int64(l)
+	default:
+		h.length = int64(n)
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, noEOF(err)
+		}
+	}
+	return h, nil
+}
+
+// readControl reads the payload of a control frame.
+func (c *WebSocketConn) readControl(h wsFrameHeader) ([]byte, error) {
+	if h.length > wsMaxControlPayload || !h.fin {
+		return nil, fmt.Errorf("websocket: invalid control frame")
+	}
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, noEOF(err)
+	}
+	if h.masked {
+		maskBytes(h.mask, 0, payload)
+	}
+	return payload, nil
+}
+
+// Read implements io.Reader
+func (c *WebSocketConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+
+		h, err := c.readHeader()
+		if err != nil {
+			c.readErr = err
+			return 0, err
+		}
+
+		switch h.opcode {
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			c.remaining = h.length
+			c.masked = h.masked
+			c.mask = h.mask
+			c.maskPos = 0
+		case wsOpPing:
+			payload, err := c.readControl(h)
+			if err != nil {
+				c.readErr = err
+				return 0, err
+			}
+			if err := c.writeFrame(wsOpPong, payload); err != nil && !errors.Is(err, net.ErrClosed) {
+				c.readErr = err
+				return 0, err
+			}
+		case wsOpPong:
+			if _, err := c.readControl(h); err != nil {
+				c.readErr = err
+				return 0, err
+			}
+		case wsOpClose:
+			payload, err := c.readControl(h)
+			if err != nil {
+				c.readErr = err
+				return 0, err
+			}
+			// Echo the status code back, as required by the protocol.
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			c.wmu.Lock()
+			if !c.closeSent {
+				c.closeSent = true
+				c.writeFrameLocked(wsOpClose, payload)
+			}
+			c.wmu.Unlock()
+			c.readErr = io.EOF
+			return 0, io.EOF
+		default:
+			c.readErr = fmt.Errorf("websocket: unknown opcode %d", h.opcode)
+			return 0, c.readErr
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	if c.masked {
+		c.maskPos = maskBytes(c.mask, c.maskPos, p[:n])
+	}
+	c.remaining -= int64(n)
+	if err != nil {
+		err = noEOF(err)
+		c.readErr = err
+		if n > 0 {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+// Write implements io.Writer
+// p is sent as a single binary frame.
+func (c *WebSocketConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Ping sends a ping to the server.
+// The pong sent back by the server is discarded by Read.
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > wsMaxControlPayload {
+		return fmt.Errorf("websocket: ping payload is too large")
+	}
+	return c.writeFrame(wsOpPing, data)
+}
+
+// CloseWrite sends a close frame to the server, if one was not already sent, without closing the connection.
+// Data sent by the server until it replies with its own close frame can still be read, after which Read returns
+// io.EOF.
+// Close must still be called to close the connection.
+func (c *WebSocketConn) CloseWrite() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	var code [2]byte
+	binary.BigEndian.PutUint16(code[:], wsCloseNormal)
+	return c.writeFrameLocked(wsOpClose, code[:])
+}
+
+// Close sends a close frame to the server, if one was not already sent, and closes the connection.
+func (c *WebSocketConn) Close() error {
+	c.CloseWrite()
+	return c.conn.Close()
+}
+
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes a single frame, masked as required for frames sent by a client.
+// c.wmu must be held.
+func (c *WebSocketConn) writeFrameLocked(opcode byte, payload []byte) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|opcode)
+
+	switch l := len(payload); {
+	case l <= 125:
+		buf = append(buf, 0x80|byte(l))
+	case l <= 0xffff:
+		buf = append(buf, 0x80|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(l))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(l))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	buf = append(buf, mask[:]...)
+	start := len(buf)
+	buf = append(buf, payload...)
+	maskBytes(mask, 0, buf[start:])
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// maskBytes applies the mask to b, starting at position pos of the mask, and returns the next position.
+func maskBytes(mask [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= mask[pos&3]
+		pos++
+	}
+	return pos & 3
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, for when the connection ends in the middle of a frame.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
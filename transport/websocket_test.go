@@ -0,0 +1,190 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// writeServerFrame writes an unmasked frame as a server would.
+func writeServerFrame(w io.Writer, fin bool, opcode byte, payload []byte) error {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	hdr := []byte{b0}
+	switch l := len(payload); {
+	case l <= 125:
+		hdr = append(hdr, byte(l))
+	case l <= 0xffff:
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(l))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(l))
+	}
+	_, err := w.Write(append(hdr, payload...))
+	return err
+}
+
+// readClientFrame reads a frame sent by the client, which must be masked.
+func readClientFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+
+	var hdr [2]byte
+	_, err := io.ReadFull(r, hdr[:])
+	assert.NilError(t, err)
+	assert.Assert(t, hdr[1]&0x80 != 0, "client frames must be masked")
+
+	length := int(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		_, err := io.ReadFull(r, b[:])
+		assert.NilError(t, err)
+		length = int(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, err := io.ReadFull(r, b[:])
+		assert.NilError(t, err)
+		length = int(binary.BigEndian.Uint64(b[:]))
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(r, mask[:])
+	assert.NilError(t, err)
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	assert.NilError(t, err)
+	maskBytes(mask, 0, payload)
+	return hdr[0] & 0x0f, payload
+}
+
+func TestWebSocket(t *testing.T) {
+	type serverConn struct {
+		conn net.Conn
+		rw   *bufio.ReadWriter
+	}
+	connCh := make(chan serverConn, 1)
+	headers := make(chan http.Header, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers <- req.Header.Clone()
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		connCh <- serverConn{conn, rw}
+	}))
+	defer srv.Close()
+
+	tr, err := TCPTransport(srv.Listener.Addr().String())
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	ws, err := DoWebSocket(context.Background(), tr, "/containers/foo/attach/ws")
+	assert.NilError(t, err)
+	defer ws.Close()
+
+	h := <-headers
+	assert.Check(t, cmp.Equal(h.Get("Upgrade"), "websocket"))
+	assert.Check(t, cmp.Equal(h.Get("Sec-WebSocket-Version"), "13"))
+	assert.Check(t, h.Get("Sec-WebSocket-Key") != "")
+
+	sc := <-connCh
+	defer sc.conn.Close()
+
+	// Fragmented message with an extended length, with a ping in between the fragments.
+	big := bytes.Repeat([]byte("a"), 300)
+	assert.NilError(t, writeServerFrame(sc.rw, false, wsOpText, []byte("hello ")))
+	assert.NilError(t, writeServerFrame(sc.rw, true, wsOpPing, []byte("ping")))
+	assert.NilError(t, writeServerFrame(sc.rw, true, wsOpContinuation, big))
+	assert.NilError(t, sc.rw.Flush())
+
+	data := make([]byte, 6+len(big))
+	_, err = io.ReadFull(ws, data)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "hello "+string(big)))
+
+	op, payload := readClientFrame(t, sc.rw)
+	assert.Check(t, cmp.Equal(op, byte(wsOpPong)))
+	assert.Check(t, cmp.Equal(string(payload), "ping"))
+
+	_, err = ws.Write([]byte(strings.Repeat("b", 70000)))
+	assert.NilError(t, err)
+	op, payload = readClientFrame(t, sc.rw)
+	assert.Check(t, cmp.Equal(op, byte(wsOpBinary)))
+	assert.Check(t, cmp.Equal(string(payload), strings.Repeat("b", 70000)))
+
+	assert.NilError(t, ws.Ping([]byte("hi")))
+	op, payload = readClientFrame(t, sc.rw)
+	assert.Check(t, cmp.Equal(op, byte(wsOpPing)))
+	assert.Check(t, cmp.Equal(string(payload), "hi"))
+
+	// A close from the server is echoed back and ends the stream.
+	assert.NilError(t, writeServerFrame(sc.rw, true, wsOpClose, []byte{0x03, 0xe8, 'b', 'y', 'e'}))
+	assert.NilError(t, sc.rw.Flush())
+
+	_, err = ws.Read(data)
+	assert.Check(t, cmp.Equal(err, io.EOF))
+	op, payload = readClientFrame(t, sc.rw)
+	assert.Check(t, cmp.Equal(op, byte(wsOpClose)))
+	assert.Check(t, cmp.DeepEqual(payload, []byte{0x03, 0xe8}))
+
+	_, err = ws.Write([]byte("after close"))
+	assert.Check(t, err != nil)
+}
+
+func TestWebSocketCloseWrite(t *testing.T) {
+	connCh := make(chan *bufio.ReadWriter, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		connCh <- rw
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	tr, err := TCPTransport(srv.Listener.Addr().String())
+	assert.NilError(t, err)
+	defer tr.Close()
+
+	ws, err := DoWebSocket(context.Background(), tr, "/containers/foo/attach/ws")
+	assert.NilError(t, err)
+	defer ws.Close()
+	rw := <-connCh
+
+	assert.NilError(t, ws.CloseWrite())
+	op, payload := readClientFrame(t, rw)
+	assert.Check(t, cmp.Equal(op, byte(wsOpClose)))
+	assert.Check(t, cmp.DeepEqual(payload, []byte{0x03, 0xe8}))
+
+	_, err = ws.Write([]byte("after close"))
+	assert.Check(t, err != nil)
+
+	// Data sent before the close from the server can still be read.
+	assert.NilError(t, writeServerFrame(rw, true, wsOpBinary, []byte("output")))
+	assert.NilError(t, writeServerFrame(rw, true, wsOpClose, []byte{0x03, 0xe8}))
+	assert.NilError(t, rw.Flush())
+
+	data, err := io.ReadAll(ws)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(string(data), "output"))
+}
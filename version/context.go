@@ -4,6 +4,9 @@ import (
 	"context"
 	"os"
 	"path"
+	"strings"
+
+	"github.com/cpuguy83/go-docker/errdefs"
 )
 
 type apiVersion struct{}
@@ -39,7 +42,7 @@ func FromEnv(ctx context.Context) context.Context {
 }
 
 const (
-	maxAPIVersion = "v1.41"
+	maxAPIVersion = "v1.47"
 	minAPIVersion = "v1.12"
 )
 
@@ -60,3 +63,28 @@ func Negotiate(ctx context.Context, srv string) context.Context {
 	}
 	return WithAPIVersion(ctx, v)
 }
+
+// NegotiateRange is like Negotiate, but it also takes the minimum version supported by the server into account.
+// An errdefs.NotImplemented error is returned if there is no version supported by both the client and the server.
+//
+// An empty srvMin means the server did not report a minimum version, as is the case before API version 1.21.
+func NegotiateRange(ctx context.Context, srvMin, srvMax string) (context.Context, error) {
+	clientMax := APIVersion(ctx)
+	if clientMax == "" {
+		clientMax = maxAPIVersion
+	}
+
+	ctx = Negotiate(ctx, srvMax)
+	v := APIVersion(ctx)
+	if LessThan(v, minAPIVersion) || LessThan(v, srvMin) {
+		if srvMin == "" {
+			srvMin = minAPIVersion
+		}
+		if srvMax == "" {
+			srvMax = minAPIVersion
+		}
+		return ctx, errdefs.NotImplementedf("no API version is supported by both the client (%s to %s) and the server (%s to %s)",
+			strings.TrimPrefix(minAPIVersion, "v"), strings.TrimPrefix(clientMax, "v"), strings.TrimPrefix(srvMin, "v"), strings.TrimPrefix(srvMax, "v"))
+	}
+	return ctx, nil
+}
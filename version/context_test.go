@@ -0,0 +1,35 @@
+package version
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestNegotiateRange(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		client, srvMin, srvMax, expected string
+	}{
+		{srvMin: "1.24", srvMax: "1.30", expected: "1.30"},
+		{srvMin: "1.24", srvMax: "1.99", expected: maxAPIVersion},
+		{client: "1.25", srvMin: "1.24", srvMax: "1.30", expected: "1.25"},
+		{client: "1.40", srvMax: "1.20", expected: "1.20"},
+		{client: "1.40", expected: minAPIVersion},
+	} {
+		negotiated, err := NegotiateRange(WithAPIVersion(ctx, tc.client), tc.srvMin, tc.srvMax)
+		assert.Check(t, err)
+		assert.Check(t, cmp.Equal(APIVersion(negotiated), tc.expected), "%+v", tc)
+	}
+
+	_, err := NegotiateRange(WithAPIVersion(ctx, "1.40"), "1.44", "1.47")
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "client (1.12 to 1.40) and the server (1.44 to 1.47)"))
+
+	_, err = NegotiateRange(ctx, "", "1.10")
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+}
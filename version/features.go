@@ -0,0 +1,68 @@
+package version
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+)
+
+// Feature is an endpoint or an option of the Engine API which requires a minimum API version.
+type Feature struct {
+	// Name is used in error messages.
+	Name string
+	// MinVersion is the first API version which supports the feature.
+	MinVersion string
+}
+
+// The features which are gated on the API version.
+// See https://docs.docker.com/engine/api/version-history/
+var (
+	ContainerWait          = Feature{Name: "container wait", MinVersion: "1.30"}
+	WaitCondition          = Feature{Name: "wait condition", MinVersion: "1.30"}
+	CreatePlatform         = Feature{Name: "container create platform", MinVersion: "1.41"}
+	HealthcheckStartPeriod = Feature{Name: "Config.Healthcheck.StartPeriod", MinVersion: "1.29"}
+	Mounts                 = Feature{Name: "HostConfig.Mounts", MinVersion: "1.25"}
+	LogsUntil              = Feature{Name: "logs until", MinVersion: "1.35"}
+	PullPlatform           = Feature{Name: "image pull platform", MinVersion: "1.32"}
+	ImagePrune             = Feature{Name: "image prune", MinVersion: "1.25"}
+	ContainerPrune         = Feature{Name: "container prune", MinVersion: "1.25"}
+	StatsOneShot           = Feature{Name: "stats one-shot", MinVersion: "1.41"}
+	RestartSignal          = Feature{Name: "restart signal", MinVersion: "1.42"}
+	ContainerUpdate        = Feature{Name: "container update", MinVersion: "1.22"}
+)
+
+// Check returns an errdefs.NotImplemented error naming the first of the features which is not supported by
+// apiVersion.
+// An empty apiVersion is assumed to support all features.
+func Check(apiVersion string, features ...Feature) error {
+	for _, f := range features {
+		if LessThan(apiVersion, f.MinVersion) {
+			return errdefs.NotImplementedf("%s requires API version %s or higher, the API version in use is %s", f.Name, f.MinVersion, apiVersion)
+		}
+	}
+	return nil
+}
+
+// Require returns a request option (a transport.RequestOpt) which checks the features against the API version
+// of the request, see Check.
+//
+// The API version is taken from the request path rather than the context so that the version negotiated by the
+// client is also checked.
+func Require(features ...Feature) func(*http.Request) error {
+	return func(req *http.Request) error {
+		return Check(FromPath(req.URL.Path), features...)
+	}
+}
+
+var pathPrefix = regexp.MustCompile(`^/v([0-9.]+)/`)
+
+// FromPath returns the API version in the request path, as added by Join, without the "v" prefix.
+// If the path does not have a version then an empty string will be returned.
+func FromPath(p string) string {
+	m := pathPrefix.FindStringSubmatch(p)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
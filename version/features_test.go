@@ -0,0 +1,40 @@
+package version
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cpuguy83/go-docker/errdefs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	assert.Check(t, Check("1.41", CreatePlatform, Mounts))
+	assert.Check(t, Check("", CreatePlatform))
+	assert.Check(t, Check("1.24"))
+
+	err := Check("1.40", Mounts, CreatePlatform)
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "container create platform requires API version 1.41 or higher, the API version in use is 1.40"))
+}
+
+func TestRequire(t *testing.T) {
+	req := &http.Request{URL: &url.URL{Path: "/v1.24/containers/create"}}
+	err := Require(Mounts)(req)
+	assert.Check(t, errdefs.IsNotImplemented(err), err)
+	assert.Check(t, cmp.ErrorContains(err, "HostConfig.Mounts"))
+
+	req.URL.Path = "/v1.25/containers/create"
+	assert.Check(t, Require(Mounts)(req))
+
+	req.URL.Path = "/containers/create"
+	assert.Check(t, Require(Mounts)(req))
+}
+
+func TestFromPath(t *testing.T) {
+	assert.Check(t, cmp.Equal(FromPath("/v1.41/containers/json"), "1.41"))
+	assert.Check(t, cmp.Equal(FromPath("/containers/json"), ""))
+	assert.Check(t, cmp.Equal(FromPath("/containers/v1.41/json"), ""))
+}